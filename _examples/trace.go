@@ -11,7 +11,8 @@ func main() {
 		pack .e -fill x -expand true
 	`)
 
-	ir.RegisterCommand("go::onchange", func() {
+	// trace passes the name, the index and the operation
+	ir.RegisterCommand("go::onchange", func(_ ...string) {
 		var s string
 		ir.EvalAs(&s, "set go::etext")
 		fmt.Println(s)
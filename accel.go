@@ -0,0 +1,344 @@
+package gothic
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// An accelerator parsed from the "Ctrl+Shift+S" form.
+type accelerator struct {
+	ctrl, shift, alt bool
+	key              string // keysym
+	name             string // canonical "Ctrl+Shift+S" form
+}
+
+// key names accepted in accelerators and their keysyms
+var accelerator_keys = map[string]string{
+	"enter":     "Return",
+	"return":    "Return",
+	"escape":    "Escape",
+	"esc":       "Escape",
+	"tab":       "Tab",
+	"space":     "space",
+	"backspace": "BackSpace",
+	"delete":    "Delete",
+	"del":       "Delete",
+	"insert":    "Insert",
+	"ins":       "Insert",
+	"home":      "Home",
+	"end":       "End",
+	"pageup":    "Prior",
+	"pagedown":  "Next",
+	"up":        "Up",
+	"down":      "Down",
+	"left":      "Left",
+	"right":     "Right",
+	"plus":      "plus",
+	"minus":     "minus",
+	"comma":     "comma",
+	"period":    "period",
+	"slash":     "slash",
+	"equal":     "equal",
+	",":         "comma",
+	".":         "period",
+	"/":         "slash",
+	"-":         "minus",
+	"=":         "equal",
+}
+
+// the labels of keysyms which differ from the keysym
+var accelerator_labels = map[string]string{
+	"Return": "Enter",
+	"Escape": "Esc",
+	"space":  "Space",
+	"Prior":  "PageUp",
+	"Next":   "PageDown",
+	"plus":   "+",
+	"minus":  "-",
+	"comma":  ",",
+	"period": ".",
+	"slash":  "/",
+	"equal":  "=",
+}
+
+// Parses an accelerator: modifiers (Ctrl, Shift, Alt) and a key (a letter, a
+// digit, F1-F24 or a name from accelerator_keys) joined with "+",
+// case-insensitive. Ctrl stands for Command on macOS.
+func parse_accelerator(s string) (*accelerator, error) {
+	parts := strings.Split(s, "+")
+	// "Ctrl++" means Ctrl+plus
+	if len(parts) > 2 && parts[len(parts)-1] == "" && parts[len(parts)-2] == "" {
+		parts = append(parts[:len(parts)-2], "plus")
+	}
+	a := &accelerator{}
+	for i, part := range parts {
+		p := strings.ToLower(strings.TrimSpace(part))
+		if i < len(parts)-1 {
+			switch p {
+			case "ctrl", "control", "cmd", "command":
+				a.ctrl = true
+			case "shift":
+				a.shift = true
+			case "alt", "option":
+				a.alt = true
+			default:
+				return nil, fmt.Errorf("gothic: unknown modifier %q in accelerator %q", part, s)
+			}
+			continue
+		}
+
+		if k, ok := accelerator_keys[p]; ok {
+			a.key = k
+		} else if len(p) > 1 && p[0] == 'f' && strings.Trim(p[1:], "0123456789") == "" {
+			a.key = "F" + p[1:]
+		} else if len(p) == 1 && (p[0] >= 'a' && p[0] <= 'z' || p[0] >= '0' && p[0] <= '9') {
+			a.key = p
+			if a.shift {
+				a.key = strings.ToUpper(p)
+			}
+		} else {
+			return nil, fmt.Errorf("gothic: unknown key %q in accelerator %q", part, s)
+		}
+	}
+
+	var name []string
+	if a.ctrl {
+		name = append(name, "Ctrl")
+	}
+	if a.alt {
+		name = append(name, "Alt")
+	}
+	if a.shift {
+		name = append(name, "Shift")
+	}
+	a.name = strings.Join(append(name, a.key_label()), "+")
+	return a, nil
+}
+
+func (a *accelerator) key_label() string {
+	if label, ok := accelerator_labels[a.key]; ok {
+		return label
+	}
+	if len(a.key) == 1 {
+		return strings.ToUpper(a.key)
+	}
+	return a.key
+}
+
+// Returns the event pattern for the windowing system `ws` ("x11", "win32" or
+// "aqua").
+func (a *accelerator) pattern(ws string) string {
+	var buf bytes.Buffer
+	buf.WriteString("<")
+	if a.ctrl {
+		if ws == "aqua" {
+			buf.WriteString("Command-")
+		} else {
+			buf.WriteString("Control-")
+		}
+	}
+	if a.alt {
+		if ws == "aqua" {
+			buf.WriteString("Option-")
+		} else {
+			buf.WriteString("Alt-")
+		}
+	}
+	if a.shift {
+		buf.WriteString("Shift-")
+	}
+	buf.WriteString("Key-")
+	buf.WriteString(a.key)
+	buf.WriteString(">")
+	return buf.String()
+}
+
+// Returns the text shown in menus, on macOS Tk turns the "Command-" style
+// into the native symbols.
+func (a *accelerator) label(ws string) string {
+	if ws != "aqua" {
+		return a.name
+	}
+	var buf bytes.Buffer
+	if a.ctrl {
+		buf.WriteString("Command-")
+	}
+	if a.alt {
+		buf.WriteString("Option-")
+	}
+	if a.shift {
+		buf.WriteString("Shift-")
+	}
+	buf.WriteString(a.key_label())
+	return buf.String()
+}
+
+type accelerators struct {
+	ws       string            // windowing system
+	commands map[string]string // canonical name -> command
+	labels   map[string]string // command -> label
+	saved    map[string]string // canonical name -> the binding it replaced
+}
+
+// ::gothic::annotate_menu menu labels
+//
+// Sets -accelerator of the entries of `menu` and its cascades whose -command
+// is a key in the `labels` dict.
+const accelerators_script = `
+proc ::gothic::annotate_menu {menu labels} {
+	set last [$menu index end]
+	if {$last eq "none"} {
+		return
+	}
+	for {set i 0} {$i <= $last} {incr i} {
+		switch -- [$menu type $i] {
+			cascade {
+				set sub [$menu entrycget $i -menu]
+				if {$sub ne ""} {
+					::gothic::annotate_menu $sub $labels
+				}
+			}
+			command - checkbutton - radiobutton {
+				set cmd [$menu entrycget $i -command]
+				if {[dict exists $labels $cmd]} {
+					$menu entryconfigure $i -accelerator [dict get $labels $cmd]
+				}
+			}
+		}
+	}
+}
+`
+
+func (ir *interpreter) init_accelerators() error {
+	if ir.accels != nil {
+		return nil
+	}
+	err := ir.eval([]byte(accelerators_script))
+	if err != nil {
+		return err
+	}
+	var ws string
+	err = ir.eval_as(&ws, []byte("tk windowingsystem"))
+	if err != nil {
+		return err
+	}
+	ir.accels = &accelerators{
+		ws:       ws,
+		commands: make(map[string]string),
+		labels:   make(map[string]string),
+		saved:    make(map[string]string),
+	}
+	return nil
+}
+
+// Binds the accelerator `accel` (e.g. "Ctrl+S", "Ctrl+Shift+Z", "F5",
+// "Alt+Left") to `handler` for all the windows, Ctrl is bound to Command on
+// macOS. Returns the name of the TCL command invoking the handler, when used
+// as the -command of menu entries, AnnotateMenu shows the accelerator next to
+// them, e.g.
+//
+//	cmd, _ := ir.AddAccelerator("Ctrl+S", save)
+//	ir.Eval(".menu.file add command -label Save -command %{%q}", cmd)
+//	ir.AnnotateMenu(".menu")
+//
+// Adding an accelerator again replaces the handler. An existing binding of
+// the application for the same keys is restored by RemoveAccelerator.
+func (ir *Interpreter) AddAccelerator(accel string, handler func()) (string, error) {
+	a, err := parse_accelerator(accel)
+	if err != nil {
+		return "", err
+	}
+	var cmd string
+	err = ir.ir.run(func() error {
+		err := ir.ir.init_accelerators()
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		as := ir.ir.accels
+		pattern := a.pattern(as.ws)
+		if old, ok := as.commands[a.name]; ok {
+			ir.ir.unregister_command(old)
+			delete(as.labels, old)
+		} else {
+			var saved string
+			err := ir.EvalAs(&saved, "bind all %{%q}", pattern)
+			if err != nil {
+				return err
+			}
+			as.saved[a.name] = saved
+		}
+		cmd = ir.ir.next_command("accelerator")
+		err = ir.ir.register_command(cmd, handler)
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		as.commands[a.name] = cmd
+		as.labels[cmd] = a.label(as.ws)
+		return ir.Eval("bind all %{%q} %{%q}", pattern, cmd)
+	})
+	return cmd, err
+}
+
+// Removes the binding of `accel` added with AddAccelerator, restoring the
+// binding it replaced, if any.
+func (ir *Interpreter) RemoveAccelerator(accel string) error {
+	a, err := parse_accelerator(accel)
+	if err != nil {
+		return err
+	}
+	return ir.ir.run(func() error {
+		as := ir.ir.accels
+		if as == nil {
+			return nil
+		}
+		cmd, ok := as.commands[a.name]
+		if !ok {
+			return nil
+		}
+		saved := as.saved[a.name]
+		delete(as.commands, a.name)
+		delete(as.labels, cmd)
+		delete(as.saved, a.name)
+		ir.ir.unregister_command(cmd)
+		return ir.Eval("bind all %{%q} %{%q}", a.pattern(as.ws), saved)
+	})
+}
+
+// Returns the menu label of `accel` on this platform, e.g. "Ctrl+S" or
+// "Command-S" on macOS.
+func (ir *Interpreter) AcceleratorLabel(accel string) (string, error) {
+	a, err := parse_accelerator(accel)
+	if err != nil {
+		return "", err
+	}
+	var label string
+	err = ir.ir.run(func() error {
+		err := ir.ir.init_accelerators()
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		label = a.label(ir.ir.accels.ws)
+		return nil
+	})
+	return label, err
+}
+
+// Sets the -accelerator option of the entries of `menu` (and its cascade
+// menus) whose -command is a command returned by AddAccelerator.
+func (ir *Interpreter) AnnotateMenu(menu string) error {
+	return ir.ir.run(func() error {
+		err := ir.ir.init_accelerators()
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		var labels bytes.Buffer
+		for cmd, label := range ir.ir.accels.labels {
+			quote(&labels, cmd)
+			labels.WriteString(" ")
+			quote(&labels, label)
+			labels.WriteString(" ")
+		}
+		return ir.Eval("::gothic::annotate_menu %{%q} %{%q}", menu, labels.String())
+	})
+}
@@ -0,0 +1,107 @@
+package gothic
+
+import (
+	"testing"
+)
+
+func TestParseAccelerator(t *testing.T) {
+	tests := []struct {
+		accel   string
+		name    string
+		pattern string // x11
+		aqua    string // aqua pattern
+		label   string // aqua label
+	}{
+		{"Ctrl+S", "Ctrl+S", "<Control-Key-s>", "<Command-Key-s>", "Command-S"},
+		{"ctrl+shift+z", "Ctrl+Shift+Z", "<Control-Shift-Key-Z>", "<Command-Shift-Key-Z>", "Command-Shift-Z"},
+		{"F5", "F5", "<Key-F5>", "<Key-F5>", "F5"},
+		{"Alt+Left", "Alt+Left", "<Alt-Key-Left>", "<Option-Key-Left>", "Option-Left"},
+		{"Ctrl++", "Ctrl++", "<Control-Key-plus>", "<Command-Key-plus>", "Command-+"},
+		{"Cmd+PageDown", "Ctrl+PageDown", "<Control-Key-Next>", "<Command-Key-Next>", "Command-PageDown"},
+	}
+	for _, test := range tests {
+		a, err := parse_accelerator(test.accel)
+		if err != nil {
+			t.Errorf("%s: %s", test.accel, err)
+			continue
+		}
+		if a.name != test.name {
+			t.Errorf("%s: name %s != %s", test.accel, a.name, test.name)
+		}
+		if p := a.pattern("x11"); p != test.pattern {
+			t.Errorf("%s: pattern %s != %s", test.accel, p, test.pattern)
+		}
+		if p := a.pattern("aqua"); p != test.aqua {
+			t.Errorf("%s: aqua pattern %s != %s", test.accel, p, test.aqua)
+		}
+		if l := a.label("aqua"); l != test.label {
+			t.Errorf("%s: aqua label %s != %s", test.accel, l, test.label)
+		}
+		if l := a.label("win32"); l != test.name {
+			t.Errorf("%s: label %s != %s", test.accel, l, test.name)
+		}
+	}
+
+	for _, accel := range []string{"Hyper+S", "Ctrl+", "Ctrl+Foo", "Ctrl+!"} {
+		if _, err := parse_accelerator(accel); err == nil {
+			t.Errorf("%s: expected an error", accel)
+		}
+	}
+}
+
+func TestRemoveAccelerator(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		// fake bind and tk commands for the "all" bindtag
+		err := ir.Eval(`
+			array set bindings {}
+			proc bind {tag pattern args} {
+				if {[llength $args] == 0} {
+					if {[info exists ::bindings($pattern)]} {
+						return $::bindings($pattern)
+					}
+					return ""
+				}
+				if {[lindex $args 0] eq ""} {
+					unset -nocomplain ::bindings($pattern)
+				} else {
+					set ::bindings($pattern) [lindex $args 0]
+				}
+			}
+			proc tk {args} {return x11}
+			bind all <Control-Key-s> {app_save}
+		`)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		for i := 0; i < 2; i++ {
+			_, err = ir.AddAccelerator("Ctrl+S", func() {})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		_, err = ir.AddAccelerator("Ctrl+O", func() {})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		for _, accel := range []string{"Ctrl+S", "Ctrl+O"} {
+			err = ir.RemoveAccelerator(accel)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		var s, o string
+		ir.EvalAs(&s, "bind all <Control-Key-s>")
+		ir.EvalAs(&o, "bind all <Control-Key-o>")
+		if s != "app_save" {
+			t.Errorf("the binding of the application wasn't restored: %q", s)
+		}
+		if o != "" {
+			t.Errorf("the binding wasn't removed: %q", o)
+		}
+	})
+}
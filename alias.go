@@ -0,0 +1,50 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Creates the command `cmd` in the slave interpreter `slave` (a path as used
+// by `interp create`, e.g. "sandbox") backed by the Go function `f`, wrapping
+// Tcl_CreateAlias. The function is registered in the master interpreter
+// under an internal name and works the same way as with RegisterCommand, so
+// sandboxed scripts get a curated set of Go-backed commands without access
+// to the master's commands.
+func (ir *Interpreter) Alias(slave, cmd string, f interface{}) error {
+	return ir.ir.run(func() error {
+		return ir.ir.filt(ir.ir.alias(slave, cmd, f))
+	})
+}
+
+func (ir *interpreter) alias(slave, cmd string, f interface{}) error {
+	cslave := C.CString(slave)
+	si := C.Tcl_GetSlave(ir.C, cslave)
+	C.free(unsafe.Pointer(cslave))
+	if si == nil {
+		return fmt.Errorf("gothic: could not find interpreter %q", slave)
+	}
+
+	target := ir.next_command("alias")
+	err := ir.register_command(target, f)
+	if err != nil {
+		return err
+	}
+
+	ccmd := C.CString(cmd)
+	ctarget := C.CString(target)
+	status := C.Tcl_CreateAlias(si, ccmd, ir.C, ctarget, 0, nil)
+	C.free(unsafe.Pointer(ccmd))
+	C.free(unsafe.Pointer(ctarget))
+	if status != C.TCL_OK {
+		var n C.int
+		p := C.Tcl_GetStringFromObj(C.Tcl_GetObjResult(si), &n)
+		ir.unregister_command(target)
+		return fmt.Errorf("gothic: %s", tcl_string_to_go_string(p, n))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+	"strings"
+)
+
+// The version of the scripting API gothic publishes into every interpreter.
+// It is available from TCL as `$::gothic::api(version)` and through the
+// regular package mechanism: `package require gothic 1.0`.
+const APIVersion = "1.0"
+
+// builtin capabilities, extended via Interpreter.ProvideCapability
+var api_capabilities = []string{
+	"eval",
+	"set",
+	"commands",
+	"methods",
+	"images",
+	"futures",
+	"streams",
+}
+
+// ::gothic::RequireAPI version ?capability ...?
+//
+// Fails with a TCL error if the published API doesn't satisfy the `version`
+// (in the `package vsatisfies` sense) or any of the capabilities is missing.
+// Returns the published version otherwise.
+//
+// ::gothic::mc src ?arg ...?
+//
+// Same as `::msgcat::mc` called from the caller's namespace, loads msgcat on
+// first use. Scripts formatted with the T type use it.
+const api_script = `
+namespace eval ::gothic {
+	variable api
+	array set api [list version %{0%q} capabilities %{1%q}]
+
+	proc RequireAPI {version args} {
+		variable api
+		if {![package vsatisfies $api(version) $version]} {
+			return -code error "gothic: API version $api(version) doesn't satisfy the required version $version"
+		}
+		foreach cap $args {
+			if {[lsearch -exact $api(capabilities) $cap] == -1} {
+				return -code error "gothic: API capability \"$cap\" is not available"
+			}
+		}
+		return $api(version)
+	}
+
+	proc mc {args} {
+		if {[info commands ::msgcat::mc] eq ""} {
+			package require msgcat
+		}
+		tailcall ::msgcat::mc {*}$args
+	}
+}
+package provide gothic %{0}
+`
+
+func (ir *interpreter) init_api() error {
+	var buf bytes.Buffer
+	err := sprintf(&buf, api_script, APIVersion, strings.Join(api_capabilities, " "))
+	if err != nil {
+		return err
+	}
+	return ir.eval(buf.Bytes())
+}
+
+func (ir *interpreter) provide_capability(names []string) error {
+	var buf bytes.Buffer
+	buf.WriteString("lappend ::gothic::api(capabilities)")
+	for _, name := range names {
+		buf.WriteString(" ")
+		quote(&buf, name)
+	}
+	return ir.eval(buf.Bytes())
+}
+
+// Adds `names` to the list of capabilities published in
+// `$::gothic::api(capabilities)`. Applications and Go plugins use it to
+// announce the commands they register, so that TCL modules can check for them
+// with `::gothic::RequireAPI`.
+func (ir *Interpreter) ProvideCapability(names ...string) error {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return ir.ir.filt(ir.ir.provide_capability(names))
+	}
+	return ir.ir.run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.provide_capability(names))
+	})
+}
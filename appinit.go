@@ -0,0 +1,57 @@
+package gothic
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// A function initializing statically linked C extensions, called on the
+// interpreter thread right after Tcl_Init and Tk_Init (unless the
+// interpreter is created without Tk), before any script runs. The `interp`
+// argument is the *Tcl_Interp, cgo code converts it to its own C type:
+//
+//	/*
+//	#include <tcl.h>
+//	int Sqlite3_Init(Tcl_Interp *interp);
+//	*/
+//	import "C"
+//
+//	func init() {
+//	        gothic.RegisterAppInit(func(interp unsafe.Pointer) error {
+//	                if C.Sqlite3_Init((*C.Tcl_Interp)(interp)) != C.TCL_OK {
+//	                        return errors.New("sqlite3 initialization failed")
+//	                }
+//	                return nil
+//	        })
+//	}
+type AppInitFunc func(interp unsafe.Pointer) error
+
+var app_inits struct {
+	sync.Mutex
+	funcs []AppInitFunc
+}
+
+// Registers the function `f` to be called for every interpreter created
+// afterwards, see AppInitFunc. Functions are called in the registration order.
+func RegisterAppInit(f AppInitFunc) {
+	app_inits.Lock()
+	app_inits.funcs = append(app_inits.funcs, f)
+	app_inits.Unlock()
+}
+
+func (ir *interpreter) app_init(extra AppInitFunc) error {
+	app_inits.Lock()
+	funcs := append([]AppInitFunc(nil), app_inits.funcs...)
+	app_inits.Unlock()
+	if extra != nil {
+		funcs = append(funcs, extra)
+	}
+
+	for _, f := range funcs {
+		err := f(unsafe.Pointer(ir.C))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+
+// A script for Interpreter.EvalBatch, Format and Args have the same meaning
+// as the arguments of Interpreter.Eval.
+type Script struct {
+	Format string
+	Args   []interface{}
+}
+
+// A shortcut for Script{format, args}.
+func NewScript(format string, args ...interface{}) Script {
+	return Script{Format: format, Args: args}
+}
+
+// Evaluates multiple scripts back-to-back in a single trip to the interpreter
+// thread. Returns a slice of errors, one per script, nil entries mean success.
+// A script failing doesn't prevent the following scripts from being
+// evaluated. If the batch can't be run (e.g. ErrInterpreterClosed or a
+// *WaitTimeoutError), that error is returned for every script.
+func (ir *Interpreter) EvalBatch(scripts ...Script) []error {
+	errs := make([]error, len(scripts))
+	buf := buffer_pool.get()
+	ends := make([]int, len(scripts))
+	for i, s := range scripts {
+		err := sprintf(&buf, s.Format, s.Args...)
+		if err != nil {
+			errs[i] = ir.ir.filt(err)
+		}
+		ends[i] = buf.Len()
+	}
+
+	ran := 0 // the scripts run so far
+	run := func() error {
+		data := buf.Bytes()
+		offset := 0
+		for i := range scripts {
+			script := data[offset:ends[i]]
+			offset = ends[i]
+			ran = i + 1
+			if errs[i] != nil {
+				continue
+			}
+			errs[i] = ir.ir.filt(ir.ir.eval(script))
+		}
+		return nil
+	}
+
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		run()
+	} else if err := ir.ir.run_and_wait(run); err != nil {
+		for i := ran; i < len(errs); i++ {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+	}
+	buffer_pool.put(buf)
+	return errs
+}
@@ -0,0 +1,87 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"sort"
+	"time"
+)
+
+// The latency distribution of the round trips measured by
+// Interpreter.BenchRoundTrip.
+type LatencyStats struct {
+	N    int           // round trips measured
+	Min  time.Duration // the whole round trip: queue, execute, wake
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+
+	// The mean time between queuing an action and its execution on the
+	// interpreter thread (the event loop latency), and between the execution
+	// and the wake up of the caller.
+	QueueMean time.Duration
+	WakeMean  time.Duration
+}
+
+// Measures the latency of `n` round trips through the async queue: an empty
+// action is queued, executed on the interpreter thread and the caller is
+// woken up. The round trips go one after another and compete with the
+// current load, a growing QueueMean means the event loop is saturated.
+// Must be called from a thread other than the interpreter thread (the
+// result is zero otherwise) and not from a command callback.
+func (ir *Interpreter) BenchRoundTrip(n int) LatencyStats {
+	if n <= 0 || C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return LatencyStats{}
+	}
+	trips := make([]time.Duration, 0, n)
+	var queue, wake time.Duration
+	for i := 0; i < n; i++ {
+		var executed time.Time
+		start := time.Now()
+		err := ir.ir.run_and_wait(func() error {
+			executed = time.Now()
+			return nil
+		})
+		if err != nil {
+			break
+		}
+		end := time.Now()
+		trips = append(trips, end.Sub(start))
+		queue += executed.Sub(start)
+		wake += end.Sub(executed)
+	}
+	st := latency_stats(trips)
+	if st.N != 0 {
+		st.QueueMean = queue / time.Duration(st.N)
+		st.WakeMean = wake / time.Duration(st.N)
+	}
+	return st
+}
+
+// computes the distribution of `trips`, sorts it in place
+func latency_stats(trips []time.Duration) LatencyStats {
+	if len(trips) == 0 {
+		return LatencyStats{}
+	}
+	sort.Slice(trips, func(i, j int) bool { return trips[i] < trips[j] })
+	var total time.Duration
+	for _, t := range trips {
+		total += t
+	}
+	percentile := func(p int) time.Duration {
+		return trips[(len(trips)-1)*p/100]
+	}
+	return LatencyStats{
+		N:    len(trips),
+		Min:  trips[0],
+		Mean: total / time.Duration(len(trips)),
+		P50:  percentile(50),
+		P90:  percentile(90),
+		P99:  percentile(99),
+		Max:  trips[len(trips)-1],
+	}
+}
@@ -0,0 +1,30 @@
+package gothic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyStats(t *testing.T) {
+	trips := make([]time.Duration, 100)
+	for i := range trips {
+		// 100..1 ms, reversed
+		trips[i] = time.Duration(100-i) * time.Millisecond
+	}
+	st := latency_stats(trips)
+	expected := LatencyStats{
+		N:    100,
+		Min:  1 * time.Millisecond,
+		Mean: 50500 * time.Microsecond,
+		P50:  50 * time.Millisecond,
+		P90:  90 * time.Millisecond,
+		P99:  99 * time.Millisecond,
+		Max:  100 * time.Millisecond,
+	}
+	if st != expected {
+		t.Errorf("expected %+v, got %+v", expected, st)
+	}
+	if st := latency_stats(nil); st != (LatencyStats{}) {
+		t.Errorf("expected zero stats, got %+v", st)
+	}
+}
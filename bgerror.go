@@ -0,0 +1,72 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+)
+
+// A TCL error along with the additional information TCL provides about it.
+type TclError struct {
+	Msg       string
+	ErrorInfo string `tcl:"errorinfo"` // stack trace, the value of $::errorInfo
+	ErrorCode string `tcl:"errorcode"` // the value of $::errorCode
+}
+
+func (e *TclError) Error() string {
+	return e.Msg
+}
+
+const bgerror_command = "::gothic::bgerror"
+
+// Routes errors raised from events, timers and bindings (which TCL reports
+// via `interp bgerror`) to `handler` instead of the default bgerror dialog.
+// The handler is invoked on the interpreter thread. If you pass nil, then the
+// previously installed TCL handler is restored.
+func (ir *Interpreter) OnBackgroundError(handler func(*TclError)) error {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return ir.ir.filt(ir.ir.on_background_error(handler))
+	}
+	return ir.ir.run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.on_background_error(handler))
+	})
+}
+
+func (ir *interpreter) on_background_error(handler func(*TclError)) error {
+	if handler == nil {
+		if ir.bgerror == nil {
+			return nil
+		}
+		ir.bgerror = nil
+		var buf bytes.Buffer
+		err := sprintf(&buf, "interp bgerror {} %{%q}", ir.bgerror_prev)
+		if err != nil {
+			return err
+		}
+		return ir.eval(buf.Bytes())
+	}
+
+	if ir.bgerror == nil {
+		err := ir.eval([]byte("interp bgerror {}"))
+		if err != nil {
+			return err
+		}
+		ir.bgerror_prev = tcl_obj_to_go_string(C.Tcl_GetObjResult(ir.C))
+	}
+	ir.bgerror = handler
+
+	if _, ok := ir.commands[bgerror_command]; !ok {
+		err := ir.register_command(bgerror_command, func(msg string, opts TclError) {
+			opts.Msg = msg
+			if ir.bgerror != nil {
+				ir.bgerror(&opts)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return ir.eval([]byte("interp bgerror {} " + bgerror_command))
+}
@@ -0,0 +1,167 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// A two-way binding between a Go struct and a TCL array variable, see
+// Interpreter.BindStruct.
+//
+// Tk-side edits modify the struct on the interpreter thread while holding the
+// binding's lock, other goroutines accessing the struct should hold it too.
+type Binding struct {
+	sync.Mutex
+
+	ir       *Interpreter
+	name     string
+	cmd      string
+	val      reflect.Value
+	fields   map[string]int // element name -> field index
+	onchange func(field string)
+
+	// set while Refresh writes variables, to ignore our own writes
+	refreshing bool
+}
+
+// Binds the exported fields of the struct `ptr` points to, which have a
+// `tcl:"key"` tag, to the elements of the global TCL array variable `name`.
+// The array is initialized from the struct, use Binding.Var to get element
+// names for -variable and -textvariable widget options.
+//
+// Changes made to the struct from Go are pushed to TCL by Binding.Refresh,
+// changes made on the TCL side (e.g. by the user typing into an entry) are
+// applied to the struct immediately and reported to the OnChange callback.
+func (ir *Interpreter) BindStruct(name string, ptr interface{}) (*Binding, error) {
+	pv := reflect.ValueOf(ptr)
+	if pv.Kind() != reflect.Ptr || pv.IsNil() || pv.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("gothic: BindStruct expected a non-nil pointer to a struct")
+	}
+
+	b := &Binding{
+		ir:     ir,
+		name:   "::" + strings.TrimPrefix(name, "::"),
+		cmd:    "::gothic::binding_" + strings.Replace(strings.TrimPrefix(name, "::"), "::", "_", -1),
+		val:    pv.Elem(),
+		fields: make(map[string]int),
+	}
+	t := b.val.Type()
+	for i, n := 0, t.NumField(); i < n; i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("tcl")
+		if f.PkgPath != "" || tag == "" || tag == "-" {
+			continue
+		}
+		b.fields[tag] = i
+	}
+
+	err := ir.ir.run(func() error {
+		return ir.ir.filt(b.bind())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Binding) bind() error {
+	err := b.refresh()
+	if err != nil {
+		return err
+	}
+	err = b.ir.ir.register_command(b.cmd, b.trace)
+	if err != nil {
+		return err
+	}
+	return b.ir.Eval("trace add variable %{%q} write %{%q}", b.name, b.cmd)
+}
+
+func (b *Binding) trace(name1, name2, op string) {
+	if b.refreshing {
+		return
+	}
+	i, ok := b.fields[name2]
+	if !ok {
+		return
+	}
+
+	ir := b.ir.ir
+	cname := C.CString(b.name)
+	ckey := C.CString(name2)
+	obj := C.Tcl_GetVar2Ex(ir.C, cname, ckey, C.TCL_GLOBAL_ONLY)
+	C.free(unsafe.Pointer(cname))
+	C.free(unsafe.Pointer(ckey))
+	if obj == nil {
+		return
+	}
+
+	b.Lock()
+	err := ir.tcl_obj_to_go_value(obj, b.val.Field(i))
+	b.Unlock()
+	if err != nil {
+		// the value doesn't fit the field, the struct keeps the old one
+		return
+	}
+	if b.onchange != nil {
+		b.onchange(b.val.Type().Field(i).Name)
+	}
+}
+
+func (b *Binding) refresh() error {
+	b.refreshing = true
+	defer func() { b.refreshing = false }()
+
+	b.Lock()
+	defer b.Unlock()
+	cname := C.CString(b.name)
+	defer C.free(unsafe.Pointer(cname))
+	for key, i := range b.fields {
+		ckey := C.CString(key)
+		err := b.ir.ir.set_element(cname, ckey, b.val.Field(i).Interface(), GlobalOnly)
+		C.free(unsafe.Pointer(ckey))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the name of the TCL variable bound to the field with the `tcl:"key"`
+// tag, suitable for -variable and -textvariable widget options.
+func (b *Binding) Var(key string) string {
+	return b.name + "(" + key + ")"
+}
+
+// Pushes the current values of the struct fields to TCL.
+func (b *Binding) Refresh() error {
+	return b.ir.ir.run(func() error {
+		return b.ir.ir.filt(b.refresh())
+	})
+}
+
+// Sets the function invoked on the interpreter thread with the Go name of the
+// field after a TCL-side change was applied to it.
+func (b *Binding) OnChange(f func(field string)) {
+	b.ir.ir.run(func() error {
+		b.onchange = f
+		return nil
+	})
+}
+
+// Removes the binding, TCL variables stay intact.
+func (b *Binding) Unbind() error {
+	return b.ir.ir.run(func() error {
+		err := b.ir.Eval("trace remove variable %{%q} write %{%q}", b.name, b.cmd)
+		if err != nil {
+			return err
+		}
+		return b.ir.ir.filt(b.ir.ir.unregister_command(b.cmd))
+	})
+}
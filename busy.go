@@ -0,0 +1,41 @@
+package gothic
+
+import (
+	"sync"
+)
+
+// Makes the `window` and its descendants busy (wrapping `tk busy hold`): user
+// interaction with them is blocked until `release` is called. Useful for
+// blocking a window while a long Go operation is running:
+//
+//	release, err := ir.Busy(".")
+//	if err != nil {
+//	        return err
+//	}
+//	defer release()
+//
+// The `release` function can be called from any goroutine, repeated calls
+// are no-ops.
+func (ir *Interpreter) Busy(window string) (release func(), err error) {
+	return ir.BusyWithCursor(window, "")
+}
+
+// Works exactly as Busy, but shows the `cursor` (e.g. "watch") over the busy
+// window. Empty cursor means the platform's default busy cursor.
+func (ir *Interpreter) BusyWithCursor(window, cursor string) (release func(), err error) {
+	if cursor == "" {
+		err = ir.Eval("tk busy hold %{%q}; update idletasks", window)
+	} else {
+		err = ir.Eval("tk busy hold %{%q} -cursor %{%q}; update idletasks", window, cursor)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ir.Eval("if {[winfo exists %{0%q}]} {tk busy forget %{0%q}}", window)
+		})
+	}, nil
+}
@@ -0,0 +1,119 @@
+package gothic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// A handle to an existing canvas widget, see Interpreter.Canvas.
+type Canvas struct {
+	ir   *Interpreter
+	path string
+}
+
+// Returns a handle to the canvas widget `path`, the widget is not checked
+// for existence.
+func (ir *Interpreter) Canvas(path string) *Canvas {
+	return &Canvas{ir: ir, path: path}
+}
+
+// Returns the path of the canvas widget.
+func (c *Canvas) Path() string {
+	return c.path
+}
+
+// Options for Canvas.ExportPostScript, empty fields are left at their
+// defaults. Distances use the Tk screen distance syntax, e.g. "10c" or
+// "210m".
+type PostScriptOpts struct {
+	// the area of the canvas to print, the visible area by default
+	X      string `tcl:"x"`
+	Y      string `tcl:"y"`
+	Width  string `tcl:"width"`
+	Height string `tcl:"height"`
+
+	// "color", "gray" or "mono"
+	ColorMode string `tcl:"colormode"`
+
+	// rotate to landscape orientation
+	Rotate bool `tcl:"rotate"`
+
+	// the placement of the printed area on the page
+	PageAnchor string `tcl:"pageanchor"`
+	PageX      string `tcl:"pagex"`
+	PageY      string `tcl:"pagey"`
+	PageWidth  string `tcl:"pagewidth"`
+	PageHeight string `tcl:"pageheight"`
+
+	// additional `postscript` options
+	Extra map[string]interface{}
+}
+
+// Writes the PostScript rendering of the canvas to `w`, `opts` can be
+// omitted. The output is passed through a TCL channel, no temporary files
+// are involved. Pending idle tasks are processed first, so that the items
+// are laid out.
+func (c *Canvas) ExportPostScript(w io.Writer, opts ...PostScriptOpts) error {
+	var o PostScriptOpts
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return c.ir.ir.run(func() error {
+		return c.ir.ir.filt(c.ir.ir.canvas_postscript(c.path, w, &o))
+	})
+}
+
+func (ir *interpreter) canvas_postscript(path string, w io.Writer, opts *PostScriptOpts) error {
+	wc := ir.new_writer_channel(w)
+	var buf bytes.Buffer
+	buf.WriteString("update idletasks\n")
+	quote(&buf, path)
+	buf.WriteString(" postscript -channel ")
+	quote(&buf, wc.name)
+	write_struct_options(&buf, opts)
+	err := ir.eval(buf.Bytes())
+	werr := wc.close()
+	if err != nil {
+		return err
+	}
+	return werr
+}
+
+// Converts PostScript produced by the canvas to PDF, used by
+// Canvas.ExportPDF. It can be replaced to use a different tool or library,
+// by default it runs the `ps2pdf` script from Ghostscript.
+var PDFConverter func(pdf io.Writer, ps io.Reader) error = ps2pdf
+
+func ps2pdf(pdf io.Writer, ps io.Reader) error {
+	var stderr bytes.Buffer
+	// -dEPSCrop makes the page fit the printed area
+	cmd := exec.Command("ps2pdf", "-dEPSCrop", "-", "-")
+	cmd.Stdin = ps
+	cmd.Stdout = pdf
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return errors.New("gothic: PDF export requires ps2pdf (Ghostscript) or a custom PDFConverter")
+	}
+	if err != nil && stderr.Len() > 0 {
+		return fmt.Errorf("gothic: ps2pdf: %s", strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// Writes the canvas as a PDF document to `w`, the options are the same as
+// for ExportPostScript. The canvas is rendered to PostScript on the
+// interpreter thread, the conversion (see PDFConverter) runs in the calling
+// goroutine.
+func (c *Canvas) ExportPDF(w io.Writer, opts ...PostScriptOpts) error {
+	var ps bytes.Buffer
+	err := c.ExportPostScript(&ps, opts...)
+	if err != nil {
+		return err
+	}
+	return PDFConverter(w, &ps)
+}
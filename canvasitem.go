@@ -0,0 +1,137 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"errors"
+	"image"
+	"sort"
+	"sync"
+)
+
+// A canvas item type implemented in Go, see RegisterCanvasItemType. The items
+// are rectangles given by two points, like canvas rectangles, their content
+// is drawn by Go, e.g.
+//
+//	gothic.RegisterCanvasItemType(gothic.CanvasItemType{
+//		Name:    "heatmap",
+//		Options: map[string]string{"-data": ""},
+//		Draw: func(item *gothic.CanvasItem, w, h int) image.Image {
+//			return render_heatmap(item.Option("-data"), w, h)
+//		},
+//	})
+//
+//	.c create heatmap 10 10 200 100 -data $samples -tags tile
+type CanvasItemType struct {
+	// The name used with `$canvas create`.
+	Name string
+
+	// The options of the items (with the leading dash) and their default
+	// values. The `-tags` option is always supported.
+	Options map[string]string
+
+	// Renders the item, the image is placed at the top-left corner of the
+	// item. Called when the item is displayed for the first time and after
+	// it's configured or resized, the result is cached in between.
+	Draw func(item *CanvasItem, width, height int) image.Image
+}
+
+// A canvas item of a type registered with RegisterCanvasItemType, valid only
+// during the Draw call.
+type CanvasItem struct {
+	typ  *canvas_item_type
+	item *C.GoTkItem
+}
+
+type canvas_item_type struct {
+	CanvasItemType
+	options []string
+}
+
+var canvas_item_types struct {
+	sync.RWMutex
+	list []*canvas_item_type
+}
+
+// Registers a canvas item type implemented in Go. The types are global to all
+// the interpreters and Tk keeps them forever, so it should be called once,
+// before the interpreters are created, e.g. in an init function. Up to 16
+// item types with up to 32 options each can be registered.
+func RegisterCanvasItemType(t CanvasItemType) error {
+	if t.Name == "" || t.Draw == nil {
+		return errors.New("gothic: canvas item type needs Name and Draw")
+	}
+	if len(t.Options) > C.GOTHIC_ITEM_OPTIONS {
+		return errors.New("gothic: too many canvas item options")
+	}
+	options := make([]string, 0, len(t.Options))
+	for name := range t.Options {
+		if len(name) < 2 || name[0] != '-' || name == "-tags" {
+			return errors.New("gothic: invalid canvas item option: " + name)
+		}
+		options = append(options, name)
+	}
+	sort.Strings(options)
+
+	err := load_tk()
+	if err != nil {
+		return err
+	}
+
+	canvas_item_types.Lock()
+	defer canvas_item_types.Unlock()
+	slot := len(canvas_item_types.list)
+	if slot >= C.GOTHIC_ITEM_TYPES {
+		return errors.New("gothic: too many canvas item types")
+	}
+	// the strings are never freed, Tk keeps them
+	C._gotk_c_item_type_init(C.int(slot), C.CString(t.Name), C.int(len(options)))
+	for i, name := range options {
+		C._gotk_c_item_type_option(C.int(slot), C.int(i), C.CString(name), C.CString(t.Options[name]))
+	}
+	C._gotk_c_item_type_register(C.int(slot))
+	canvas_item_types.list = append(canvas_item_types.list, &canvas_item_type{t, options})
+	return nil
+}
+
+// Returns the item id.
+func (item *CanvasItem) ID() int {
+	return int(item.item.header.id)
+}
+
+// Returns the path name of the canvas.
+func (item *CanvasItem) Canvas() string {
+	return C.GoString(C._gotk_c_item_canvas(item.item))
+}
+
+// Returns the current value of the option `name` (with the leading dash).
+func (item *CanvasItem) Option(name string) string {
+	for i, opt := range item.typ.options {
+		if opt == name {
+			return C.GoString(item.item.values[i])
+		}
+	}
+	return ""
+}
+
+// Returns the item coordinates: x1 y1 x2 y2 as given to the canvas.
+func (item *CanvasItem) Coords() [4]float64 {
+	c := item.item.coords
+	return [4]float64{float64(c[0]), float64(c[1]), float64(c[2]), float64(c[3])}
+}
+
+//export _gotk_go_item_draw
+func _gotk_go_item_draw(slot C.int, item *C.GoTkItem, handle C.Tk_PhotoHandle) C.int {
+	canvas_item_types.RLock()
+	t := canvas_item_types.list[slot]
+	canvas_item_types.RUnlock()
+
+	w, h := int(item.width), int(item.height)
+	img := t.Draw(&CanvasItem{t, item}, w, h)
+	if img == nil {
+		return C.TCL_OK
+	}
+	return photo_put_image(item.interp, handle, img, img.Bounds().Min, 0, 0, w, h)
+}
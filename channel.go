@@ -0,0 +1,75 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"io"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// TCL channels writing to Go io.Writers, used to capture the output of
+// commands like `canvas postscript -channel` without temporary files.
+
+type writer_channel struct {
+	ir   *interpreter
+	id   int
+	ch   C.Tcl_Channel
+	name string
+	w    io.Writer
+	err  error // the first write error
+}
+
+var writer_channels struct {
+	sync.Mutex
+	lastid int
+	m      map[int]*writer_channel
+}
+
+// Creates a channel writing to `w` and registers it in the interpreter, it
+// must be closed with close. Must be called on the interpreter thread.
+func (ir *interpreter) new_writer_channel(w io.Writer) *writer_channel {
+	writer_channels.Lock()
+	if writer_channels.m == nil {
+		writer_channels.m = make(map[int]*writer_channel)
+	}
+	writer_channels.lastid++
+	wc := &writer_channel{ir: ir, id: writer_channels.lastid, w: w}
+	writer_channels.m[wc.id] = wc
+	writer_channels.Unlock()
+
+	wc.ch = C._gotk_c_writer_channel(ir.C, C.int(wc.id))
+	wc.name = C.GoString(C.Tcl_GetChannelName(wc.ch))
+	return wc
+}
+
+// Flushes and closes the channel, returns the first write error. The channel
+// stays valid until then, even if a script closed it.
+func (wc *writer_channel) close() error {
+	C._gotk_c_close_writer_channel(wc.ir.C, wc.ch)
+	writer_channels.Lock()
+	delete(writer_channels.m, wc.id)
+	writer_channels.Unlock()
+	return wc.err
+}
+
+//export _gotk_go_writer_output
+func _gotk_go_writer_output(id C.int, buf *C.char, n C.int, errno *C.int) C.int {
+	writer_channels.Lock()
+	wc := writer_channels.m[int(id)]
+	writer_channels.Unlock()
+	if wc == nil || wc.err != nil {
+		*errno = C.int(syscall.EIO)
+		return -1
+	}
+	_, err := wc.w.Write(unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(n)))
+	if err != nil {
+		wc.err = err
+		*errno = C.int(syscall.EIO)
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,64 @@
+package gothic
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type failing_writer struct{}
+
+func (failing_writer) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriterChannel(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		var buf bytes.Buffer
+		wc := ir.ir.new_writer_channel(&buf)
+		err := ir.Eval(`puts -nonewline %{%q} "hello, world"`, wc.name)
+		if err != nil {
+			t.Error(err)
+		}
+		err = wc.close()
+		if err != nil {
+			t.Error(err)
+		} else if s := buf.String(); s != "hello, world" {
+			t.Errorf("%q != %q", s, "hello, world")
+		}
+
+		var s string
+		err = ir.EvalAs(&s, "file channels gothic-writer*")
+		if err != nil {
+			t.Error(err)
+		} else if s != "" {
+			t.Errorf("channel %s is still open", s)
+		}
+
+		// closed by the script, the data is still flushed
+		buf.Reset()
+		wc = ir.ir.new_writer_channel(&buf)
+		err = ir.Eval(`puts -nonewline %{0%q} data; close %{0%q}`, wc.name)
+		if err != nil {
+			t.Error(err)
+		}
+		err = wc.close()
+		if err != nil {
+			t.Error(err)
+		} else if s := buf.String(); s != "data" {
+			t.Errorf("%q != %q", s, "data")
+		}
+		ir.EvalAs(&s, "file channels gothic-writer*")
+		if s != "" {
+			t.Errorf("channel %s is still open", s)
+		}
+
+		wc = ir.ir.new_writer_channel(failing_writer{})
+		ir.Eval(`puts %{%q} data`, wc.name)
+		err = wc.close()
+		if err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Errorf("expected the write error, got %v", err)
+		}
+	})
+}
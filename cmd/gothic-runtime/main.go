@@ -0,0 +1,108 @@
+// Copies the TCL and Tk script libraries of the local installation into a
+// directory, to be embedded into an application and used as
+// gothic.RuntimeFS:
+//
+//	gothic-runtime [-tk dir] [dest]
+//
+// The destination defaults to "tcltk" and is replaced if it exists. The Tk
+// library is looked up next to the TCL one, use -tk (or $TK_LIBRARY) if it's
+// installed elsewhere.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nsf/gothic"
+)
+
+func main() {
+	tk := flag.String("tk", os.Getenv("TK_LIBRARY"), "Tk library directory")
+	flag.Parse()
+	dest := "tcltk"
+	if flag.NArg() > 0 {
+		dest = flag.Arg(0)
+	}
+
+	err := run(dest, *tk)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(dest, tk string) error {
+	var tcl, version string
+	gothic.NewTclInterpreter(func(ir *gothic.Interpreter) {
+		ir.EvalAs(&tcl, "info library")
+		ir.EvalAs(&version, "info tclversion")
+	})
+
+	if tk == "" {
+		tk = filepath.Join(filepath.Dir(tcl), "tk"+version)
+	}
+	for _, file := range []string{filepath.Join(tcl, "init.tcl"), filepath.Join(tk, "tk.tcl")} {
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+	}
+
+	err := os.RemoveAll(dest)
+	if err != nil {
+		return err
+	}
+	err = copy_dir(tcl, filepath.Join(dest, filepath.Base(tcl)))
+	if err != nil {
+		return err
+	}
+	return copy_dir(tk, filepath.Join(dest, "tk"+version))
+}
+
+// Symbolic links are followed, go:embed doesn't store them.
+func copy_dir(src, dst string) error {
+	err := os.MkdirAll(dst, 0755)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		from := filepath.Join(src, e.Name())
+		to := filepath.Join(dst, e.Name())
+		fi, err := os.Stat(from)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			err = copy_dir(from, to)
+		} else {
+			err = copy_file(from, to)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copy_file(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,18 @@
+// A wish-like shell with all gothic commands preregistered and Go plugins
+// loadable via the -plugin flag. See gothic.Shell for details.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nsf/gothic"
+)
+
+func main() {
+	err := gothic.Run(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
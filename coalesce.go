@@ -0,0 +1,65 @@
+package gothic
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttles UI updates coming faster than the screen can show them, e.g.
+// from a 1 kHz data source: of the closures set during an interval only the
+// most recent one runs, on the interpreter thread. See
+// Interpreter.NewCoalescer.
+type Coalescer struct {
+	ir       *Interpreter
+	interval time.Duration
+
+	mu        sync.Mutex
+	pending   func(*Interpreter)
+	scheduled bool
+	last      time.Time // when the last closure was taken to run
+}
+
+// Returns a Coalescer running at most one closure per `interval`, 16ms
+// matches a 60Hz display.
+func (ir *Interpreter) NewCoalescer(interval time.Duration) *Coalescer {
+	return &Coalescer{ir: ir, interval: interval}
+}
+
+// Sets the closure to run on the interpreter thread, replacing the one
+// which hasn't run yet. The closure runs right away if none has run during
+// the last interval, otherwise once the interval has passed. Never blocks,
+// can be called from any goroutine.
+func (c *Coalescer) Set(f func(*Interpreter)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = f
+	if c.scheduled {
+		return
+	}
+	c.scheduled = true
+	time.AfterFunc(time.Until(c.last.Add(c.interval)), c.fire)
+}
+
+// Drops the closure which hasn't run yet, if any.
+func (c *Coalescer) Cancel() {
+	c.mu.Lock()
+	c.pending = nil
+	c.mu.Unlock()
+}
+
+func (c *Coalescer) fire() {
+	c.mu.Lock()
+	f := c.pending
+	c.pending = nil
+	c.scheduled = false
+	c.last = time.Now()
+	c.mu.Unlock()
+	if f == nil {
+		return
+	}
+	// the closures set meanwhile schedule the next run
+	c.ir.ir.run_and_wait(func() error {
+		f(c.ir)
+		return nil
+	})
+}
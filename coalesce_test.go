@@ -0,0 +1,44 @@
+package gothic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Quit()
+
+	c := ir.NewCoalescer(20 * time.Millisecond)
+	var got []int
+	last := make(chan struct{})
+	start := time.Now()
+	for i := 1; i <= 100; i++ {
+		v := i
+		c.Set(func(ir *Interpreter) {
+			got = append(got, v)
+			if v == 100 {
+				close(last)
+			}
+		})
+		time.Sleep(100 * time.Microsecond)
+	}
+	select {
+	case <-last:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the last closure didn't run")
+	}
+	// one run per interval, plus the leading one
+	if max := int(time.Since(start)/(20*time.Millisecond)) + 2; len(got) > max {
+		t.Errorf("closures weren't coalesced, expected at most %d runs: %v", max, got)
+	}
+
+	c.Set(func(ir *Interpreter) {
+		t.Error("canceled closure ran")
+	})
+	c.Cancel()
+	time.Sleep(50 * time.Millisecond)
+}
@@ -0,0 +1,52 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"errors"
+	"image/color"
+	"unsafe"
+
+	"github.com/nsf/gothic/internal/script"
+)
+
+// Parses a Tk color: a name ("LightSteelBlue2"), "#rgb", "#rrggbb",
+// "#rrrgggbbb" or "#rrrrggggbbbb", or a system color name. The result is an
+// opaque color.RGBA64. Requires Tk.
+func (ir *Interpreter) ParseColor(name string) (color.Color, error) {
+	var c color.Color
+	err := ir.ir.run(func() error {
+		var err error
+		c, err = ir.ir.parse_color(name)
+		return ir.ir.filt(err)
+	})
+	return c, err
+}
+
+func (ir *interpreter) parse_color(name string) (color.Color, error) {
+	if ir.notk {
+		return nil, errors.New("gothic: ParseColor requires Tk")
+	}
+	var rgb [3]C.ushort
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	if C._gotk_c_parse_color(ir.C, cname, &rgb[0]) != C.TCL_OK {
+		return nil, ir.result_error()
+	}
+	return color.RGBA64{uint16(rgb[0]), uint16(rgb[1]), uint16(rgb[2]), 0xffff}, nil
+}
+
+// Formats a color the way Tk options expect it: "#rrggbb". Tk colors are
+// opaque, the color components are taken without alpha premultiplication
+// and a fully transparent color becomes "", which means "no color" for
+// options like the -fill of canvas items.
+//
+// Values implementing color.Color are formatted with FormatColor when passed
+// to Interpreter.Eval and friends and as widget options, e.g.
+//
+//	ir.Eval(".c itemconfigure %{%q} -fill %{%q}", item, img.At(x, y))
+func FormatColor(c color.Color) string {
+	return script.FormatColor(c)
+}
@@ -0,0 +1,41 @@
+package gothic
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+func TestFormatColor(t *testing.T) {
+	tests := []struct {
+		c    color.Color
+		gold string
+	}{
+		{color.Black, "#000000"},
+		{color.RGBA{0xb9, 0xd3, 0xee, 0xff}, "#b9d3ee"},
+		{color.RGBA64{0xb9b9, 0xd3d3, 0xeeee, 0xffff}, "#b9d3ee"},
+		// premultiplied half-transparent red
+		{color.RGBA{0x80, 0, 0, 0x80}, "#ff0000"},
+		{color.NRGBA{0x12, 0x34, 0x56, 0x80}, "#123456"},
+		{color.Transparent, ""},
+		{color.Gray{0x7f}, "#7f7f7f"},
+	}
+	for _, test := range tests {
+		if s := FormatColor(test.c); s != test.gold {
+			t.Errorf("%v: %s != %s", test.c, s, test.gold)
+		}
+	}
+
+	s, err := Sprintf("-fill %{%q} -outline %{}", color.White, color.NRGBA{1, 2, 3, 255})
+	if err != nil {
+		t.Error(err)
+	} else if gold := `-fill "#ffffff" -outline #010203`; s != gold {
+		t.Errorf("%s != %s", s, gold)
+	}
+
+	var buf bytes.Buffer
+	write_option(&buf, "background", color.Black)
+	if gold := ` -background "#000000"`; buf.String() != gold {
+		t.Errorf("%s != %s", buf.String(), gold)
+	}
+}
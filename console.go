@@ -0,0 +1,93 @@
+package gothic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Starts a console reading TCL commands from `r` line by line (commands can
+// span several lines) and evaluating them on the interpreter thread. Results
+// and errors are written to `w`, along with the "% " prompt. The console stops
+// at the end of the input or when the interpreter exits. A read in progress is
+// interrupted when it stops if `r` has a SetReadDeadline method (e.g. a
+// net.Conn or a pipe from os.Pipe), otherwise the goroutine reading `r` stays
+// blocked until the next line or the end of the input. Useful for poking at a
+// live UI during debugging:
+//
+//	ir.StartConsole(os.Stdin, os.Stdout)
+func (ir *Interpreter) StartConsole(r io.Reader, w io.Writer) {
+	go console(ir, r, w, w)
+}
+
+// runs the read-eval-print loop, `eof` is true if it was stopped by the end of
+// the input rather than by the exit of the interpreter
+func console(ir *Interpreter, stdin io.Reader, stdout, stderr io.Writer) (eof bool, err error) {
+	lines := make(chan string)
+	readerr := make(chan error, 1)
+	quit := make(chan struct{})
+	defer close(quit)
+	if d, ok := stdin.(interface{ SetReadDeadline(time.Time) error }); ok {
+		// interrupts the read in progress when the console stops
+		defer d.SetReadDeadline(time.Now())
+	}
+	go func() {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-quit:
+				return
+			}
+		}
+		readerr <- scanner.Err()
+		close(lines)
+	}()
+
+	var cmd strings.Builder
+	prompt := func() {
+		if cmd.Len() == 0 {
+			fmt.Fprint(stdout, "% ")
+		} else {
+			fmt.Fprint(stdout, "> ")
+		}
+	}
+
+	prompt()
+	for {
+		select {
+		case <-ir.ir.queue.closedchan:
+			// not Done, which belongs to the application
+			return false, nil
+		case line, ok := <-lines:
+			if !ok {
+				fmt.Fprintln(stdout)
+				return true, <-readerr
+			}
+			cmd.WriteString(line)
+			cmd.WriteString("\n")
+
+			var complete bool
+			err := ir.EvalAs(&complete, "info complete %{%q}", cmd.String())
+			if err != nil {
+				return false, err
+			}
+			if !complete {
+				prompt()
+				continue
+			}
+
+			var result string
+			err = ir.EvalAs(&result, "%{}", cmd.String())
+			cmd.Reset()
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+			} else if result != "" {
+				fmt.Fprintln(stdout, result)
+			}
+			prompt()
+		}
+	}
+}
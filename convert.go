@@ -0,0 +1,69 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+type converter struct {
+	to   func(v interface{}) (Obj, error)
+	from func(Obj, reflect.Value) error
+}
+
+var converters struct {
+	sync.RWMutex
+	m map[reflect.Type]converter
+}
+
+// Registers custom conversions for values of type `typ`. They are used by
+// Set, EvalAs, command arguments and everything else that converts values
+// between Go and TCL, taking precedence over the builtin conversions.
+//
+// The `to` function converts a Go value of type `typ` to a TCL object, the
+// `from` function receives a TCL object and a settable reflect.Value of type
+// `typ`. Either of them can be nil, in that case the corresponding direction
+// falls back to the builtin conversion. Both functions are invoked on the
+// interpreter thread.
+func RegisterConverter(typ reflect.Type, to func(v interface{}) (Obj, error), from func(Obj, reflect.Value) error) {
+	converters.Lock()
+	if converters.m == nil {
+		converters.m = make(map[reflect.Type]converter)
+	}
+	converters.m[typ] = converter{to, from}
+	converters.Unlock()
+}
+
+func lookup_converter(typ reflect.Type) (converter, bool) {
+	converters.RLock()
+	c, ok := converters.m[typ]
+	converters.RUnlock()
+	return c, ok
+}
+
+func convert_to_tcl_obj(v reflect.Value) (*C.Tcl_Obj, bool, error) {
+	c, ok := lookup_converter(v.Type())
+	if !ok || c.to == nil {
+		return nil, false, nil
+	}
+	obj, err := c.to(v.Interface())
+	if err != nil {
+		return nil, true, err
+	}
+	if obj.p == nil {
+		return nil, true, fmt.Errorf("gothic: converter for %s returned an empty object", v.Type())
+	}
+	return obj.p, true, nil
+}
+
+func convert_from_tcl_obj(obj *C.Tcl_Obj, v reflect.Value) (bool, error) {
+	c, ok := lookup_converter(v.Type())
+	if !ok || c.from == nil {
+		return false, nil
+	}
+	return true, c.from(Obj{obj}, v)
+}
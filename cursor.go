@@ -0,0 +1,174 @@
+package gothic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+)
+
+// A custom cursor created by Interpreter.CreateCursor.
+type Cursor struct {
+	name string
+	dir  string
+}
+
+// Returns the cursor specification (a TCL list), the value of the -cursor
+// option.
+func (c *Cursor) Name() string {
+	return c.name
+}
+
+// Removes the files backing the cursor. Tk loads them when the cursor is
+// used for the first time and after all the windows stopped using it, so
+// the cursor must not be used after that.
+func (c *Cursor) Close() error {
+	return os.RemoveAll(c.dir)
+}
+
+// Sets the cursor of the widget `widget`, `name` is a cursor name, e.g.
+// "crosshair" or "watch", or the Name of a cursor created by CreateCursor.
+// An empty name resets the cursor to the one of the parent window.
+func (ir *Interpreter) SetCursor(widget, name string) error {
+	return ir.Eval("%{%q} configure -cursor %{%q}", widget, name)
+}
+
+// Creates a cursor from the image `img`, `hotspot` is the point of the image
+// (relative to its bounds) the pointer position corresponds to. The cursor
+// is stored in temporary files as Tk loads custom cursors from files, they
+// are removed by Cursor.Close. On X11 the cursor is two-colored: dark pixels
+// are black, light ones are white and pixels with alpha below 50% are
+// transparent. Windows cursors keep the colors. Not supported on macOS.
+func (ir *Interpreter) CreateCursor(img image.Image, hotspot image.Point) (*Cursor, error) {
+	b := img.Bounds()
+	if !hotspot.In(image.Rect(0, 0, b.Dx(), b.Dy())) {
+		return nil, errors.New("gothic: cursor hotspot is outside of the image")
+	}
+	var ws string
+	err := ir.EvalAs(&ws, "tk windowingsystem")
+	if err != nil {
+		return nil, err
+	}
+	if ws == "aqua" {
+		return nil, errors.New("gothic: custom cursors are not supported on macOS")
+	}
+
+	dir, err := os.MkdirTemp("", "gothic-cursor")
+	if err != nil {
+		return nil, err
+	}
+	c := &Cursor{dir: dir}
+	var buf bytes.Buffer
+	if ws == "win32" {
+		file := filepath.Join(dir, "cursor.cur")
+		err = os.WriteFile(file, encode_cur(img, hotspot), 0666)
+		quote(&buf, "@"+file)
+	} else {
+		source, mask := encode_xbm(img, hotspot)
+		sourcefile := filepath.Join(dir, "cursor.xbm")
+		maskfile := filepath.Join(dir, "mask.xbm")
+		err = os.WriteFile(sourcefile, source, 0666)
+		if err == nil {
+			err = os.WriteFile(maskfile, mask, 0666)
+		}
+		quote(&buf, "@"+sourcefile)
+		buf.WriteString(" ")
+		quote(&buf, maskfile)
+		buf.WriteString(" black white")
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.name = buf.String()
+	return c, nil
+}
+
+// Encodes the X11 cursor source and mask bitmaps.
+func encode_xbm(img image.Image, hotspot image.Point) (source, mask []byte) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	stride := (w + 7) / 8
+	sbits := make([]byte, stride*h)
+	mbits := make([]byte, stride*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			if c.A < 128 {
+				continue
+			}
+			bit := byte(1) << uint(x%8)
+			mbits[y*stride+x/8] |= bit
+			// the source bits are foreground (black) pixels
+			if color.GrayModel.Convert(c).(color.Gray).Y < 128 {
+				sbits[y*stride+x/8] |= bit
+			}
+		}
+	}
+	return write_xbm("cursor", w, h, &hotspot, sbits), write_xbm("mask", w, h, nil, mbits)
+}
+
+func write_xbm(name string, w, h int, hotspot *image.Point, bits []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "#define %s_width %d\n#define %s_height %d\n", name, w, name, h)
+	if hotspot != nil {
+		fmt.Fprintf(&buf, "#define %s_x_hot %d\n#define %s_y_hot %d\n", name, hotspot.X, name, hotspot.Y)
+	}
+	fmt.Fprintf(&buf, "static unsigned char %s_bits[] = {", name)
+	for i, b := range bits {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		if i%12 == 0 {
+			buf.WriteString("\n  ")
+		} else {
+			buf.WriteString(" ")
+		}
+		fmt.Fprintf(&buf, "0x%02x", b)
+	}
+	buf.WriteString("};\n")
+	return buf.Bytes()
+}
+
+// Encodes a Windows .cur file with a single 32-bit image.
+func encode_cur(img image.Image, hotspot image.Point) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// the AND mask rows are padded to 4 bytes, alpha makes it unused
+	maskstride := ((w + 31) / 32) * 4
+	size := 40 + w*h*4 + maskstride*h
+
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	// ICONDIR: reserved, type (2 for cursors), count
+	binary.Write(&buf, le, [3]uint16{0, 2, 1})
+	// ICONDIRENTRY, the dimensions of 256 are written as 0
+	buf.WriteByte(byte(w))
+	buf.WriteByte(byte(h))
+	buf.Write([]byte{0, 0})
+	binary.Write(&buf, le, [2]uint16{uint16(hotspot.X), uint16(hotspot.Y)})
+	binary.Write(&buf, le, [2]uint32{uint32(size), 22})
+	// BITMAPINFOHEADER, the height includes the AND mask
+	binary.Write(&buf, le, struct {
+		Size          uint32
+		Width, Height int32
+		Planes, Bits  uint16
+		Compression   uint32
+		ImageSize     uint32
+		XRes, YRes    int32
+		Used, Imp     uint32
+	}{40, int32(w), int32(h * 2), 1, 32, 0, uint32(size - 40), 0, 0, 0, 0})
+	// BGRA pixels, bottom-up
+	for y := h - 1; y >= 0; y-- {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			buf.Write([]byte{c.B, c.G, c.R, c.A})
+		}
+	}
+	buf.Write(make([]byte, maskstride*h))
+	return buf.Bytes()
+}
@@ -0,0 +1,58 @@
+package gothic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func test_cursor_image() *image.NRGBA {
+	// 10x2: a black pixel, a white one and a transparent one in the first
+	// row, the second row is black
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 2))
+	img.Set(0, 0, color.Black)
+	img.Set(1, 0, color.White)
+	for x := 0; x < 10; x++ {
+		img.Set(x, 1, color.Black)
+	}
+	return img
+}
+
+func TestEncodeXBM(t *testing.T) {
+	source, mask := encode_xbm(test_cursor_image(), image.Pt(1, 1))
+	gold := "#define cursor_width 10\n#define cursor_height 2\n" +
+		"#define cursor_x_hot 1\n#define cursor_y_hot 1\n" +
+		"static unsigned char cursor_bits[] = {\n  0x01, 0x00, 0xff, 0x03};\n"
+	if string(source) != gold {
+		t.Errorf("source:\n%s\n!=\n%s", source, gold)
+	}
+	gold = "#define mask_width 10\n#define mask_height 2\n" +
+		"static unsigned char mask_bits[] = {\n  0x03, 0x00, 0xff, 0x03};\n"
+	if string(mask) != gold {
+		t.Errorf("mask:\n%s\n!=\n%s", mask, gold)
+	}
+}
+
+func TestEncodeCUR(t *testing.T) {
+	data := encode_cur(test_cursor_image(), image.Pt(3, 1))
+	// header, 40 bytes of BITMAPINFOHEADER, pixels and 4-byte mask rows
+	if n := 22 + 40 + 10*2*4 + 4*2; len(data) != n {
+		t.Fatalf("size %d != %d", len(data), n)
+	}
+	le := binary.LittleEndian
+	if le.Uint16(data[2:]) != 2 || le.Uint16(data[4:]) != 1 {
+		t.Error("not a cursor file with one image")
+	}
+	if data[6] != 10 || data[7] != 2 || le.Uint16(data[10:]) != 3 || le.Uint16(data[12:]) != 1 {
+		t.Error("wrong size or hotspot")
+	}
+	// the first pixel of the last row comes first
+	if px := data[62:66]; !bytes.Equal(px, []byte{0, 0, 0, 255}) {
+		t.Errorf("%v != black", px)
+	}
+	if px := data[62+40+4 : 62+40+8]; !bytes.Equal(px, []byte{255, 255, 255, 255}) {
+		t.Errorf("%v != white", px)
+	}
+}
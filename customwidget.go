@@ -0,0 +1,435 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"sort"
+	"strconv"
+	"unsafe"
+)
+
+// A Tk widget class implemented in Go, see Interpreter.DefineWidget. All the
+// callbacks are invoked on the interpreter thread.
+type WidgetClass struct {
+	// Tk class name (used by the option database and bind), e.g.
+	// "Waveform".
+	Class string
+
+	// The creation command, e.g. "waveform": `waveform .w -color red`.
+	Command string
+
+	// Supported options (without the leading dash) with their default
+	// values. "width" and "height" are always supported, they are the
+	// requested size of the widget (200x100 by default).
+	Options map[string]string
+
+	// Called after the options are set on creation and by `configure`,
+	// returning an error rejects the configuration. Can be nil.
+	Configure func(w *CustomWidget) error
+
+	// Renders the widget at its current size. Called at idle time after the
+	// widget is created, resized, configured or CustomWidget.Redraw is
+	// called.
+	Draw func(w *CustomWidget, width, height int) image.Image
+
+	// Event handlers by bind pattern, e.g. "<Button-1>", "<Motion>". The
+	// bindings added to the widget path with `bind` work as well.
+	Events map[string]func(w *CustomWidget, e Event)
+
+	// Called when the widget is destroyed. Can be nil.
+	Destroy func(w *CustomWidget)
+}
+
+// An instance of a WidgetClass.
+type CustomWidget struct {
+	// Arbitrary state of the widget, e.g. set by Configure.
+	Data interface{}
+
+	ir      *Interpreter
+	class   *WidgetClass
+	path    string
+	image   string
+	options map[string]string
+	events  []string // commands of the class.Events bindings
+	pending bool     // redraw is scheduled
+}
+
+type custom_widgets struct {
+	classes map[string]*WidgetClass
+	widgets map[string]*CustomWidget
+}
+
+// Widget commands are forwarded to the frame the widget is built of, except
+// `configure` and `cget`. The frame requests the configured size, the image
+// with the rendered contents is placed over it, so that it doesn't take part
+// in the geometry negotiation.
+const custom_widget_script = `
+namespace eval ::gothic::widget {}
+
+proc ::gothic::widget::create {class path args} {
+	frame $path -class $class -borderwidth 0 -highlightthickness 0
+	rename $path ::gothic::widget::_$path
+	interp alias {} ::$path {} ::gothic::widget::command $path
+	set img [image create photo]
+	label $path.img -image $img -borderwidth 0 -highlightthickness 0 -padx 0 -pady 0
+	place $path.img -x 0 -y 0 -relwidth 1 -relheight 1
+	bindtags $path.img [list $path.img $path [winfo toplevel $path] all]
+	bind $path <Configure> [list ::gothic::widget_redraw $path]
+	bind $path <Destroy> [list ::gothic::widget::destroyed $path %W]
+	if {[catch {
+		::gothic::widget_create $class $path $img
+		::gothic::widget::configure $path {*}$args
+	} err]} {
+		destroy $path
+		return -code error $err
+	}
+	return $path
+}
+
+proc ::gothic::widget::destroyed {path w} {
+	if {$w ne $path} {
+		return
+	}
+	::gothic::widget_destroyed $path
+	catch {rename ::$path {}}
+}
+
+proc ::gothic::widget::configure {path args} {
+	if {[llength $args] % 2} {
+		return -code error "value for \"[lindex $args end]\" missing"
+	}
+	foreach {option value} $args {
+		::gothic::widget_set $path $option $value
+	}
+	::gothic::widget_configured $path
+}
+
+proc ::gothic::widget::command {path cmd args} {
+	switch -- $cmd {
+		configure {
+			if {[llength $args] == 0} {
+				return [lmap item [::gothic::widget_options $path] {list {*}$item}]
+			}
+			if {[llength $args] == 1} {
+				set option [lindex $args 0]
+				return [list $option [::gothic::widget_get $path $option]]
+			}
+			::gothic::widget::configure $path {*}$args
+		}
+		cget {
+			if {[llength $args] != 1} {
+				return -code error "wrong # args: should be \"$path cget option\""
+			}
+			::gothic::widget_get $path [lindex $args 0]
+		}
+		default {
+			uplevel 1 [list ::gothic::widget::_$path $cmd {*}$args]
+		}
+	}
+}
+`
+
+// Defines a new widget class implemented in Go: class.Command creates
+// instances the way Tk widget commands do and the instance commands support
+// `configure` and `cget` for the class options. The widget contents are
+// rendered by class.Draw into an image, gothic takes care of the geometry
+// negotiation (the widget requests -width x -height, and is redrawn when the
+// geometry manager gives it another size) and of dispatching class.Events.
+func (ir *Interpreter) DefineWidget(class WidgetClass) error {
+	if class.Class == "" || class.Command == "" || class.Draw == nil {
+		return fmt.Errorf("gothic: widget class needs Class, Command and Draw")
+	}
+	options := map[string]string{"width": "200", "height": "100"}
+	for name, value := range class.Options {
+		options[name] = value
+	}
+	class.Options = options
+
+	return ir.ir.run(func() error {
+		err := ir.ir.init_custom_widgets(ir)
+		if err != nil {
+			return err
+		}
+		ir.ir.widgets.classes[class.Class] = &class
+		return ir.Eval("proc %{%q} {path args} {::gothic::widget::create %{%q} $path {*}$args}",
+			"::"+class.Command, class.Class)
+	})
+}
+
+func (ir *interpreter) init_custom_widgets(iir *Interpreter) error {
+	if ir.widgets != nil {
+		return nil
+	}
+	ws := &custom_widgets{
+		classes: make(map[string]*WidgetClass),
+		widgets: make(map[string]*CustomWidget),
+	}
+
+	commands := map[string]interface{}{
+		"::gothic::widget_create": func(class, path, img string) error {
+			c, ok := ws.classes[class]
+			if !ok {
+				return fmt.Errorf("gothic: unknown widget class %q", class)
+			}
+			w := &CustomWidget{
+				ir:      iir,
+				class:   c,
+				path:    path,
+				image:   img,
+				options: make(map[string]string, len(c.Options)),
+			}
+			for name, value := range c.Options {
+				w.options[name] = value
+			}
+			ws.widgets[path] = w
+			return w.bind_events()
+		},
+		"::gothic::widget_destroyed": func(path string) {
+			w, ok := ws.widgets[path]
+			if !ok {
+				return
+			}
+			delete(ws.widgets, path)
+			if w.class.Destroy != nil {
+				w.class.Destroy(w)
+			}
+			for _, cmd := range w.events {
+				ir.unregister_command(cmd)
+			}
+			var buf bytes.Buffer
+			sprintf(&buf, "image delete %{%q}", w.image)
+			ir.eval(buf.Bytes())
+		},
+		"::gothic::widget_set": func(path, option, value string) error {
+			w, err := ws.lookup(path)
+			if err != nil {
+				return err
+			}
+			name, err := w.option_name(option)
+			if err != nil {
+				return err
+			}
+			w.options[name] = value
+			return nil
+		},
+		"::gothic::widget_get": func(path, option string) (string, error) {
+			w, err := ws.lookup(path)
+			if err != nil {
+				return "", err
+			}
+			name, err := w.option_name(option)
+			if err != nil {
+				return "", err
+			}
+			return w.options[name], nil
+		},
+		"::gothic::widget_options": func(path string) (string, error) {
+			w, err := ws.lookup(path)
+			if err != nil {
+				return "", err
+			}
+			names := make([]string, 0, len(w.options))
+			for name := range w.options {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var buf, item bytes.Buffer
+			for i, name := range names {
+				if i != 0 {
+					buf.WriteString(" ")
+				}
+				item.Reset()
+				quote(&item, "-"+name)
+				item.WriteString(" ")
+				quote(&item, w.class.Options[name])
+				item.WriteString(" ")
+				quote(&item, w.options[name])
+				quote(&buf, item.String())
+			}
+			return buf.String(), nil
+		},
+		"::gothic::widget_configured": func(path string) error {
+			w, err := ws.lookup(path)
+			if err != nil {
+				return err
+			}
+			if w.class.Configure != nil {
+				err = w.class.Configure(w)
+				if err != nil {
+					return err
+				}
+			}
+			var buf bytes.Buffer
+			err = sprintf(&buf, "::gothic::widget::_%{} configure -width %{%q} -height %{%q}",
+				w.path, w.options["width"], w.options["height"])
+			if err != nil {
+				return err
+			}
+			err = ir.eval(buf.Bytes())
+			if err != nil {
+				return err
+			}
+			return w.schedule_redraw()
+		},
+		"::gothic::widget_redraw": func(path string) error {
+			w, ok := ws.widgets[path]
+			if !ok {
+				return nil
+			}
+			return w.schedule_redraw()
+		},
+		"::gothic::widget_draw": func(path string) error {
+			w, ok := ws.widgets[path]
+			if !ok {
+				return nil
+			}
+			w.pending = false
+			return w.draw()
+		},
+	}
+	for name, f := range commands {
+		err := ir.register_command(name, f)
+		if err != nil {
+			return err
+		}
+	}
+	err := ir.eval([]byte(custom_widget_script))
+	if err != nil {
+		return err
+	}
+	ir.widgets = ws
+	return nil
+}
+
+func (ws *custom_widgets) lookup(path string) (*CustomWidget, error) {
+	w, ok := ws.widgets[path]
+	if !ok {
+		return nil, fmt.Errorf("gothic: widget %q doesn't exist", path)
+	}
+	return w, nil
+}
+
+// Returns the path of the widget.
+func (w *CustomWidget) Path() string {
+	return w.path
+}
+
+// Returns the interpreter the widget belongs to.
+func (w *CustomWidget) Interpreter() *Interpreter {
+	return w.ir
+}
+
+// Returns the current value of the option `name` (without the leading dash).
+func (w *CustomWidget) Option(name string) string {
+	var value string
+	w.ir.ir.run(func() error {
+		value = w.options[name]
+		return nil
+	})
+	return value
+}
+
+// Schedules a redraw of the widget at idle time, multiple calls before it
+// happens result in a single redraw. Can be called from any goroutine, e.g.
+// when the data the widget displays changes.
+func (w *CustomWidget) Redraw() error {
+	return w.ir.ir.run(func() error {
+		return w.ir.ir.filt(w.schedule_redraw())
+	})
+}
+
+func (w *CustomWidget) option_name(option string) (string, error) {
+	if len(option) > 1 && option[0] == '-' {
+		if _, ok := w.options[option[1:]]; ok {
+			return option[1:], nil
+		}
+	}
+	return "", fmt.Errorf("unknown option %q", option)
+}
+
+func (w *CustomWidget) bind_events() error {
+	ir := w.ir.ir
+	var buf bytes.Buffer
+	for pattern, handler := range w.class.Events {
+		pattern, handler := pattern, handler
+		cmd := ir.next_command("widget_event")
+		err := ir.register_command(cmd, func(W, x, y, rx, ry, b, K, A, k, D, width, h, s string) {
+			handler(w, new_event(pattern, W, x, y, rx, ry, b, K, A, k, D, width, h, s))
+		})
+		if err != nil {
+			return err
+		}
+		w.events = append(w.events, cmd)
+		buf.Reset()
+		buf.WriteString("bind ")
+		quote(&buf, w.path+".img")
+		buf.WriteString(" ")
+		quote(&buf, pattern)
+		buf.WriteString(" ")
+		quote(&buf, cmd+" "+event_subst)
+		err = ir.eval(buf.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *CustomWidget) schedule_redraw() error {
+	if w.pending {
+		return nil
+	}
+	w.pending = true
+	var buf bytes.Buffer
+	sprintf(&buf, "after idle [list ::gothic::widget_draw %{%q}]", w.path)
+	return w.ir.ir.eval(buf.Bytes())
+}
+
+func (w *CustomWidget) draw() error {
+	ir := w.ir.ir
+	var buf bytes.Buffer
+	// the size is 1x1 until the widget is mapped
+	sprintf(&buf, `if {[winfo ismapped %{0%q}]} {
+		list [winfo width %{0%q}] [winfo height %{0%q}]
+	} else {
+		list [winfo reqwidth %{0%q}] [winfo reqheight %{0%q}]
+	}`, w.path)
+	err := ir.eval(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	size, err := Obj{C.Tcl_GetObjResult(ir.C)}.List()
+	if err != nil || len(size) != 2 {
+		return fmt.Errorf("gothic: can't get the size of %s", w.path)
+	}
+	width, _ := strconv.Atoi(size[0].String())
+	height, _ := strconv.Atoi(size[1].String())
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+
+	img := w.class.Draw(w, width, height)
+	buf.Reset()
+	sprintf(&buf, "%{%q} configure -width %{} -height %{}", w.image, width, height)
+	err = ir.eval(buf.Bytes())
+	if err != nil || img == nil {
+		return err
+	}
+
+	cname := C.CString(w.image)
+	handle := C.Tk_FindPhoto(ir.C, cname)
+	C.free(unsafe.Pointer(cname))
+	if handle == nil {
+		return fmt.Errorf("gothic: image %q doesn't exist", w.image)
+	}
+	status := photo_put_image(ir.C, handle, img, img.Bounds().Min, 0, 0, width, height)
+	if status != C.TCL_OK {
+		return ir.result_error()
+	}
+	return nil
+}
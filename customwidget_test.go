@@ -0,0 +1,87 @@
+package gothic
+
+import (
+	"image"
+	"testing"
+)
+
+func TestDefineWidget(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		// fake Tk commands, the widget command of a frame records its
+		// arguments
+		err := ir.Eval(`
+			set log {}
+			proc frame {path args} {
+				proc ::$path {args} "lappend ::log \[list $path {*}\$args\]"
+			}
+			proc label {args} {}
+			proc place {args} {}
+			proc bindtags {args} {}
+			proc bind {args} {}
+			proc winfo {args} {return .}
+			proc image {args} {return image1}
+			proc after {args} {}
+			proc destroy {path} {::gothic::widget::destroyed $path $path}
+		`)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		configured := 0
+		err = ir.DefineWidget(WidgetClass{
+			Class:   "Waveform",
+			Command: "waveform",
+			Options: map[string]string{"color": "black", "height": "50"},
+			Configure: func(w *CustomWidget) error {
+				configured++
+				return nil
+			},
+			Draw: func(w *CustomWidget, width, height int) image.Image {
+				return nil
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		var s string
+		err = ir.EvalAs(&s, "waveform .w -color red -width 300")
+		if err != nil {
+			t.Error(err)
+		} else if s != ".w" {
+			t.Errorf(".w != %s", s)
+		}
+		err = ir.EvalAs(&s, "list [.w cget -color] [.w configure -height] [.w configure]")
+		if err != nil {
+			t.Error(err)
+		} else if gold := "red {-height 50} {{-color black red} {-height 50 50} {-width 200 300}}"; s != gold {
+			t.Errorf("%s != %s", gold, s)
+		}
+
+		err = ir.Eval(".w configure -size 10")
+		must_contain(t, err, `unknown option "-size"`)
+		err = ir.Eval(".w configure -color")
+		if err != nil {
+			t.Error(err)
+		}
+		err = ir.Eval(".w configure -color blue -height")
+		must_contain(t, err, `value for "-height" missing`)
+
+		ir.EvalAs(&s, `join $log "\n"`)
+		if gold := ".w configure -width 300 -height 50"; s != gold {
+			t.Errorf("%s != %s", gold, s)
+		}
+		if configured != 1 {
+			t.Errorf("Configure called %d times", configured)
+		}
+
+		err = ir.EvalAs(&s, "destroy .w; info commands .w")
+		if err != nil {
+			t.Error(err)
+		} else if s != "" {
+			t.Errorf("widget command %s wasn't removed", s)
+		}
+	})
+}
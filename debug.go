@@ -0,0 +1,70 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+	"unsafe"
+)
+
+// Verbosity of the debug output, see Interpreter.SetDebug. Each level includes
+// everything from the levels below it.
+type DebugLevel int
+
+const (
+	DebugOff       DebugLevel = iota
+	DebugScripts              // evaluated scripts and their execution time
+	DebugQueue                // time actions spent in the async queue
+	DebugCallbacks            // Go command and method dispatches
+)
+
+// Turns on the debug output of the given `level` into `w`. Passing nil writer
+// or DebugOff level turns the debug output off. All the output is written from
+// the interpreter thread.
+func (ir *Interpreter) SetDebug(w io.Writer, level DebugLevel) {
+	if w == nil {
+		level = DebugOff
+	}
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		ir.ir.debugw, ir.ir.debuglvl = w, level
+		return
+	}
+	ir.ir.run_and_wait(func() error {
+		ir.ir.debugw, ir.ir.debuglvl = w, level
+		return nil
+	})
+}
+
+func (ir *interpreter) debugf(level DebugLevel, format string, args ...interface{}) {
+	if ir.debuglvl < level {
+		return
+	}
+	fmt.Fprintf(ir.debugw, "gothic: "+format+"\n", args...)
+}
+
+func (ir *interpreter) debug_eval(script []byte, d time.Duration, err error) {
+	if err != nil {
+		ir.debugf(DebugScripts, "eval (%s): %s\n\terror: %s", d, script, err)
+		return
+	}
+	ir.debugf(DebugScripts, "eval (%s): %s", d, script)
+}
+
+func (ir *interpreter) debug_dispatch(kind string, objc C.int, objv unsafe.Pointer) {
+	if ir.debuglvl < DebugCallbacks {
+		return
+	}
+	var buf bytes.Buffer
+	for i, obj := range unsafe.Slice((**C.Tcl_Obj)(objv), int(objc)) {
+		if i != 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(tcl_obj_to_go_string(obj))
+	}
+	ir.debugf(DebugCallbacks, "%s dispatch: %s", kind, buf.Bytes())
+}
@@ -0,0 +1,101 @@
+package gothic
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Starts an opt-in debug server listening on the TCP address `addr` (e.g.
+// "localhost:7777"), which accepts TCL commands and evaluates them on the
+// interpreter thread, writing results back, the same way as StartConsole
+// does. The first line sent by a client must be the `token`, connections
+// failing to provide it are closed. Works with plain tools:
+//
+//	$ nc localhost 7777
+//	secret
+//	% winfo children .
+//
+// Anyone who knows the token gets full control over the process, so keep
+// the server bound to localhost or behind a tunnel. Call `stop` to close the
+// listener and all connections.
+func (ir *Interpreter) ServeDebug(addr, token string) (stop func(), err error) {
+	if token == "" {
+		return nil, errors.New("gothic: ServeDebug requires a non-empty token")
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+	closed := false
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			if closed {
+				mu.Unlock()
+				conn.Close()
+				return
+			}
+			conns[conn] = true
+			mu.Unlock()
+
+			go func() {
+				serve_debug_conn(ir, conn, token)
+				conn.Close()
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+			}()
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			closed = true
+			l.Close()
+			for conn := range conns {
+				conn.Close()
+			}
+			mu.Unlock()
+		})
+	}, nil
+}
+
+// The time a client has to send the token.
+const debug_auth_timeout = 10 * time.Second
+
+func serve_debug_conn(ir *Interpreter, conn net.Conn, token string) {
+	// an unauthenticated client can't hold the connection open or make us
+	// buffer more than the token line
+	conn.SetReadDeadline(time.Now().Add(debug_auth_timeout))
+	r := bufio.NewReader(io.LimitReader(conn, int64(len(token)+2)))
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if subtle.ConstantTimeCompare([]byte(line), []byte(token)) != 1 {
+		fmt.Fprintln(conn, "gothic: authentication failed")
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// the reader may hold the beginning of the first command
+	console(ir, io.MultiReader(r, conn), conn, conn)
+}
@@ -0,0 +1,126 @@
+package gothic
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// A TCL command with subcommands, routing `name sub args...` to the Go
+// handler of `sub`. It's a TCL ensemble: subcommands can be abbreviated to
+// unique prefixes and the unknown ones fail with the standard "unknown or
+// ambiguous subcommand" error. Register it using
+// Interpreter.RegisterDispatcher.
+//
+//	d := gothic.NewDispatcher()
+//	d.Handle("open", func(path string) (int, error) { ... })
+//	d.Handle("close", func(fd int) error { ... })
+//	ir.RegisterDispatcher("file2", d)
+//	// file2 op data.txt
+type Dispatcher struct {
+	subs []dispatcher_sub
+}
+
+type dispatcher_sub struct {
+	name string
+	fn   interface{}
+	opts *CommandOpts
+	site string
+}
+
+// Returns an empty Dispatcher.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// Adds the subcommand `sub` handled by `fn`, which works as in
+// RegisterCommand. Subcommands must be added before the dispatcher is
+// registered.
+func (d *Dispatcher) Handle(sub string, fn interface{}, opts ...CommandOpts) {
+	s := dispatcher_sub{name: sub, fn: fn, site: caller_site(1)}
+	if len(opts) > 0 {
+		s.opts = &opts[0]
+	}
+	d.subs = append(d.subs, s)
+}
+
+// Registers the dispatcher `d` as the command `name` (Options.CommandPrefix
+// applies). UnregisterCommand deletes it with all its subcommands.
+func (ir *Interpreter) RegisterDispatcher(name string, d *Dispatcher) error {
+	name = ir.ir.prefixed(name)
+	return ir.ir.run(func() error {
+		return ir.ir.filt(ir.ir.register_dispatcher(name, d))
+	})
+}
+
+func (ir *interpreter) register_dispatcher(name string, d *Dispatcher) error {
+	if len(d.subs) == 0 {
+		return errors.New("gothic: the dispatcher has no subcommands")
+	}
+	qualified := name
+	if !strings.HasPrefix(qualified, "::") {
+		qualified = "::" + qualified
+	}
+	// the ensemble would replace a Go command of the same name
+	for _, n := range []string{qualified, qualified[2:]} {
+		_, dup := ir.dispatchers[n]
+		if _, ok := ir.commands[n]; ok || dup {
+			return errors.New("gothic: command with the same name was already registered")
+		}
+	}
+
+	// the handlers live in a namespace of their own, deleting it deletes
+	// them and the ensemble
+	ns := ir.next_command("dispatch")
+	var buf bytes.Buffer
+	sprintf(&buf, "namespace eval %{%q} {}", ns)
+	err := ir.eval(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	buf.Reset()
+	sprintf(&buf, "namespace ensemble create -command %{%q} -map {", qualified)
+	for _, s := range d.subs {
+		cmd := ns + "::" + s.name
+		err = ir.register_command_at(cmd, s.fn, s.site, s.opts)
+		if err == nil {
+			// the usage in the "wrong # args" errors is the one of the
+			// subcommand
+			cd := ir.commands[cmd]
+			cd.usage = name + " " + s.name + cd.usage[len(cmd):]
+			buf.WriteString(" ")
+			quote(&buf, s.name)
+			buf.WriteString(" ")
+			quote(&buf, cmd)
+			continue
+		}
+		ir.eval([]byte("namespace delete " + ns))
+		return err
+	}
+	buf.WriteString("}")
+
+	// the ensemble belongs to the namespace it's created in
+	script := buf.String()
+	buf.Reset()
+	sprintf(&buf, "namespace eval %{%q} %{%q}", ns, script)
+	err = ir.eval(buf.Bytes())
+	if err != nil {
+		ir.eval([]byte("namespace delete " + ns))
+		return err
+	}
+	if ir.dispatchers == nil {
+		ir.dispatchers = make(map[string]string)
+	}
+	ir.dispatchers[name] = ns
+	return nil
+}
+
+// deletes the dispatcher `name` if there is one
+func (ir *interpreter) unregister_dispatcher(name string) (bool, error) {
+	ns, ok := ir.dispatchers[name]
+	if !ok {
+		return false, nil
+	}
+	delete(ir.dispatchers, name)
+	return true, ir.eval([]byte("namespace delete " + ns))
+}
@@ -0,0 +1,59 @@
+package gothic
+
+import (
+	"testing"
+)
+
+func TestDispatcher(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		d := NewDispatcher()
+		d.Handle("add", func(a, b int) int { return a + b })
+		d.Handle("append", func(a, b string) string { return a + b })
+		d.Handle("negate", func(a int) int { return -a })
+		err := ir.RegisterDispatcher("math", d)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		var s string
+		err = ir.EvalAs(&s, "list [math add 1 2] [math app a b] [math n 5]")
+		if err != nil {
+			t.Error(err)
+		} else if gold := "3 ab -5"; s != gold {
+			t.Errorf("%s != %s", gold, s)
+		}
+
+		err = ir.Eval("math a 1 2")
+		must_contain(t, err, `unknown or ambiguous subcommand "a": must be add, append, or negate`)
+		err = ir.Eval("math mul 1 2")
+		must_contain(t, err, `unknown or ambiguous subcommand "mul"`)
+		err = ir.Eval("math add 1")
+		must_contain(t, err, `wrong # args: should be "math add arg1 arg2"`)
+
+		err = ir.UnregisterCommand("math")
+		if err != nil {
+			t.Error(err)
+		}
+		err = ir.Eval("math add 1 2")
+		must_contain(t, err, `invalid command name "math"`)
+		err = ir.RegisterDispatcher("math", d)
+		if err != nil {
+			t.Error(err)
+		}
+		err = ir.RegisterDispatcher("::math", d)
+		must_contain(t, err, "already registered")
+
+		// Go commands aren't replaced
+		ir.RegisterCommand("calc", func() int { return 42 })
+		err = ir.RegisterDispatcher("::calc", d)
+		must_contain(t, err, "already registered")
+		var x int
+		err = ir.EvalAs(&x, "calc")
+		if err != nil {
+			t.Error(err)
+		} else if x != 42 {
+			t.Errorf("42 != %d", x)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+//go:build gothic_dlopen
+
+// Definitions of the TCL and Tk functions gothic uses, forwarding the calls to
+// the libraries loaded with dlopen (see dynload.go). The pointers are
+// resolved by name when a library is loaded, the functions must not be called
+// before that.
+
+#include "interpreter.h"
+#include <dlfcn.h>
+
+enum { LIB_TCL, LIB_TK };
+
+#define SYMBOLS(FUNC, PROC) \
+	FUNC(LIB_TCL, Tcl_Interp*, Tcl_CreateInterp, (void), ()) \
+	PROC(LIB_TCL, Tcl_DeleteInterp, (Tcl_Interp *interp), (interp)) \
+	PROC(LIB_TCL, Tcl_FinalizeThread, (void), ()) \
+	FUNC(LIB_TCL, int, Tcl_Init, (Tcl_Interp *interp), (interp)) \
+	FUNC(LIB_TCL, Tcl_ThreadId, Tcl_GetCurrentThread, (void), ()) \
+	PROC(LIB_TCL, Tcl_ThreadQueueEvent, (Tcl_ThreadId threadId, Tcl_Event *evPtr, Tcl_QueuePosition position), (threadId, evPtr, position)) \
+	PROC(LIB_TCL, Tcl_ThreadAlert, (Tcl_ThreadId threadId), (threadId)) \
+	FUNC(LIB_TCL, int, Tcl_DoOneEvent, (int flags), (flags)) \
+	PROC(LIB_TCL, Tcl_SetMaxBlockTime, (const Tcl_Time *timePtr), (timePtr)) \
+	PROC(LIB_TCL, Tcl_CreateEventSource, (Tcl_EventSetupProc *setupProc, Tcl_EventCheckProc *checkProc, ClientData clientData), (setupProc, checkProc, clientData)) \
+	PROC(LIB_TCL, Tcl_DeleteEventSource, (Tcl_EventSetupProc *setupProc, Tcl_EventCheckProc *checkProc, ClientData clientData), (setupProc, checkProc, clientData)) \
+	FUNC(LIB_TCL, void*, Tcl_GetThreadData, (Tcl_ThreadDataKey *keyPtr, int size), (keyPtr, size)) \
+	FUNC(LIB_TCL, int, Tcl_SetServiceMode, (int mode), (mode)) \
+	FUNC(LIB_TCL, int, Tcl_SetRecursionLimit, (Tcl_Interp *interp, int depth), (interp, depth)) \
+	FUNC(LIB_TCL, char*, Tcl_Alloc, (unsigned int size), (size)) \
+	FUNC(LIB_TCL, int, Tcl_EvalEx, (Tcl_Interp *interp, const char *script, int numBytes, int flags), (interp, script, numBytes, flags)) \
+	FUNC(LIB_TCL, int, Tcl_ExprObj, (Tcl_Interp *interp, Tcl_Obj *objPtr, Tcl_Obj **resultPtrPtr), (interp, objPtr, resultPtrPtr)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_GetObjResult, (Tcl_Interp *interp), (interp)) \
+	PROC(LIB_TCL, Tcl_SetObjResult, (Tcl_Interp *interp, Tcl_Obj *resultObjPtr), (interp, resultObjPtr)) \
+	PROC(LIB_TCL, Tcl_ResetResult, (Tcl_Interp *interp), (interp)) \
+	PROC(LIB_TCL, Tcl_SetResult, (Tcl_Interp *interp, char *result, Tcl_FreeProc *freeProc), (interp, result, freeProc)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_NewObj, (void), ()) \
+	PROC(LIB_TCL, TclFreeObj, (Tcl_Obj *objPtr), (objPtr)) \
+	PROC(LIB_TCL, Tcl_Preserve, (ClientData data), (data)) \
+	PROC(LIB_TCL, Tcl_Release, (ClientData data), (data)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_NewStringObj, (const char *bytes, int length), (bytes, length)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_NewWideIntObj, (Tcl_WideInt wideValue), (wideValue)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_NewIntObj, (int intValue), (intValue)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_NewDoubleObj, (double doubleValue), (doubleValue)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_NewByteArrayObj, (const unsigned char *bytes, int length), (bytes, length)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_NewListObj, (int objc, Tcl_Obj *const objv[]), (objc, objv)) \
+	FUNC(LIB_TCL, char*, Tcl_GetString, (Tcl_Obj *objPtr), (objPtr)) \
+	FUNC(LIB_TCL, char*, Tcl_GetStringFromObj, (Tcl_Obj *objPtr, int *lengthPtr), (objPtr, lengthPtr)) \
+	FUNC(LIB_TCL, int, Tcl_GetWideIntFromObj, (Tcl_Interp *interp, Tcl_Obj *objPtr, Tcl_WideInt *widePtr), (interp, objPtr, widePtr)) \
+	FUNC(LIB_TCL, int, Tcl_GetDoubleFromObj, (Tcl_Interp *interp, Tcl_Obj *objPtr, double *doublePtr), (interp, objPtr, doublePtr)) \
+	FUNC(LIB_TCL, int, Tcl_GetBooleanFromObj, (Tcl_Interp *interp, Tcl_Obj *objPtr, int *intPtr), (interp, objPtr, intPtr)) \
+	FUNC(LIB_TCL, unsigned char*, Tcl_GetByteArrayFromObj, (Tcl_Obj *objPtr, int *lengthPtr), (objPtr, lengthPtr)) \
+	FUNC(LIB_TCL, int, Tcl_ListObjGetElements, (Tcl_Interp *interp, Tcl_Obj *listPtr, int *objcPtr, Tcl_Obj ***objvPtr), (interp, listPtr, objcPtr, objvPtr)) \
+	FUNC(LIB_TCL, int, Tcl_ListObjAppendElement, (Tcl_Interp *interp, Tcl_Obj *listPtr, Tcl_Obj *objPtr), (interp, listPtr, objPtr)) \
+	PROC(LIB_TCL, Tcl_AppendToObj, (Tcl_Obj *objPtr, const char *bytes, int length), (objPtr, bytes, length)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_GetVar2Ex, (Tcl_Interp *interp, const char *part1, const char *part2, int flags), (interp, part1, part2, flags)) \
+	FUNC(LIB_TCL, Tcl_Obj*, Tcl_SetVar2Ex, (Tcl_Interp *interp, const char *part1, const char *part2, Tcl_Obj *newValuePtr, int flags), (interp, part1, part2, newValuePtr, flags)) \
+	FUNC(LIB_TCL, Tcl_Command, Tcl_CreateObjCommand, (Tcl_Interp *interp, const char *cmdName, Tcl_ObjCmdProc *proc, ClientData clientData, Tcl_CmdDeleteProc *deleteProc), (interp, cmdName, proc, clientData, deleteProc)) \
+	FUNC(LIB_TCL, int, Tcl_DeleteCommand, (Tcl_Interp *interp, const char *cmdName), (interp, cmdName)) \
+	FUNC(LIB_TCL, Tcl_Interp*, Tcl_GetSlave, (Tcl_Interp *interp, const char *name), (interp, name)) \
+	FUNC(LIB_TCL, int, Tcl_CreateAlias, (Tcl_Interp *childInterp, const char *childCmd, Tcl_Interp *target, const char *targetCmd, int argc, CONST84 char *const *argv), (childInterp, childCmd, target, targetCmd, argc, argv)) \
+	FUNC(LIB_TCL, int, Tcl_StringMatch, (const char *str, const char *pattern), (str, pattern)) \
+	FUNC(LIB_TCL, int, Tcl_ReadChars, (Tcl_Channel channel, Tcl_Obj *objPtr, int charsToRead, int appendFlag), (channel, objPtr, charsToRead, appendFlag)) \
+	FUNC(LIB_TCL, Tcl_WideInt, Tcl_Seek, (Tcl_Channel chan, Tcl_WideInt offset, int mode), (chan, offset, mode)) \
+	FUNC(LIB_TCL, Tcl_Channel, Tcl_CreateChannel, (const Tcl_ChannelType *typePtr, const char *chanName, ClientData instanceData, int mask), (typePtr, chanName, instanceData, mask)) \
+	PROC(LIB_TCL, Tcl_RegisterChannel, (Tcl_Interp *interp, Tcl_Channel chan), (interp, chan)) \
+	FUNC(LIB_TCL, int, Tcl_UnregisterChannel, (Tcl_Interp *interp, Tcl_Channel chan), (interp, chan)) \
+	FUNC(LIB_TCL, int, Tcl_IsChannelRegistered, (Tcl_Interp *interp, Tcl_Channel chan), (interp, chan)) \
+	FUNC(LIB_TCL, CONST84_RETURN char*, Tcl_GetChannelName, (Tcl_Channel chan), (chan)) \
+	FUNC(LIB_TCL, int, Tcl_FSRegister, (ClientData clientData, const Tcl_Filesystem *fsPtr), (clientData, fsPtr)) \
+	PROC(LIB_TCL, Tcl_FSMountsChanged, (const Tcl_Filesystem *fsPtr), (fsPtr)) \
+	FUNC(LIB_TCL, CONST84_RETURN char*, Tcl_PosixError, (Tcl_Interp *interp), (interp)) \
+	PROC(LIB_TCL, Tcl_SetErrno, (int err), (err)) \
+	FUNC(LIB_TK, int, Tk_Init, (Tcl_Interp *interp), (interp)) \
+	FUNC(LIB_TK, int, Tk_GetNumMainWindows, (void), ()) \
+	FUNC(LIB_TK, Tk_Window, Tk_MainWindow, (Tcl_Interp *interp), (interp)) \
+	PROC(LIB_TK, Tk_SetClass, (Tk_Window tkwin, const char *className), (tkwin, className)) \
+	FUNC(LIB_TK, Tk_Uid, Tk_GetUid, (const char *str), (str)) \
+	FUNC(LIB_TK, XColor*, Tk_GetColor, (Tcl_Interp *interp, Tk_Window tkwin, Tk_Uid name), (interp, tkwin, name)) \
+	PROC(LIB_TK, Tk_FreeColor, (XColor *colorPtr), (colorPtr)) \
+	FUNC(LIB_TK, Tk_PhotoHandle, Tk_FindPhoto, (Tcl_Interp *interp, const char *imageName), (interp, imageName)) \
+	FUNC(LIB_TK, int, Tk_PhotoGetImage, (Tk_PhotoHandle handle, Tk_PhotoImageBlock *blockPtr), (handle, blockPtr)) \
+	PROC(LIB_TK, Tk_CreatePhotoImageFormat, (const Tk_PhotoImageFormat *formatPtr), (formatPtr)) \
+	FUNC(LIB_TK, int, Tk_PhotoSetSize, (Tcl_Interp *interp, Tk_PhotoHandle handle, int width, int height), (interp, handle, width, height)) \
+	PROC(LIB_TK, Tk_CreateItemType, (Tk_ItemType *typePtr), (typePtr)) \
+	FUNC(LIB_TK, Tk_Window, Tk_CanvasTkwin, (Tk_Canvas canvas), (canvas)) \
+	FUNC(LIB_TK, int, Tk_CanvasGetCoordFromObj, (Tcl_Interp *interp, Tk_Canvas canvas, Tcl_Obj *obj, double *doublePtr), (interp, canvas, obj, doublePtr)) \
+	PROC(LIB_TK, Tk_CanvasDrawableCoords, (Tk_Canvas canvas, double x, double y, short *drawableXPtr, short *drawableYPtr), (canvas, x, y, drawableXPtr, drawableYPtr)) \
+	FUNC(LIB_TK, int, Tk_CanvasTagsParseProc, (ClientData clientData, Tcl_Interp *interp, Tk_Window tkwin, const char *value, char *widgRec, int offset), (clientData, interp, tkwin, value, widgRec, offset)) \
+	FUNC(LIB_TK, CONST86 char*, Tk_CanvasTagsPrintProc, (ClientData clientData, Tk_Window tkwin, char *widgRec, int offset, Tcl_FreeProc **freeProcPtr), (clientData, tkwin, widgRec, offset, freeProcPtr)) \
+	FUNC(LIB_TK, int, Tk_ConfigureWidget, (Tcl_Interp *interp, Tk_Window tkwin, const Tk_ConfigSpec *specs, int argc, CONST84 char **argv, char *widgRec, int flags), (interp, tkwin, specs, argc, argv, widgRec, flags)) \
+	PROC(LIB_TK, Tk_FreeOptions, (const Tk_ConfigSpec *specs, char *widgRec, Display *display, int needFlags), (specs, widgRec, display, needFlags)) \
+	FUNC(LIB_TK, Tk_Image, Tk_GetImage, (Tcl_Interp *interp, Tk_Window tkwin, const char *name, Tk_ImageChangedProc *changeProc, ClientData clientData), (interp, tkwin, name, changeProc, clientData)) \
+	PROC(LIB_TK, Tk_FreeImage, (Tk_Image image), (image)) \
+	PROC(LIB_TK, Tk_DeleteImage, (Tcl_Interp *interp, const char *name), (interp, name)) \
+	PROC(LIB_TK, Tk_RedrawImage, (Tk_Image image, int imageX, int imageY, int width, int height, Drawable drawable, int drawableX, int drawableY), (image, imageX, imageY, width, height, drawable, drawableX, drawableY)) \
+	FUNC(LIB_TK, int, Tk_PhotoPutBlock, (Tcl_Interp *interp, Tk_PhotoHandle handle, Tk_PhotoImageBlock *blockPtr, int x, int y, int width, int height, int compRule), (interp, handle, blockPtr, x, y, width, height, compRule))
+
+#define DEFINE_FUNC(lib, ret, name, params, args) \
+	static ret (*p_##name) params; \
+	ret name params { return p_##name args; }
+#define DEFINE_PROC(lib, name, params, args) \
+	static void (*p_##name) params; \
+	void name params { p_##name args; }
+
+SYMBOLS(DEFINE_FUNC, DEFINE_PROC)
+
+typedef struct {
+	int lib;
+	const char *name;
+	void **ptr;
+} Symbol;
+
+#define SYMBOL_FUNC(lib, ret, name, params, args) {lib, #name, (void**)&p_##name},
+#define SYMBOL_PROC(lib, name, params, args) {lib, #name, (void**)&p_##name},
+
+static Symbol symbols[] = {
+	SYMBOLS(SYMBOL_FUNC, SYMBOL_PROC)
+};
+
+// Loads the library `name` and resolves the symbols of `lib` from it. Returns
+// NULL on success or the error message.
+const char *_gotk_c_dl_load(int lib, const char *name) {
+	unsigned int i;
+	void *handle = dlopen(name, RTLD_NOW | RTLD_GLOBAL);
+	if (!handle)
+		return dlerror();
+	for (i = 0; i < sizeof(symbols) / sizeof(symbols[0]); i++) {
+		if (symbols[i].lib != lib)
+			continue;
+		*symbols[i].ptr = dlsym(handle, symbols[i].name);
+		if (!*symbols[i].ptr) {
+			const char *err = dlerror();
+			dlclose(handle);
+			return err;
+		}
+	}
+	return NULL;
+}
@@ -0,0 +1,73 @@
+//go:build gothic_dlopen
+
+package gothic
+
+/*
+#cgo LDFLAGS: -ldl
+#include <stdlib.h>
+
+const char *_gotk_c_dl_load(int lib, const char *name);
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Names of the shared libraries tried in order when gothic is built with the
+// gothic_dlopen tag. In this mode the binary isn't linked against TCL and Tk,
+// they are loaded when the first interpreter is created (Tk when it's
+// initialized), so that it starts on systems without them and New reports
+// ErrLibraryNotFound instead. Can be changed before the first interpreter is
+// created, e.g. to point to libraries shipped with the application.
+var (
+	TclLibraries = []string{
+		"libtcl8.6.so",
+		"libtcl8.6.dylib",
+		"/Library/Frameworks/Tcl.framework/Tcl",
+	}
+	TkLibraries = []string{
+		"libtk8.6.so",
+		"libtk8.6.dylib",
+		"/Library/Frameworks/Tk.framework/Tk",
+	}
+)
+
+const (
+	lib_tcl = 0
+	lib_tk  = 1
+)
+
+type dynlib struct {
+	once sync.Once
+	err  error
+}
+
+var tcl_lib, tk_lib dynlib
+
+func (l *dynlib) load(lib int, names []string) error {
+	l.once.Do(func() {
+		var errs []string
+		for _, name := range names {
+			cname := C.CString(name)
+			cerr := C._gotk_c_dl_load(C.int(lib), cname)
+			C.free(unsafe.Pointer(cname))
+			if cerr == nil {
+				return
+			}
+			errs = append(errs, C.GoString(cerr))
+		}
+		l.err = fmt.Errorf("%w: %s", ErrLibraryNotFound, strings.Join(errs, "; "))
+	})
+	return l.err
+}
+
+func load_tcl() error {
+	return tcl_lib.load(lib_tcl, TclLibraries)
+}
+
+func load_tk() error {
+	return tk_lib.load(lib_tk, TkLibraries)
+}
@@ -0,0 +1,60 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"runtime"
+	"time"
+)
+
+// Tuning of the event loop of the interpreter thread, see
+// Interpreter.SetEventLoopOptions. Zero value means defaults.
+type EventLoopOptions struct {
+	// The longest the loop sleeps waiting for events, zero means until an
+	// event arrives. A limit makes the thread wake up periodically even when
+	// idle, which costs CPU, but bounds the latency when the notifier misses
+	// wakeups (seen in some VMs and with some X11 setups).
+	MaxBlockTime time.Duration
+
+	// Yields the processor to other goroutines (runtime.Gosched) after
+	// handling that many events, zero never yields. The interpreter thread
+	// stays busy under a flood of events (e.g. a stream of updates from Go)
+	// and can starve the goroutines feeding it when GOMAXPROCS is small.
+	YieldEvery int
+
+	// Stops TCL from handling events outside of the event loop, in
+	// Tcl_ServiceAll (TCL_SERVICE_NONE). Native modal loops (dialogs,
+	// window resizing on Windows and macOS) use it to keep the application
+	// responsive, disabling it makes them block the other events instead of
+	// running Go callbacks in the middle of the native code.
+	NoServiceAll bool
+}
+
+// Changes the event loop tuning of the interpreter, see EventLoopOptions.
+func (ir *Interpreter) SetEventLoopOptions(opts EventLoopOptions) error {
+	return ir.ir.run(func() error {
+		ir.ir.loop = opts
+		ir.ir.loop_events = 0
+		C._gotk_c_set_max_block_time(C.long(opts.MaxBlockTime / time.Microsecond))
+		if opts.NoServiceAll {
+			C.Tcl_SetServiceMode(C.TCL_SERVICE_NONE)
+		} else {
+			C.Tcl_SetServiceMode(C.TCL_SERVICE_ALL)
+		}
+		return nil
+	})
+}
+
+// called by the main loop after each event
+func (ir *interpreter) event_handled() {
+	if ir.loop.YieldEvery <= 0 {
+		return
+	}
+	ir.loop_events++
+	if ir.loop_events >= ir.loop.YieldEvery {
+		ir.loop_events = 0
+		runtime.Gosched()
+	}
+}
@@ -0,0 +1,112 @@
+package gothic
+
+import (
+	"strconv"
+	"sync"
+)
+
+// Size of the channel buffer used by Interpreter.Events.
+const EventsBufferSize = 64
+
+// A Tk event decoded from the bind % substitutions. Fields which don't make
+// sense for the event type are zero.
+type Event struct {
+	Widget  string // %W
+	Pattern string // the pattern passed to Interpreter.Events
+	X, Y    int    // %x %y, relative to the widget
+	RootX   int    // %X
+	RootY   int    // %Y
+	Button  int    // %b
+	KeySym  string // %K
+	Char    string // %A
+	KeyCode int    // %k
+	Delta   int    // %D
+	Width   int    // %w
+	Height  int    // %h
+	State   int    // %s
+}
+
+// parses a numeric % substitution, Tk uses "??" for irrelevant ones
+func event_int(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// the % substitutions passed to new_event
+const event_subst = "%W %x %y %X %Y %b %K %A %k %D %w %h %s"
+
+func new_event(pattern, w, x, y, rx, ry, b, K, A, k, D, width, h, s string) Event {
+	ev := Event{
+		Widget:  w,
+		Pattern: pattern,
+		X:       event_int(x),
+		Y:       event_int(y),
+		RootX:   event_int(rx),
+		RootY:   event_int(ry),
+		Button:  event_int(b),
+		KeySym:  K,
+		Char:    A,
+		KeyCode: event_int(k),
+		Delta:   event_int(D),
+		Width:   event_int(width),
+		Height:  event_int(h),
+		State:   event_int(s),
+	}
+	if K == "??" {
+		ev.KeySym = ""
+		ev.Char = ""
+	}
+	return ev
+}
+
+// Delivers events matching the `pattern` (e.g. "<KeyPress>",
+// "<<ListboxSelect>>") on the `widget` (or a bind tag, like "all") on the
+// returned channel. The binding is added to the existing ones. Events are
+// sent from the interpreter thread, which never blocks on a full channel: if
+// the receiver doesn't keep up with EventsBufferSize pending events, new
+// events are dropped. Call `stop` to remove the binding, the channel is
+// closed afterwards.
+//
+// If the binding can't be installed, the returned channel is closed right
+// away.
+func (ir *Interpreter) Events(widget, pattern string) (events <-chan Event, stop func()) {
+	ch := make(chan Event, EventsBufferSize)
+	var cmd, script string
+	err := ir.ir.run(func() error {
+		cmd = ir.ir.next_command("events")
+		err := ir.ir.register_command(cmd, func(w, x, y, rx, ry, b, K, A, k, D, width, h, s string) {
+			ev := new_event(pattern, w, x, y, rx, ry, b, K, A, k, D, width, h, s)
+			select {
+			case ch <- ev:
+			default:
+			}
+		})
+		if err != nil {
+			return err
+		}
+		script = cmd + " " + event_subst
+		return ir.Eval("bind %{%q} %{%q} %{%q}", widget, pattern, "+"+script)
+	})
+	if err != nil {
+		close(ch)
+		return ch, func() {}
+	}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			ir.ir.run(func() error {
+				ir.Eval(`apply {{tag pattern script} {
+					set lines [split [bind $tag $pattern] "\n"]
+					set i [lsearch -exact $lines $script]
+					if {$i >= 0} {
+						bind $tag $pattern [join [lreplace $lines $i $i] "\n"]
+					}
+				}} %{%q} %{%q} %{%q}`, widget, pattern, script)
+				ir.ir.unregister_command(cmd)
+				close(ch)
+				return nil
+			})
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Evaluates the TCL expression made of `format` and `args` (see Eval) as an
+// integer. The expression is substituted once, as in `expr {...}`, so the
+// values inserted with %{%q} can't inject commands:
+//
+//	x, err := ir.ExprInt("%{} + [winfo width %{%q}] / 2", x0, path)
+func (ir *Interpreter) ExprInt(format string, args ...interface{}) (int, error) {
+	var out int
+	err := ir.expr_as(&out, format, args)
+	return out, err
+}
+
+// Evaluates the TCL expression as a floating-point number, see ExprInt.
+func (ir *Interpreter) ExprFloat(format string, args ...interface{}) (float64, error) {
+	var out float64
+	err := ir.expr_as(&out, format, args)
+	return out, err
+}
+
+// Evaluates the TCL expression as a boolean, see ExprInt.
+func (ir *Interpreter) ExprBool(format string, args ...interface{}) (bool, error) {
+	var out bool
+	err := ir.expr_as(&out, format, args)
+	return out, err
+}
+
+// Makes the Go function `fn` available in TCL expressions as the math
+// function `name`, e.g. `expr {clamp($x, 0, 100)}`, in `expr`, `if`,
+// widget -validatecommand scripts and ExprInt. It's the command
+// `::tcl::mathfunc::name`, the arguments and the result are converted as in
+// RegisterCommand. Unregister it with
+// UnregisterCommand("::tcl::mathfunc::name").
+//
+//	ir.RegisterMathFunc("clamp", func(x, lo, hi float64) float64 {
+//		return math.Max(lo, math.Min(x, hi))
+//	})
+func (ir *Interpreter) RegisterMathFunc(name string, fn interface{}) error {
+	name = "::tcl::mathfunc::" + name
+	site := caller_site(1)
+	return ir.ir.run(func() error {
+		return ir.ir.filt(ir.ir.register_command_at(name, fn, site, nil))
+	})
+}
+
+func (ir *Interpreter) expr_as(out interface{}, format string, args []interface{}) error {
+	// interpreter thread
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		buf := ir.ir.push_cmdbuf()
+		defer ir.ir.pop_cmdbuf()
+		err := sprintf(buf, format, args...)
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		return ir.ir.filt(ir.ir.expr(out, buf.Bytes()))
+	}
+
+	// foreign thread
+	buf := buffer_pool.get()
+	err := sprintf(&buf, format, args...)
+	if err != nil {
+		buffer_pool.put(buf)
+		return ir.ir.filt(err)
+	}
+	expr := buf.Bytes()
+	err = ir.ir.run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.expr(out, expr))
+	})
+	buffer_pool.put(buf)
+	return err
+}
+
+// evaluates the expression with Tcl_ExprObj, converts the result to `out`
+func (ir *interpreter) expr(out interface{}, expr []byte) error {
+	var obj *C.Tcl_Obj
+	if len(expr) == 0 {
+		obj = C.Tcl_NewObj()
+	} else {
+		obj = new_tcl_string_obj(unsafe.String(&expr[0], len(expr)))
+	}
+	C._gotk_c_incr_ref_count(obj)
+	defer C._gotk_c_decr_ref_count(obj)
+
+	var result *C.Tcl_Obj
+	status := C.Tcl_ExprObj(ir.C, obj, &result)
+	if status != C.TCL_OK {
+		return ir.result_error()
+	}
+	defer C._gotk_c_decr_ref_count(result)
+	return ir.tcl_obj_to_go_value(result, reflect.ValueOf(out).Elem())
+}
@@ -0,0 +1,73 @@
+package gothic
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Quit()
+
+	n, err := ir.ExprInt("%{} * [string length %{%q}]", 6, "7 chars")
+	if err != nil {
+		t.Error(err)
+	} else if n != 42 {
+		t.Errorf("%d != 42", n)
+	}
+
+	f, err := ir.ExprFloat("%{} / 4.0", 1)
+	if err != nil {
+		t.Error(err)
+	} else if f != 0.25 {
+		t.Errorf("%v != 0.25", f)
+	}
+
+	// the quoted value is substituted once, the command isn't run
+	b, err := ir.ExprBool("%{%q} eq {[exit]}", "[exit]")
+	if err != nil {
+		t.Error(err)
+	} else if !b {
+		t.Error("expected true")
+	}
+
+	_, err = ir.ExprInt("1 +")
+	must_contain(t, err, "missing operand")
+	_, err = ir.ExprInt("1.5")
+	must_contain(t, err, `expected integer but got "1.5"`)
+}
+
+func TestMathFunc(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		err := ir.RegisterMathFunc("clamp", func(x, lo, hi float64) float64 {
+			return math.Max(lo, math.Min(x, hi))
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var s string
+		err = ir.EvalAs(&s, "set x 150; list [expr {clamp($x, 0, 100)}] [expr {clamp(-1, 0, 100)}]")
+		if err != nil {
+			t.Error(err)
+		} else if s != "100.0 0.0" {
+			t.Errorf("100.0 0.0 != %s", s)
+		}
+		n, err := ir.ExprInt("int(clamp(%{}, 0, 10))", 7)
+		if err != nil {
+			t.Error(err)
+		} else if n != 7 {
+			t.Errorf("%d != 7", n)
+		}
+
+		_, err = ir.ExprFloat("clamp(1)")
+		must_contain(t, err, "wrong # args")
+
+		ir.UnregisterCommand("::tcl::mathfunc::clamp")
+		_, err = ir.ExprFloat("clamp(1, 0, 2)")
+		must_contain(t, err, "clamp")
+	})
+}
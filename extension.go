@@ -0,0 +1,136 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returned by Interpreter.LoadExtension.
+type ExtensionError struct {
+	Path    string // the path given or the file found
+	Package string
+
+	// the library wasn't found, otherwise it was found but `load` failed
+	// (e.g. it's built for another architecture or Tcl version)
+	Missing bool
+
+	// the error reported by `load`, nil if Missing
+	Err error
+}
+
+func (e *ExtensionError) Error() string {
+	if e.Missing {
+		return "gothic: extension " + e.Path + " not found"
+	}
+	return "gothic: failed to load extension " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *ExtensionError) Unwrap() error {
+	return e.Err
+}
+
+// Directories searched by LoadExtension after the directory of the executable
+// and `$auto_path`.
+var ExtensionDirs = []string{"/usr/local/lib", "/usr/lib"}
+
+// Loads the binary TCL extension `path` (a .so/.dll/.dylib) wrapping `load`,
+// `pkgName` is the package name used to find its init function (can be empty
+// to let TCL guess it from the file name). If the `path` is not absolute, it's
+// searched in the directory of the executable, the current directory, the
+// `$auto_path` directories and ExtensionDirs, the platform's shared library
+// extension and the "lib" prefix can be omitted. Failures are reported as
+// *ExtensionError.
+func (ir *Interpreter) LoadExtension(path, pkgName string) error {
+	file, err := ir.find_extension(path)
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		return &ExtensionError{Path: path, Package: pkgName, Missing: true}
+	}
+
+	if pkgName == "" {
+		err = ir.Eval("load %{%q}", file)
+	} else {
+		err = ir.Eval("load %{%q} %{%q}", file, pkgName)
+	}
+	if err != nil {
+		return &ExtensionError{Path: file, Package: pkgName, Err: err}
+	}
+	return nil
+}
+
+func (ir *Interpreter) find_extension(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		if is_file(path) {
+			return path, nil
+		}
+		return "", nil
+	}
+
+	var ext string
+	var autopath []string
+	err := ir.ir.run(func() error {
+		err := ir.EvalAs(&ext, "info sharedlibextension")
+		if err != nil {
+			return err
+		}
+		// a missing auto_path has no directories, an unreadable one is an
+		// error
+		err = ir.Eval("if {[info exists ::auto_path]} {set ::auto_path}")
+		if err != nil {
+			return fmt.Errorf("gothic: can't read auto_path: %w", err)
+		}
+		list, err := Obj{C.Tcl_GetObjResult(ir.ir.C)}.List()
+		if err != nil {
+			return fmt.Errorf("gothic: can't read auto_path: %w", err)
+		}
+		for _, dir := range list {
+			autopath = append(autopath, dir.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var dirs []string
+	if exe, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+	dirs = append(dirs, ".")
+	dirs = append(dirs, autopath...)
+	dirs = append(dirs, ExtensionDirs...)
+
+	names := []string{path}
+	if !strings.HasSuffix(path, ext) {
+		names = append(names, path+ext)
+	}
+	if dir, base := filepath.Split(path); !strings.HasPrefix(base, "lib") {
+		names = append(names, filepath.Join(dir, "lib"+base))
+		if !strings.HasSuffix(base, ext) {
+			names = append(names, filepath.Join(dir, "lib"+base+ext))
+		}
+	}
+
+	for _, dir := range dirs {
+		for _, name := range names {
+			file := filepath.Join(dir, name)
+			if is_file(file) {
+				return filepath.Abs(file)
+			}
+		}
+	}
+	return "", nil
+}
+
+func is_file(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
@@ -36,6 +36,12 @@ func test_error(t *testing.T, gold, format string, args ...interface{}) {
 	must_contain(t, err, gold)
 }
 
+type test_text_marshaler string
+
+func (m test_text_marshaler) MarshalText() ([]byte, error) {
+	return []byte("text:" + m), nil
+}
+
 func TestFormat(t *testing.T) {
 	am := ArgMap{"i": 10, "j": 5}
 	test_format(t, "simple as is %{oops}", "simple as is %{oops}")
@@ -44,7 +50,9 @@ func TestFormat(t *testing.T) {
 	test_format(t, "3.14", "%{%.2f}", 3.1415)
 	test_format(t, "005", "%{j%03d}", am)
 	test_format(t, `"\[command \$variable\]"`, "%{%q}", "[command $variable]")
+	test_format(t, `a "text:\[b\]"`, "%{} %{%m}", test_text_marshaler("a"), test_text_marshaler("[b]"))
 	test_error(t, "missing enclosing bracket", "%{} %{", 10, 5)
+	test_error(t, "requires an encoding.TextMarshaler", "%{%m}", 10)
 	test_error(t, "not-a-number", "%{oops}", 10, 5)
 	test_error(t, "there is no.+index -100", "%{-100}", 1, 2, 3)
 	test_error(t, "there is no.+index 100", "%{100}", 1, 2, 3)
@@ -0,0 +1,87 @@
+package gothic
+
+import (
+	"bytes"
+)
+
+// Gives the keyboard focus to `widget` when the application has the focus,
+// otherwise it becomes the focus window once the application gets it.
+func (ir *Interpreter) Focus(widget string) error {
+	return ir.Eval("focus %{%q}", widget)
+}
+
+// Gives the keyboard focus to `widget` even if the application doesn't have
+// it, taking it away from other applications (`focus -force`).
+func (ir *Interpreter) FocusForce(widget string) error {
+	return ir.Eval("focus -force %{%q}", widget)
+}
+
+// Returns the focus window of the application on the main window's display,
+// empty if the application doesn't have the focus.
+func (ir *Interpreter) FocusGet() (string, error) {
+	var w string
+	err := ir.EvalAs(&w, "focus")
+	return w, err
+}
+
+// Returns the window that had the focus last in the toplevel of `window`,
+// even if the application doesn't have the focus now (`focus -lastfor`).
+func (ir *Interpreter) FocusLast(window string) (string, error) {
+	var w string
+	err := ir.EvalAs(&w, "focus -lastfor %{%q}", window)
+	return w, err
+}
+
+// ::gothic::set_tab_order widgets
+//
+// Makes Tab and Shift-Tab move the focus through `widgets` in the given
+// order, wrapping around. Each widget gets a bind tag in front of its other
+// tags, the bindings break the default traversal.
+const focus_script = `
+namespace eval ::gothic {
+	proc set_tab_order {widgets} {
+		set n [llength $widgets]
+		for {set i 0} {$i < $n} {incr i} {
+			set w [lindex $widgets $i]
+			set tag gothic::taborder::$w
+			if {[lsearch -exact [bindtags $w] $tag] == -1} {
+				bindtags $w [linsert [bindtags $w] 0 $tag]
+			}
+			bind $tag <Key-Tab> [list ::gothic::tab_to [lindex $widgets [expr {($i + 1) % $n}]]]
+			bind $tag <<PrevWindow>> [list ::gothic::tab_to [lindex $widgets [expr {($i - 1 + $n) % $n}]]]
+		}
+	}
+
+	proc tab_to {w} {
+		if {[winfo exists $w]} {
+			tk::TabToWindow $w
+		}
+		return -code break
+	}
+}
+`
+
+// Sets the keyboard traversal order: Tab moves the focus from each widget
+// to the next one, Shift-Tab to the previous one, wrapping around. Tk
+// traverses widgets in the stacking order of siblings by default, this
+// order can cross containers. Entries get their contents selected when
+// tabbed into, like with the default traversal.
+func (ir *Interpreter) SetTabOrder(widgets ...string) error {
+	if len(widgets) == 0 {
+		return nil
+	}
+	return ir.ir.run(func() error {
+		err := ir.ir.eval([]byte(focus_script))
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		var buf bytes.Buffer
+		buf.WriteString("::gothic::set_tab_order [list")
+		for _, w := range widgets {
+			buf.WriteString(" ")
+			quote(&buf, w)
+		}
+		buf.WriteString("]")
+		return ir.ir.filt(ir.ir.eval(buf.Bytes()))
+	})
+}
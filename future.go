@@ -0,0 +1,168 @@
+package gothic
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ::gothic::await token
+//
+// Waits for the future to complete and returns its value (or raises its
+// error). Within a coroutine it yields, otherwise it enters the event loop
+// via vwait.
+//
+// ::gothic::then token onok ?onerror?
+//
+// Invokes `{*}$onok $value` or `{*}$onerror $message` at the global level when
+// the future completes. Without `onerror`, errors become background errors.
+//
+// A completed future delivers its result once: to the waiters registered
+// before the completion or to the first waiter registered afterwards.
+const future_script = `
+namespace eval ::gothic {
+	variable futures
+	variable future_waiters
+	array set futures {}
+	array set future_waiters {}
+
+	proc future_complete {token status value} {
+		variable futures
+		variable future_waiters
+		if {![info exists future_waiters($token)]} {
+			set futures($token) [list $status $value]
+			return
+		}
+		foreach cmd $future_waiters($token) {
+			after 0 [list {*}$cmd $status $value]
+		}
+		unset future_waiters($token)
+	}
+
+	proc future_wait {token cmd} {
+		variable futures
+		variable future_waiters
+		if {[info exists futures($token)]} {
+			after 0 [list {*}$cmd {*}$futures($token)]
+			unset futures($token)
+			return
+		}
+		lappend future_waiters($token) $cmd
+	}
+
+	proc future_resume {coro status value} {
+		$coro [list $status $value]
+	}
+
+	proc future_set {var status value} {
+		set $var [list $status $value]
+	}
+
+	proc future_then {onok onerror status value} {
+		if {$status eq "ok"} {
+			uplevel #0 [list {*}$onok $value]
+		} elseif {$onerror ne ""} {
+			uplevel #0 [list {*}$onerror $value]
+		} else {
+			return -code error $value
+		}
+	}
+
+	proc then {token onok {onerror {}}} {
+		future_wait $token [list ::gothic::future_then $onok $onerror]
+	}
+
+	proc await {token} {
+		if {[catch {info coroutine} coro] || $coro eq ""} {
+			set var ::gothic::future_result($token)
+			future_wait $token [list ::gothic::future_set $var]
+			vwait $var
+			set result [set $var]
+			unset $var
+		} else {
+			future_wait $token [list ::gothic::future_resume $coro]
+			set result [yield]
+		}
+		lassign $result status value
+		if {$status ne "ok"} {
+			return -code error $value
+		}
+		return $value
+	}
+}
+`
+
+func (ir *interpreter) init_futures() error {
+	return ir.eval([]byte(future_script))
+}
+
+var last_future_id uint64
+
+// A result which is computed later, from any goroutine. A command registered
+// with RegisterCommand can return a *Future, TCL gets a token which can be
+// passed to `::gothic::await` (coroutine-friendly) or `::gothic::then`
+// (callback continuation). This lets TCL scripts call slow Go operations
+// without freezing the UI:
+//
+//	ir.RegisterCommand("fetch", func(url string) *gothic.Future {
+//	        f := ir.NewFuture()
+//	        go func() {
+//	                body, err := fetch(url)
+//	                if err != nil {
+//	                        f.Reject(err)
+//	                        return
+//	                }
+//	                f.Resolve(body)
+//	        }()
+//	        return f
+//	})
+//
+//	coroutine c apply {{} {
+//	        .text insert end [::gothic::await [fetch http://example.com]]
+//	}}
+type Future struct {
+	ir    *Interpreter
+	token string
+	once  sync.Once
+}
+
+// Creates a new pending future.
+func (ir *Interpreter) NewFuture() *Future {
+	id := atomic.AddUint64(&last_future_id, 1)
+	return &Future{ir: ir, token: "gothic::future" + strconv.FormatUint(id, 10)}
+}
+
+// Returns the token identifying the future in TCL.
+func (f *Future) String() string {
+	return f.token
+}
+
+// Implements encoding.TextMarshaler, futures are converted to their tokens.
+func (f *Future) MarshalText() ([]byte, error) {
+	return []byte(f.token), nil
+}
+
+// Completes the future with the `value`. Only the first Resolve or Reject
+// call has an effect.
+func (f *Future) Resolve(value interface{}) error {
+	return f.complete("ok", value)
+}
+
+// Completes the future with the error `err`. Only the first Resolve or
+// Reject call has an effect.
+func (f *Future) Reject(err error) error {
+	return f.complete("error", err.Error())
+}
+
+func (f *Future) complete(status string, value interface{}) (err error) {
+	f.once.Do(func() {
+		err = f.ir.ir.run(func() error {
+			err := f.ir.ir.set_var("::gothic::future_value", value, GlobalOnly)
+			if err != nil {
+				return f.ir.ir.filt(err)
+			}
+			return f.ir.Eval("::gothic::future_complete %{%q} %{%q} $::gothic::future_value", f.token, status)
+		})
+	})
+	return err
+}
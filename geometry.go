@@ -0,0 +1,170 @@
+package gothic
+
+import (
+	"bytes"
+)
+
+// Fluent helpers for the geometry managers, e.g.:
+//
+//  err := gothic.Grid(".f.entry").Row(1).Col(2).Sticky("ew").ColSpan(2).Apply(ir)
+
+type geometry struct {
+	manager string
+	windows []string
+	opts    map[string]interface{}
+}
+
+func new_geometry(manager string, windows []string) geometry {
+	return geometry{manager, windows, make(map[string]interface{})}
+}
+
+func (g *geometry) script() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(g.manager)
+	buf.WriteString(" configure")
+	for _, w := range g.windows {
+		buf.WriteString(" ")
+		quote(&buf, w)
+	}
+	write_map_options(&buf, g.opts)
+	return buf.Bytes()
+}
+
+//------------------------------------------------------------------------------
+// grid
+//------------------------------------------------------------------------------
+
+// Options for `grid configure`, see Grid.
+type GridBuilder struct {
+	g geometry
+}
+
+// Starts building a `grid configure` command for the `windows`.
+func Grid(windows ...string) *GridBuilder {
+	return &GridBuilder{new_geometry("grid", windows)}
+}
+
+func (b *GridBuilder) set(name string, value interface{}) *GridBuilder {
+	b.g.opts[name] = value
+	return b
+}
+
+func (b *GridBuilder) Row(row int) *GridBuilder         { return b.set("row", row) }
+func (b *GridBuilder) Col(col int) *GridBuilder         { return b.set("column", col) }
+func (b *GridBuilder) RowSpan(n int) *GridBuilder       { return b.set("rowspan", n) }
+func (b *GridBuilder) ColSpan(n int) *GridBuilder       { return b.set("columnspan", n) }
+func (b *GridBuilder) Sticky(sides string) *GridBuilder { return b.set("sticky", sides) }
+func (b *GridBuilder) PadX(pad int) *GridBuilder        { return b.set("padx", pad) }
+func (b *GridBuilder) PadY(pad int) *GridBuilder        { return b.set("pady", pad) }
+func (b *GridBuilder) IPadX(pad int) *GridBuilder       { return b.set("ipadx", pad) }
+func (b *GridBuilder) IPadY(pad int) *GridBuilder       { return b.set("ipady", pad) }
+func (b *GridBuilder) In(master string) *GridBuilder    { return b.set("in", master) }
+
+// Returns the resulting TCL script.
+func (b *GridBuilder) String() string {
+	return string(b.g.script())
+}
+
+// Evaluates the resulting script in the interpreter.
+func (b *GridBuilder) Apply(ir *Interpreter) error {
+	return ir.EvalBytes(b.g.script())
+}
+
+// Sets weights of the rows and columns of the grid `master`: rows[i] is the
+// weight of the row i, columns[i] is the weight of the column i.
+func (ir *Interpreter) GridConfigureWeights(master string, rows, columns []int) error {
+	var buf bytes.Buffer
+	for i, w := range rows {
+		buf.WriteString("grid rowconfigure ")
+		quote(&buf, master)
+		sprintf(&buf, " %{} -weight %{}\n", i, w)
+	}
+	for i, w := range columns {
+		buf.WriteString("grid columnconfigure ")
+		quote(&buf, master)
+		sprintf(&buf, " %{} -weight %{}\n", i, w)
+	}
+	return ir.EvalBytes(buf.Bytes())
+}
+
+//------------------------------------------------------------------------------
+// pack
+//------------------------------------------------------------------------------
+
+// Options for `pack configure`, see Pack.
+type PackBuilder struct {
+	g geometry
+}
+
+// Starts building a `pack configure` command for the `windows`.
+func Pack(windows ...string) *PackBuilder {
+	return &PackBuilder{new_geometry("pack", windows)}
+}
+
+func (b *PackBuilder) set(name string, value interface{}) *PackBuilder {
+	b.g.opts[name] = value
+	return b
+}
+
+func (b *PackBuilder) Side(side string) *PackBuilder     { return b.set("side", side) }
+func (b *PackBuilder) Fill(fill string) *PackBuilder     { return b.set("fill", fill) }
+func (b *PackBuilder) Expand(expand bool) *PackBuilder   { return b.set("expand", expand) }
+func (b *PackBuilder) Anchor(anchor string) *PackBuilder { return b.set("anchor", anchor) }
+func (b *PackBuilder) PadX(pad int) *PackBuilder         { return b.set("padx", pad) }
+func (b *PackBuilder) PadY(pad int) *PackBuilder         { return b.set("pady", pad) }
+func (b *PackBuilder) IPadX(pad int) *PackBuilder        { return b.set("ipadx", pad) }
+func (b *PackBuilder) IPadY(pad int) *PackBuilder        { return b.set("ipady", pad) }
+func (b *PackBuilder) In(master string) *PackBuilder     { return b.set("in", master) }
+func (b *PackBuilder) Before(window string) *PackBuilder { return b.set("before", window) }
+func (b *PackBuilder) After(window string) *PackBuilder  { return b.set("after", window) }
+
+// Returns the resulting TCL script.
+func (b *PackBuilder) String() string {
+	return string(b.g.script())
+}
+
+// Evaluates the resulting script in the interpreter.
+func (b *PackBuilder) Apply(ir *Interpreter) error {
+	return ir.EvalBytes(b.g.script())
+}
+
+//------------------------------------------------------------------------------
+// place
+//------------------------------------------------------------------------------
+
+// Options for `place configure`, see Place.
+type PlaceBuilder struct {
+	g geometry
+}
+
+// Starts building a `place configure` command for the `windows`.
+func Place(windows ...string) *PlaceBuilder {
+	return &PlaceBuilder{new_geometry("place", windows)}
+}
+
+func (b *PlaceBuilder) set(name string, value interface{}) *PlaceBuilder {
+	b.g.opts[name] = value
+	return b
+}
+
+func (b *PlaceBuilder) X(x int) *PlaceBuilder                { return b.set("x", x) }
+func (b *PlaceBuilder) Y(y int) *PlaceBuilder                { return b.set("y", y) }
+func (b *PlaceBuilder) RelX(x float64) *PlaceBuilder         { return b.set("relx", x) }
+func (b *PlaceBuilder) RelY(y float64) *PlaceBuilder         { return b.set("rely", y) }
+func (b *PlaceBuilder) Width(w int) *PlaceBuilder            { return b.set("width", w) }
+func (b *PlaceBuilder) Height(h int) *PlaceBuilder           { return b.set("height", h) }
+func (b *PlaceBuilder) RelWidth(w float64) *PlaceBuilder     { return b.set("relwidth", w) }
+func (b *PlaceBuilder) RelHeight(h float64) *PlaceBuilder    { return b.set("relheight", h) }
+func (b *PlaceBuilder) Anchor(anchor string) *PlaceBuilder   { return b.set("anchor", anchor) }
+func (b *PlaceBuilder) BorderMode(mode string) *PlaceBuilder { return b.set("bordermode", mode) }
+func (b *PlaceBuilder) In(master string) *PlaceBuilder       { return b.set("in", master) }
+
+// Returns the resulting TCL script.
+func (b *PlaceBuilder) String() string {
+	return string(b.g.script())
+}
+
+// Evaluates the resulting script in the interpreter.
+func (b *PlaceBuilder) Apply(ir *Interpreter) error {
+	return ir.EvalBytes(b.g.script())
+}
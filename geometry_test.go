@@ -0,0 +1,13 @@
+package gothic
+
+import (
+	"testing"
+)
+
+func TestGridBuilder(t *testing.T) {
+	s := Grid(".f.entry").Row(1).Col(2).Sticky("ew").ColSpan(2).String()
+	gold := `grid configure ".f.entry" -column "2" -columnspan "2" -row "1" -sticky "ew"`
+	if s != gold {
+		t.Errorf("%s != %s", gold, s)
+	}
+}
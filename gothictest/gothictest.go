@@ -0,0 +1,418 @@
+// Package gothictest provides a fake interpreter for unit testing code built
+// on top of gothic without a running Tcl/Tk interpreter or a display.
+//
+// The fake doesn't evaluate anything: it records formatted scripts, replies
+// with canned results and dispatches registered commands synchronously when
+// asked to. Application code should depend on the Interp interface (or a
+// narrower one of its own) instead of *gothic.Interpreter to be testable.
+//
+// The package doesn't import gothic and builds without cgo, so such tests
+// run where Tcl/Tk isn't installed, e.g. with CGO_ENABLED=0 in CI.
+package gothictest
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nsf/gothic/internal/script"
+)
+
+// The subset of the *gothic.Interpreter method set implemented by the fake.
+type Interp interface {
+	Eval(format string, args ...interface{}) error
+	EvalBytes(s []byte) error
+	EvalAs(out interface{}, format string, args ...interface{}) error
+	Set(name string, val interface{}) error
+	SetVar(name string, val interface{}, flags VarFlags) error
+	GetVar(out interface{}, name string, flags VarFlags) error
+	ErrorFilter(filt func(error) error)
+	UploadImage(name string, img image.Image) error
+	RegisterCommand(name string, cbfunc interface{}, opts ...CommandOpts) error
+	RegisterCommands(name string, val interface{}) error
+	UnregisterCommand(name string) error
+	UnregisterCommands(name string) error
+}
+
+var _ Interp = (*Interpreter)(nil)
+
+// The same types as gothic.VarFlags and gothic.CommandOpts, so that tests
+// don't need to import gothic, which requires cgo and Tcl/Tk.
+type (
+	VarFlags    = script.VarFlags
+	CommandOpts = script.CommandOpts
+)
+
+// The same flags as gothic.GlobalOnly and friends.
+const (
+	GlobalOnly    = script.GlobalOnly
+	NamespaceOnly = script.NamespaceOnly
+	AppendValue   = script.AppendValue
+	ListElement   = script.ListElement
+)
+
+type response struct {
+	re     *regexp.Regexp
+	result string
+	err    error
+}
+
+// A fake interpreter. All methods are safe to call from different goroutines.
+type Interpreter struct {
+	mu        sync.Mutex
+	scripts   []string
+	responses []response
+	vars      map[string]interface{}
+	images    map[string]image.Image
+	commands  map[string]interface{}
+	methods   map[string]interface{}
+	errfilt   func(error) error
+}
+
+// Creates a new fake interpreter.
+func New() *Interpreter {
+	return &Interpreter{
+		vars:     make(map[string]interface{}),
+		images:   make(map[string]image.Image),
+		commands: make(map[string]interface{}),
+		methods:  make(map[string]interface{}),
+	}
+}
+
+// Makes scripts matching the regular expression `pattern` return `result`
+// and `err`. Responses are matched in the order they were added, scripts
+// which don't match any response succeed with an empty result.
+func (f *Interpreter) Respond(pattern, result string, err error) {
+	re := regexp.MustCompile(pattern)
+	f.mu.Lock()
+	f.responses = append(f.responses, response{re, result, err})
+	f.mu.Unlock()
+}
+
+// Returns all scripts evaluated so far.
+func (f *Interpreter) Scripts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.scripts...)
+}
+
+// Returns the last evaluated script or an empty string if there were none.
+func (f *Interpreter) LastScript() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.scripts) == 0 {
+		return ""
+	}
+	return f.scripts[len(f.scripts)-1]
+}
+
+// Forgets all evaluated scripts.
+func (f *Interpreter) Reset() {
+	f.mu.Lock()
+	f.scripts = nil
+	f.mu.Unlock()
+}
+
+// Returns the value of the variable `name` as it was passed to Set or SetVar.
+func (f *Interpreter) Var(name string) (interface{}, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.vars[name]
+	return v, ok
+}
+
+// Returns the image uploaded under the `name`.
+func (f *Interpreter) Image(name string) (image.Image, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	img, ok := f.images[name]
+	return img, ok
+}
+
+// Invokes the registered command `name` synchronously, converting `args` to
+// the types of the handler arguments. Commands registered via
+// RegisterCommands are available as "name::Method".
+func (f *Interpreter) Invoke(name string, args ...string) error {
+	f.mu.Lock()
+	cb, ok := f.commands[name]
+	var recv interface{}
+	var method reflect.Value
+	if !ok {
+		if i := strings.LastIndex(name, "::"); i != -1 {
+			if val, ok := f.methods[name[:i]]; ok {
+				recv = val
+				method = find_method(val, name[i+2:])
+			}
+		}
+	}
+	f.mu.Unlock()
+
+	var fn reflect.Value
+	var in []reflect.Value
+	switch {
+	case ok:
+		fn = reflect.ValueOf(cb)
+	case method.IsValid():
+		fn = method
+		in = append(in, reflect.ValueOf(recv))
+	default:
+		return f.filt(fmt.Errorf("invalid command name %q", name))
+	}
+
+	// like in gothic, trailing pointer arguments are optional and the
+	// variadic argument takes the rest
+	ft := fn.Type()
+	off := len(in)
+	n := ft.NumIn()
+	if ft.IsVariadic() {
+		n--
+	}
+	required := 0
+	for i := off; i < n; i++ {
+		if ft.In(i).Kind() != reflect.Ptr {
+			required = i - off + 1
+		}
+	}
+	if len(args) < required || !ft.IsVariadic() && len(args) > n-off {
+		return f.filt(fmt.Errorf("wrong # args for %q", name))
+	}
+	for i := off; i < n; i++ {
+		v := reflect.New(ft.In(i)).Elem()
+		if i-off < len(args) {
+			err := convert_arg(args[i-off], v)
+			if err != nil {
+				return f.filt(err)
+			}
+		}
+		in = append(in, v)
+	}
+	if ft.IsVariadic() {
+		var rest []string
+		if len(args) > n-off {
+			rest = args[n-off:]
+		}
+		v := reflect.MakeSlice(ft.In(n), len(rest), len(rest))
+		for i, arg := range rest {
+			err := convert_arg(arg, v.Index(i))
+			if err != nil {
+				return f.filt(err)
+			}
+		}
+		fn.CallSlice(append(in, v))
+		return nil
+	}
+	fn.Call(in)
+	return nil
+}
+
+func convert_arg(s string, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		err := convert(s, p.Elem())
+		if err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	return convert(s, v)
+}
+
+func find_method(val interface{}, sub string) reflect.Value {
+	t := reflect.TypeOf(val)
+	for _, prefix := range []string{"TCL_", "TCL"} {
+		if m, ok := t.MethodByName(prefix + sub); ok {
+			return m.Func
+		}
+	}
+	return reflect.Value{}
+}
+
+func (f *Interpreter) filt(err error) error {
+	f.mu.Lock()
+	filt := f.errfilt
+	f.mu.Unlock()
+	if filt != nil {
+		return filt(err)
+	}
+	return err
+}
+
+func (f *Interpreter) eval(script string) (string, error) {
+	f.mu.Lock()
+	f.scripts = append(f.scripts, script)
+	for _, r := range f.responses {
+		if r.re.MatchString(script) {
+			f.mu.Unlock()
+			return r.result, r.err
+		}
+	}
+	f.mu.Unlock()
+	return "", nil
+}
+
+// Records the formatted script.
+func (f *Interpreter) Eval(format string, args ...interface{}) error {
+	s, err := script.Sprintf(format, args...)
+	if err != nil {
+		return f.filt(err)
+	}
+	_, err = f.eval(s)
+	return f.filt(err)
+}
+
+// Records the script.
+func (f *Interpreter) EvalBytes(s []byte) error {
+	_, err := f.eval(string(s))
+	return f.filt(err)
+}
+
+// Records the formatted script and writes the canned result into `out`.
+// Only basic types (strings, numbers and booleans) are supported.
+func (f *Interpreter) EvalAs(out interface{}, format string, args ...interface{}) error {
+	pv := reflect.ValueOf(out)
+	if pv.Kind() != reflect.Ptr || pv.IsNil() {
+		panic("gothictest: EvalAs expected a non-nil pointer argument")
+	}
+	s, err := script.Sprintf(format, args...)
+	if err != nil {
+		return f.filt(err)
+	}
+	result, err := f.eval(s)
+	if err != nil {
+		return f.filt(err)
+	}
+	return f.filt(convert(result, pv.Elem()))
+}
+
+// Stores the variable, see Var.
+func (f *Interpreter) Set(name string, val interface{}) error {
+	f.mu.Lock()
+	f.vars[name] = val
+	f.mu.Unlock()
+	return nil
+}
+
+// Stores the variable, flags are ignored.
+func (f *Interpreter) SetVar(name string, val interface{}, flags VarFlags) error {
+	return f.Set(name, val)
+}
+
+// Writes the value of a variable stored by Set or SetVar into `out`, flags
+// are ignored.
+func (f *Interpreter) GetVar(out interface{}, name string, flags VarFlags) error {
+	pv := reflect.ValueOf(out)
+	if pv.Kind() != reflect.Ptr || pv.IsNil() {
+		panic("gothictest: GetVar expected a non-nil pointer argument")
+	}
+	val, ok := f.Var(name)
+	if !ok {
+		return f.filt(fmt.Errorf("can't read %q: no such variable", name))
+	}
+	return f.filt(convert(fmt.Sprint(val), pv.Elem()))
+}
+
+// Sets the error filter, see gothic.Interpreter.ErrorFilter.
+func (f *Interpreter) ErrorFilter(filt func(error) error) {
+	f.mu.Lock()
+	f.errfilt = filt
+	f.mu.Unlock()
+}
+
+// Stores the image, see Image.
+func (f *Interpreter) UploadImage(name string, img image.Image) error {
+	f.mu.Lock()
+	f.images[name] = img
+	f.mu.Unlock()
+	return nil
+}
+
+// Registers a command which can be invoked using Invoke. The options are
+// ignored.
+func (f *Interpreter) RegisterCommand(name string, cbfunc interface{}, opts ...CommandOpts) error {
+	if reflect.TypeOf(cbfunc).Kind() != reflect.Func {
+		return errors.New("gothic: RegisterCommand only accepts func type as a second argument")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.commands[name]; ok {
+		return errors.New("gothic: command with the same name was already registered")
+	}
+	f.commands[name] = cbfunc
+	return nil
+}
+
+// Registers a method set which can be invoked using Invoke.
+func (f *Interpreter) RegisterCommands(name string, val interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.methods[name]; ok {
+		return errors.New("gothic: method set with the same name was already registered")
+	}
+	f.methods[name] = val
+	return nil
+}
+
+// Unregisters a command.
+func (f *Interpreter) UnregisterCommand(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.commands[name]; !ok {
+		return errors.New("gothic: trying to unregister a non-existent command")
+	}
+	delete(f.commands, name)
+	return nil
+}
+
+// Unregisters a method set.
+func (f *Interpreter) UnregisterCommands(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.methods[name]; !ok {
+		return errors.New("gothic: trying to unregister a non-existent method set")
+	}
+	delete(f.methods, name)
+	return nil
+}
+
+func convert(s string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return fmt.Errorf("expected integer but got %q", s)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return fmt.Errorf("expected integer but got %q", s)
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("expected floating-point number but got %q", s)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		switch strings.ToLower(s) {
+		case "1", "true", "yes", "on":
+			v.SetBool(true)
+		case "0", "false", "no", "off":
+			v.SetBool(false)
+		default:
+			return fmt.Errorf("expected boolean value but got %q", s)
+		}
+	default:
+		return fmt.Errorf("gothictest: cannot convert result to Go type: %s", v.Type())
+	}
+	return nil
+}
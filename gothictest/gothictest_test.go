@@ -0,0 +1,98 @@
+package gothictest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type methods struct {
+	called string
+}
+
+func (m *methods) TCL_Hello(name string) {
+	m.called = "hello " + name
+}
+
+func TestEval(t *testing.T) {
+	ir := New()
+	ir.Respond(`^winfo width`, "640", nil)
+	ir.Respond(`^boom`, "", errors.New("boom"))
+
+	err := ir.Eval(".l configure -text %{%q}", "[x]")
+	if err != nil {
+		t.Error(err)
+	}
+	if s := ir.LastScript(); s != `.l configure -text "\[x\]"` {
+		t.Errorf("unexpected script: %s", s)
+	}
+
+	var w int
+	err = ir.EvalAs(&w, "winfo width %{}", ".")
+	if err != nil || w != 640 {
+		t.Errorf("unexpected result: %d, %v", w, err)
+	}
+
+	err = ir.Eval("boom")
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if n := len(ir.Scripts()); n != 3 {
+		t.Errorf("%d scripts recorded instead of 3", n)
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	ir := New()
+
+	var sum int
+	ir.RegisterCommand("add", func(a, b int) { sum = a + b })
+	err := ir.Invoke("add", "2", "3")
+	if err != nil || sum != 5 {
+		t.Errorf("unexpected result: %d, %v", sum, err)
+	}
+	err = ir.Invoke("add", "x", "1")
+	if err == nil {
+		t.Error("non-nil error expected")
+	}
+	err = ir.Invoke("add", "1")
+	if err == nil {
+		t.Error("wrong # args error expected")
+	}
+
+	var joined string
+	ir.RegisterCommand("join", func(sep *string, parts ...string) {
+		joined = strings.Join(parts, *sep)
+	})
+	err = ir.Invoke("join", ",", "a", "b")
+	if err != nil || joined != "a,b" {
+		t.Errorf("unexpected result: %q, %v", joined, err)
+	}
+
+	m := new(methods)
+	ir.RegisterCommands("ns", m)
+	err = ir.Invoke("ns::Hello", "world")
+	if err != nil || m.called != "hello world" {
+		t.Errorf("unexpected result: %q, %v", m.called, err)
+	}
+
+	err = ir.Invoke("nonexistent")
+	if err == nil {
+		t.Error("non-nil error expected")
+	}
+}
+
+func TestVars(t *testing.T) {
+	ir := New()
+	ir.Set("x", 42)
+	var x int
+	err := ir.GetVar(&x, "x", 0)
+	if err != nil || x != 42 {
+		t.Errorf("unexpected result: %d, %v", x, err)
+	}
+	err = ir.GetVar(&x, "y", 0)
+	if err == nil {
+		t.Error("non-nil error expected")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !go1.21
+
+package gothic
+
+// gothic needs Go 1.21 or newer: UploadImage pins the pixels it passes to Tk
+// with runtime.Pinner, strings are shared with TCL using unsafe.String and
+// unsafe.StringData (Go 1.20). Command and event client data are cgo.Handle
+// values, which need no pinning. This declaration fails to compile with older
+// versions so that the reason is in the error message.
+var _ = gothic_requires_go_1_21_or_newer
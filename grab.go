@@ -0,0 +1,63 @@
+package gothic
+
+// Sets a grab on `window`: pointer and keyboard events go to the window and
+// its descendants only. A local grab affects the windows of this
+// application, a global one all the applications on the display.
+func (ir *Interpreter) GrabSet(window string, global bool) error {
+	if global {
+		return ir.Eval("grab set -global %{%q}", window)
+	}
+	return ir.Eval("grab set %{%q}", window)
+}
+
+// Releases the grab on `window`, if any.
+func (ir *Interpreter) GrabRelease(window string) error {
+	return ir.Eval("grab release %{%q}", window)
+}
+
+// Returns the window holding the grab in the application, empty if there is
+// no grab.
+func (ir *Interpreter) GrabCurrent() (string, error) {
+	var w string
+	err := ir.EvalAs(&w, "grab current")
+	return w, err
+}
+
+// ::gothic::run_modal w
+//
+// Waits until `w` is viewable, sets a local grab on it and gives it the
+// focus, then waits until it's destroyed. The previous grab and focus are
+// restored afterwards, the same way Tk's own dialogs do it.
+const grab_script = `
+proc ::gothic::run_modal {w} {
+	if {![winfo viewable $w]} {
+		tkwait visibility $w
+	}
+	::tk::SetFocusGrab $w $w
+	tkwait window $w
+	::tk::RestoreFocusGrab $w $w destroy
+}
+`
+
+// Runs `window` (usually a toplevel) as a modal dialog: sets a local grab on
+// it, gives it the focus and waits until it's destroyed, events keep being
+// processed meanwhile. The previous grab and focus are restored then and
+// `done` (if not nil) is invoked on the interpreter thread, e.g. to read the
+// variables the dialog has set before other code changes them. Called from
+// another goroutine, it blocks it until the dialog is closed.
+func (ir *Interpreter) RunModal(window string, done func()) error {
+	return ir.ir.run(func() error {
+		err := ir.ir.eval([]byte(grab_script))
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		err = ir.Eval("::gothic::run_modal %{%q}", window)
+		if err != nil {
+			return err
+		}
+		if done != nil {
+			done()
+		}
+		return nil
+	})
+}
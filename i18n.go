@@ -0,0 +1,88 @@
+package gothic
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/nsf/gothic/internal/script"
+	"golang.org/x/text/language"
+)
+
+// A msgcat source string, translated when the script runs. It can be passed
+// to Interpreter.Eval and friends, it's formatted as a msgcat lookup
+// regardless of the tag format, e.g.
+//
+//	ir.Eval("button .save -text %{}", gothic.T("Save"))
+//
+// becomes `button .save -text [::gothic::mc "Save"]`.
+type T = script.T
+
+// Converts a language tag to the msgcat locale form: "en-US" -> "en_us".
+func msgcat_locale(tag language.Tag) string {
+	return strings.ToLower(strings.ReplaceAll(tag.String(), "-", "_"))
+}
+
+// Sets the msgcat locale used for translations (`msgcat::mclocale`).
+func (ir *Interpreter) SetLocale(tag language.Tag) error {
+	return ir.Eval("package require msgcat; ::msgcat::mclocale %{%q}", msgcat_locale(tag))
+}
+
+// Returns the msgcat locale, e.g. "en_us".
+func (ir *Interpreter) Locale() (string, error) {
+	var locale string
+	err := ir.EvalAs(&locale, "package require msgcat; ::msgcat::mclocale")
+	return locale, err
+}
+
+// Loads the msgcat message catalogs (*.msg files) from the directory `dir`
+// of `fsys`. Unlike `msgcat::mcload`, the catalogs of all the locales are
+// loaded, so that the locale can be changed later. The files are evaluated
+// in the global namespace, the translations are visible to all the
+// namespaces and to Interpreter.T.
+func (ir *Interpreter) LoadTranslations(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.msg"))
+	if err != nil {
+		return err
+	}
+	return ir.ir.run(func() error {
+		err := ir.ir.eval([]byte("package require msgcat"))
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		for _, file := range files {
+			data, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			buf.WriteString("namespace eval :: ")
+			quote(&buf, string(data))
+			err = ir.ir.eval(buf.Bytes())
+			if err != nil {
+				return ir.ir.filt(err)
+			}
+		}
+		return nil
+	})
+}
+
+// Translates `src` using msgcat in the current locale, `args` are
+// substituted into the translation the same way `format` does it. Returns
+// `src` if the lookup fails.
+func (ir *Interpreter) T(src string, args ...interface{}) string {
+	var buf bytes.Buffer
+	buf.WriteString("::gothic::mc ")
+	quote(&buf, src)
+	for _, arg := range args {
+		buf.WriteString(" ")
+		quote_value(&buf, arg)
+	}
+	var s string
+	err := ir.EvalAs(&s, buf.String())
+	if err != nil {
+		return src
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package gothic
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/text/language"
+)
+
+func TestTranslations(t *testing.T) {
+	s, err := Sprintf("button .b -text %{%q}", T("Save [all]"))
+	if err != nil {
+		t.Error(err)
+	} else if gold := `button .b -text [::gothic::mc "Save \[all\]"]`; s != gold {
+		t.Errorf("%s != %s", s, gold)
+	}
+
+	msgs := fstest.MapFS{
+		"msgs/de.msg": {Data: []byte(`
+			::msgcat::mcset de Save Speichern
+			::msgcat::mcset de "%d files" "%d Dateien"
+		`)},
+		"msgs/fr.msg": {Data: []byte("::msgcat::mcset fr Save Enregistrer")},
+	}
+	NewTclInterpreter(func(ir *Interpreter) {
+		err := ir.LoadTranslations(msgs, "msgs")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = ir.SetLocale(language.MustParse("de-AT"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if locale, _ := ir.Locale(); locale != "de_at" {
+			t.Errorf("%s != de_at", locale)
+		}
+		if s := ir.T("Save"); s != "Speichern" {
+			t.Errorf("%s != Speichern", s)
+		}
+		if s := ir.T("%d files", 3); s != "3 Dateien" {
+			t.Errorf("%s != 3 Dateien", s)
+		}
+		if s := ir.T("Open"); s != "Open" {
+			t.Errorf("%s != Open", s)
+		}
+
+		var s string
+		err = ir.EvalAs(&s, "namespace eval ::app { set x %{} }", T("Save"))
+		if err != nil {
+			t.Error(err)
+		} else if s != "Speichern" {
+			t.Errorf("%s != Speichern", s)
+		}
+
+		ir.SetLocale(language.French)
+		if s := ir.T("Save"); s != "Enregistrer" {
+			t.Errorf("%s != Enregistrer", s)
+		}
+	})
+}
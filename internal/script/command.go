@@ -0,0 +1,20 @@
+package script
+
+import (
+	"fmt"
+)
+
+// See gothic.CommandOpts.
+type CommandOpts struct {
+	OnError func(name string, err error) error
+}
+
+// See gothic.CommandPanicError.
+type CommandPanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // the stack of the panicking goroutine
+}
+
+func (e *CommandPanicError) Error() string {
+	return fmt.Sprintf("gothic: command panicked: %v", e.Value)
+}
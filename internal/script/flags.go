@@ -0,0 +1,11 @@
+package script
+
+// See gothic.VarFlags, the values are those of the TCL_* flags.
+type VarFlags int
+
+const (
+	GlobalOnly    VarFlags = 1 // TCL_GLOBAL_ONLY
+	NamespaceOnly VarFlags = 2 // TCL_NAMESPACE_ONLY
+	AppendValue   VarFlags = 4 // TCL_APPEND_VALUE
+	ListElement   VarFlags = 8 // TCL_LIST_ELEMENT
+)
@@ -0,0 +1,274 @@
+// Package script holds the pure-Go parts of gothic shared with the
+// gothictest fake, which must build without cgo: the script formatter used
+// by Interpreter.Eval and friends and the types of the API that don't depend
+// on TCL.
+package script
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// See gothic.ArgMap.
+type ArgMap map[string]interface{}
+
+func split_tag(tag string) (abbrev, format string) {
+	abbrev = tag
+	format = ""
+	if i := strings.Index(tag, "%"); i != -1 {
+		abbrev = tag[:i]
+		format = tag[i:]
+	}
+	return
+}
+
+func write_arg_quoted(buf *bytes.Buffer, arg interface{}) error {
+	switch a := arg.(type) {
+	case string:
+		Quote(buf, a)
+	case error:
+		Quote(buf, a.Error())
+	case fmt.Stringer:
+		Quote(buf, a.String())
+	case color.Color:
+		Quote(buf, FormatColor(a))
+	default:
+		// TODO: it doesn't work in all cases, we still need to escape
+		// various $ { } [ ] symbols
+		fmt.Fprintf(buf, "%q", arg)
+	}
+	return nil
+}
+
+func write_arg_marshaled(buf *bytes.Buffer, arg interface{}) error {
+	m, ok := arg.(encoding.TextMarshaler)
+	if !ok {
+		return fmt.Errorf("gothic.sprintf: %%m requires an encoding.TextMarshaler, got %T", arg)
+	}
+	text, err := m.MarshalText()
+	if err != nil {
+		return err
+	}
+	Quote(buf, string(text))
+	return nil
+}
+
+func write_arg(buf *bytes.Buffer, arg interface{}, format string) error {
+	if t, ok := arg.(T); ok {
+		t.write(buf)
+		return nil
+	}
+	if format != "" {
+		if format == "%q" {
+			return write_arg_quoted(buf, arg)
+		} else if format == "%m" {
+			return write_arg_marshaled(buf, arg)
+		} else {
+			fmt.Fprintf(buf, format, arg)
+		}
+	} else if c, ok := arg.(color.Color); ok {
+		buf.WriteString(FormatColor(c))
+	} else {
+		fmt.Fprint(buf, arg)
+	}
+	return nil
+}
+
+func write_tag(buf *bytes.Buffer, tag string, counter *int, args []interface{}) error {
+	argnum := 0
+	if tag == "" || strings.HasPrefix(tag, "%") {
+		// no abbrev, means use counter
+	}
+
+	abbrev, format := split_tag(tag)
+	if abbrev == "" {
+		// no abbrev, means use the counter
+		argnum = *counter
+		(*counter)++
+	} else {
+		// non-empty abbrev, let's convert it to the integer
+		i, err := strconv.ParseInt(abbrev, 10, 16)
+		if err != nil {
+			return errors.New("gothic.sprintf: not-a-number tag abbrev")
+		}
+
+		argnum = int(i)
+	}
+
+	if argnum < 0 || argnum >= len(args) {
+		return fmt.Errorf("gothic.sprintf: there is no argument with index %d", argnum)
+	}
+
+	return write_arg(buf, args[argnum], format)
+}
+
+func write_tag_argmap(buf *bytes.Buffer, tag string, argmap ArgMap) error {
+	if tag == "" || strings.HasPrefix(tag, "%") {
+		return errors.New("gothic.sprintf: empty format tag abbrev on gothic.ArgMap call form")
+	}
+
+	key, format := split_tag(tag)
+	arg, found := argmap[key]
+	if !found {
+		return fmt.Errorf("gothic.sprintf: no argument %q in the ArgMap", key)
+	}
+
+	return write_arg(buf, arg, format)
+}
+
+// Formats the script into `buf`, see gothic.Interpreter.Eval for the format
+// syntax.
+func Fprintf(buf *bytes.Buffer, format string, args ...interface{}) error {
+	if len(args) == 0 {
+		// quick path
+		buf.WriteString(format)
+		return nil
+	}
+
+	counter := 0
+	argmap := false
+	argmapvar := ArgMap(nil)
+	if len(args) == 1 {
+		argmapvar, argmap = args[0].(ArgMap)
+	}
+
+	offset := 0
+	for {
+		i := strings.Index(format[offset:], "%{")
+		if i == -1 {
+			// no more format tags, write the rest and return
+			buf.WriteString(format[offset:])
+			break
+		}
+
+		// write everything before the formatter
+		buf.WriteString(format[offset : offset+i])
+
+		// now let's fine the ending "}"
+		b := offset + i + 2
+		j := strings.Index(format[b:], "}")
+		if j == -1 {
+			return errors.New("gothic.sprintf: missing enclosing bracket in a formatter tag")
+		}
+		e := b + j
+
+		var err error
+		tag := format[b:e]
+		if argmap {
+			err = write_tag_argmap(buf, tag, argmapvar)
+		} else {
+			err = write_tag(buf, tag, &counter, args)
+		}
+		if err != nil {
+			return err
+		}
+		offset = e + 1
+	}
+	return nil
+}
+
+// Formats the script and returns it as a string, see Fprintf.
+func Sprintf(format string, args ...interface{}) (string, error) {
+	var buf bytes.Buffer
+	err := Fprintf(&buf, format, args...)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Writes `s` as a double-quoted TCL word, escaping the characters which are
+// special in TCL, so that it's substituted to `s` as is.
+func Quote(buf *bytes.Buffer, s string) {
+	const lowerhex = "0123456789abcdef"
+	buf.WriteString(`"`)
+	size := 0
+	for offset := 0; offset < len(s); offset += size {
+		r := rune(s[offset])
+		size = 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(s[offset:])
+		}
+
+		if size == 1 && r == utf8.RuneError {
+			// invalid rune, write the byte as is
+			buf.WriteString(`\x`)
+			buf.WriteByte(lowerhex[r>>4])
+			buf.WriteByte(lowerhex[r&0xF])
+			continue
+		}
+
+		// first check for special TCL escaping cases
+		switch r {
+		case '{', '}', '[', ']', '"', '$', '\\':
+			buf.WriteString("\\")
+			buf.WriteString(s[offset : offset+size])
+			continue
+		}
+
+		// other printable characters
+		if unicode.IsPrint(r) {
+			buf.WriteString(s[offset : offset+size])
+			continue
+		}
+
+		// non-printable characters
+		switch r {
+		case '\a':
+			buf.WriteString(`\a`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\v':
+			buf.WriteString(`\v`)
+		default:
+			switch {
+			case r < ' ':
+				buf.WriteString(`\x`)
+				buf.WriteByte(lowerhex[r>>4])
+				buf.WriteByte(lowerhex[r&0xF])
+			case r >= 0x10000:
+				r = 0xFFFD
+				fallthrough
+			case r < 0x10000:
+				buf.WriteString(`\u`)
+				for s := 12; s >= 0; s -= 4 {
+					buf.WriteByte(lowerhex[r>>uint(s)&0xF])
+				}
+			}
+		}
+	}
+	buf.WriteString(`"`)
+}
+
+// See gothic.T.
+type T string
+
+func (t T) write(buf *bytes.Buffer) {
+	buf.WriteString("[::gothic::mc ")
+	Quote(buf, string(t))
+	buf.WriteString("]")
+}
+
+// See gothic.FormatColor.
+func FormatColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 0 {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
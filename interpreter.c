@@ -8,62 +8,42 @@ void _gotk_c_tcl_set_result(Tcl_Interp *interp, char *result) {
 	Tcl_SetResult(interp, result, free_string);
 }
 
-GoTkClientData *_gotk_c_client_data_new(void *go_interp, char *strp, int strn, void **iface, void **iface2) {
-	GoTkClientData *cd = malloc(sizeof(GoTkClientData));
-	cd->go_interp = go_interp;
-	cd->strp = strp;
-	cd->strn = strn;
-	if (iface) {
-		cd->iface[0] = iface[0];
-		cd->iface[1] = iface[1];
-	}
-	if (iface2) {
-		cd->iface2[0] = iface2[0];
-		cd->iface2[1] = iface2[1];
-	}
-	return cd;
-}
-
 //------------------------------------------------------------------------------
 // Command
 //------------------------------------------------------------------------------
 
-extern int _gotk_go_command_handler(GoTkClientData*, int, Tcl_Obj**);
-extern int _gotk_go_method_handler(GoTkClientData*, int, Tcl_Obj**);
-extern void _gotk_go_command_deleter(GoTkClientData*);
+extern int _gotk_go_command_handler(uintptr_t, int, Tcl_Obj**);
+extern void _gotk_go_command_deleter(uintptr_t);
 
 int _gotk_c_command_handler(ClientData cd, Tcl_Interp *interp, int objc, Tcl_Obj *CONST objv[]) {
-	return _gotk_go_command_handler((GoTkClientData*)cd, objc, (Tcl_Obj**)objv);
-}
-
-int _gotk_c_method_handler(ClientData cd, Tcl_Interp *interp, int objc, Tcl_Obj *CONST objv[]) {
-	return _gotk_go_method_handler((GoTkClientData*)cd, objc, (Tcl_Obj**)objv);
+	int i, status;
+	// the command can delete the interpreter (e.g. `destroy .` ends the main
+	// loop) or free the objects it got, Go keeps using them until it returns
+	Tcl_Preserve(interp);
+	for (i = 0; i < objc; i++)
+		Tcl_IncrRefCount(objv[i]);
+	status = _gotk_go_command_handler((uintptr_t)cd, objc, (Tcl_Obj**)objv);
+	for (i = 0; i < objc; i++)
+		Tcl_DecrRefCount(objv[i]);
+	Tcl_Release(interp);
+	return status;
 }
 
 void _gotk_c_command_deleter(ClientData cd) {
-	GoTkClientData *clidata = (GoTkClientData*)cd;
-	_gotk_go_command_deleter(clidata);
-	free(cd);
+	_gotk_go_command_deleter((uintptr_t)cd);
 }
 
-void _gotk_c_method_deleter(ClientData cd) {
-	free(cd);
+void _gotk_c_add_command(Tcl_Interp *interp, const char *name, uintptr_t data) {
+	Tcl_CreateObjCommand(interp, name, _gotk_c_command_handler,
+			     (ClientData)data, _gotk_c_command_deleter);
 }
 
-void _gotk_c_add_command(Tcl_Interp *interp, const char *name, void *go_interp,
-	char *strp, int strn, void **iface)
-{
-	GoTkClientData *cd = _gotk_c_client_data_new(go_interp, strp, strn, iface, 0);
-	Tcl_CreateObjCommand(interp, name, _gotk_c_command_handler,
-			     (ClientData)cd, _gotk_c_command_deleter);
+void _gotk_c_incr_ref_count(Tcl_Obj *obj) {
+	Tcl_IncrRefCount(obj);
 }
 
-void _gotk_c_add_method(Tcl_Interp *interp, const char *name, void *go_interp,
-	void **iface, void **iface2)
-{
-	GoTkClientData *cd = _gotk_c_client_data_new(go_interp, 0, 0, iface, iface2);
-	Tcl_CreateObjCommand(interp, name, _gotk_c_method_handler,
-			     (ClientData)cd, _gotk_c_method_deleter);
+void _gotk_c_decr_ref_count(Tcl_Obj *obj) {
+	Tcl_DecrRefCount(obj);
 }
 
 //------------------------------------------------------------------------------
@@ -72,10 +52,696 @@ void _gotk_c_add_method(Tcl_Interp *interp, const char *name, void *go_interp,
 
 extern int _gotk_go_async_handler(Tcl_Event*, int);
 
-Tcl_Event *_gotk_c_new_async_event(void *go_interp) {
+Tcl_Event *_gotk_c_new_async_event(uintptr_t go_interp) {
 	GoTkAsyncEvent *ev = (GoTkAsyncEvent*)Tcl_Alloc(sizeof(GoTkAsyncEvent));
 	ev->header.proc = _gotk_go_async_handler;
 	ev->header.nextPtr = 0;
 	ev->go_interp = go_interp;
 	return (Tcl_Event*)ev;
 }
+
+//------------------------------------------------------------------------------
+// Filesystem
+//------------------------------------------------------------------------------
+
+// A read-only filesystem, paths are mapped to Go fs.FS instances on the Go
+// side (see vfs.go). Opened files are read into memory entirely.
+
+#include <errno.h>
+#include <fcntl.h>
+#include <stdio.h>
+#include <string.h>
+#include <sys/stat.h>
+#include <unistd.h>
+
+extern int _gotk_go_fs_match_path(char*, int);
+extern int _gotk_go_fs_stat(char*, int, int*, Tcl_WideInt*, Tcl_WideInt*);
+extern int _gotk_go_fs_read(char*, int, char**, Tcl_WideInt*);
+extern int _gotk_go_fs_list(Tcl_Obj*, Tcl_Obj*, char*, int, char*, int);
+
+typedef struct {
+	char *data;
+	Tcl_WideInt size;
+	Tcl_WideInt pos;
+} GoTkFile;
+
+static int file_close(ClientData cd, Tcl_Interp *interp) {
+	GoTkFile *f = (GoTkFile*)cd;
+	free(f->data);
+	free(f);
+	return 0;
+}
+
+static int file_input(ClientData cd, char *buf, int toRead, int *errorCodePtr) {
+	GoTkFile *f = (GoTkFile*)cd;
+	Tcl_WideInt n = f->size - f->pos;
+	if (n > toRead)
+		n = toRead;
+	if (n > 0) {
+		memcpy(buf, f->data + f->pos, n);
+		f->pos += n;
+	}
+	return (int)n;
+}
+
+static int file_output(ClientData cd, const char *buf, int toWrite, int *errorCodePtr) {
+	*errorCodePtr = EROFS;
+	return -1;
+}
+
+static Tcl_WideInt file_wide_seek(ClientData cd, Tcl_WideInt offset, int mode, int *errorCodePtr) {
+	GoTkFile *f = (GoTkFile*)cd;
+	Tcl_WideInt pos;
+	switch (mode) {
+	case SEEK_SET: pos = offset; break;
+	case SEEK_CUR: pos = f->pos + offset; break;
+	case SEEK_END: pos = f->size + offset; break;
+	default: *errorCodePtr = EINVAL; return -1;
+	}
+	if (pos < 0) {
+		*errorCodePtr = EINVAL;
+		return -1;
+	}
+	f->pos = pos;
+	return pos;
+}
+
+static int file_seek(ClientData cd, long offset, int mode, int *errorCodePtr) {
+	return (int)file_wide_seek(cd, offset, mode, errorCodePtr);
+}
+
+static void file_watch(ClientData cd, int mask) {
+}
+
+static int file_get_handle(ClientData cd, int direction, ClientData *handlePtr) {
+	return TCL_ERROR;
+}
+
+static Tcl_ChannelType file_channel_type = {
+	.typeName = "gothic",
+	.version = TCL_CHANNEL_VERSION_5,
+	.closeProc = file_close,
+	.inputProc = file_input,
+	.outputProc = file_output,
+	.seekProc = file_seek,
+	.watchProc = file_watch,
+	.getHandleProc = file_get_handle,
+	.wideSeekProc = file_wide_seek,
+};
+
+// sets the "couldn't <what> "<path>": <errno message>" error, it's not
+// variadic to let the dlopen build forward the calls (see dynload.c)
+static void fs_error(Tcl_Interp *interp, const char *what, const char *path) {
+	Tcl_Obj *msg = Tcl_NewStringObj("couldn't ", -1);
+	Tcl_AppendToObj(msg, what, -1);
+	Tcl_AppendToObj(msg, " \"", -1);
+	Tcl_AppendToObj(msg, path, -1);
+	Tcl_AppendToObj(msg, "\": ", -1);
+	Tcl_AppendToObj(msg, Tcl_PosixError(interp), -1);
+	Tcl_SetObjResult(interp, msg);
+}
+
+static int fs_path_in_filesystem(Tcl_Obj *pathPtr, ClientData *clientDataPtr) {
+	int n;
+	char *path = Tcl_GetStringFromObj(pathPtr, &n);
+	return _gotk_go_fs_match_path(path, n) ? TCL_OK : -1;
+}
+
+static int fs_stat(Tcl_Obj *pathPtr, Tcl_StatBuf *buf) {
+	int n, isdir;
+	Tcl_WideInt size, mtime;
+	char *path = Tcl_GetStringFromObj(pathPtr, &n);
+	int err = _gotk_go_fs_stat(path, n, &isdir, &size, &mtime);
+	if (err != 0) {
+		Tcl_SetErrno(err);
+		return -1;
+	}
+	memset(buf, 0, sizeof(*buf));
+	buf->st_mode = isdir ? (S_IFDIR | 0555) : (S_IFREG | 0444);
+	buf->st_nlink = 1;
+	buf->st_size = size;
+	buf->st_atime = buf->st_mtime = buf->st_ctime = mtime;
+	return 0;
+}
+
+static int fs_access(Tcl_Obj *pathPtr, int mode) {
+	Tcl_StatBuf buf;
+	if (fs_stat(pathPtr, &buf) != 0)
+		return -1;
+	if (mode & W_OK) {
+		Tcl_SetErrno(EROFS);
+		return -1;
+	}
+	if ((mode & X_OK) && !S_ISDIR(buf.st_mode)) {
+		Tcl_SetErrno(EACCES);
+		return -1;
+	}
+	return 0;
+}
+
+static Tcl_Channel fs_open_file_channel(Tcl_Interp *interp, Tcl_Obj *pathPtr, int mode, int permissions) {
+	int n, err;
+	char *path = Tcl_GetStringFromObj(pathPtr, &n);
+	char name[32];
+	GoTkFile *f;
+
+	if (mode & (O_WRONLY | O_RDWR | O_CREAT | O_TRUNC | O_APPEND)) {
+		err = EROFS;
+		goto error;
+	}
+	f = malloc(sizeof(GoTkFile));
+	f->pos = 0;
+	err = _gotk_go_fs_read(path, n, &f->data, &f->size);
+	if (err != 0) {
+		free(f);
+		goto error;
+	}
+	snprintf(name, sizeof(name), "gothic%p", (void*)f);
+	return Tcl_CreateChannel(&file_channel_type, name, (ClientData)f, TCL_READABLE);
+
+error:
+	Tcl_SetErrno(err);
+	if (interp)
+		fs_error(interp, "open", path);
+	return NULL;
+}
+
+static int fs_match_in_directory(Tcl_Interp *interp, Tcl_Obj *resultPtr, Tcl_Obj *pathPtr,
+	const char *pattern, Tcl_GlobTypeData *types)
+{
+	int n, err;
+	int type = types ? types->type : 0;
+	char *path = Tcl_GetStringFromObj(pathPtr, &n);
+
+	if (type & (TCL_GLOB_TYPE_MOUNT | TCL_GLOB_TYPE_LINK))
+		return TCL_OK;
+	if (types && (types->perm & TCL_GLOB_PERM_W))
+		return TCL_OK;
+	err = _gotk_go_fs_list(resultPtr, pathPtr, path, n, (char*)pattern, type);
+	if (err != 0) {
+		Tcl_SetErrno(err);
+		if (interp)
+			fs_error(interp, "read directory", path);
+		return TCL_ERROR;
+	}
+	return TCL_OK;
+}
+
+static Tcl_Filesystem filesystem = {
+	.typeName = "gothic",
+	.structureLength = sizeof(Tcl_Filesystem),
+	.version = TCL_FILESYSTEM_VERSION_1,
+	.pathInFilesystemProc = fs_path_in_filesystem,
+	.statProc = fs_stat,
+	.accessProc = fs_access,
+	.openFileChannelProc = fs_open_file_channel,
+	.matchInDirectoryProc = fs_match_in_directory,
+};
+
+int _gotk_c_fs_register(void) {
+	return Tcl_FSRegister(NULL, &filesystem);
+}
+
+void _gotk_c_fs_changed(void) {
+	Tcl_FSMountsChanged(&filesystem);
+}
+
+// joins the names the way they were given, `glob -tails` depends on it
+void _gotk_c_fs_append(Tcl_Obj *result, Tcl_Obj *dir, const char *name, int n) {
+	int dirn;
+	char *dirp = Tcl_GetStringFromObj(dir, &dirn);
+	Tcl_Obj *path = Tcl_NewStringObj(dirp, dirn);
+	if (dirn > 0 && dirp[dirn-1] != '/')
+		Tcl_AppendToObj(path, "/", 1);
+	Tcl_AppendToObj(path, name, n);
+	Tcl_ListObjAppendElement(NULL, result, path);
+}
+
+//------------------------------------------------------------------------------
+// Photo formats
+//------------------------------------------------------------------------------
+
+// Photo format procedures don't get client data, so there is a fixed number
+// of slots with their own procedures passing the slot number to Go (see
+// photoformat.go).
+
+extern int _gotk_go_photo_match(int, Tcl_Interp*, Tcl_Obj*, int, int*, int*);
+extern int _gotk_go_photo_read(int, Tcl_Interp*, Tcl_Obj*, int, Tk_PhotoHandle,
+	int, int, int, int, int, int);
+extern int _gotk_go_photo_write(int, Tcl_Interp*, char*, Tk_PhotoImageBlock*);
+
+static Tcl_Obj *read_channel(Tcl_Channel chan) {
+	Tcl_Obj *data = Tcl_NewObj();
+	Tcl_IncrRefCount(data);
+	if (Tcl_ReadChars(chan, data, -1, 0) < 0) {
+		Tcl_DecrRefCount(data);
+		return NULL;
+	}
+	return data;
+}
+
+static int photo_file_match(int slot, Tcl_Channel chan, int *w, int *h, Tcl_Interp *interp) {
+	int ok;
+	Tcl_Obj *data = read_channel(chan);
+	if (!data)
+		return 0;
+	ok = _gotk_go_photo_match(slot, interp, data, 0, w, h);
+	Tcl_DecrRefCount(data);
+	return ok;
+}
+
+static int photo_file_read(int slot, Tcl_Interp *interp, Tcl_Channel chan, const char *fileName,
+	Tk_PhotoHandle handle, int destX, int destY, int width, int height, int srcX, int srcY)
+{
+	int status;
+	Tcl_Obj *data;
+	Tcl_Seek(chan, 0, SEEK_SET);
+	data = read_channel(chan);
+	if (!data) {
+		fs_error(interp, "read", fileName);
+		return TCL_ERROR;
+	}
+	status = _gotk_go_photo_read(slot, interp, data, 0, handle,
+		destX, destY, width, height, srcX, srcY);
+	Tcl_DecrRefCount(data);
+	return status;
+}
+
+#define PHOTO_FORMAT_PROCS(i) \
+static int file_match_##i(Tcl_Channel chan, const char *fileName, Tcl_Obj *format, \
+	int *w, int *h, Tcl_Interp *interp) \
+{ \
+	return photo_file_match(i, chan, w, h, interp); \
+} \
+static int string_match_##i(Tcl_Obj *data, Tcl_Obj *format, int *w, int *h, Tcl_Interp *interp) { \
+	return _gotk_go_photo_match(i, interp, data, 1, w, h); \
+} \
+static int file_read_##i(Tcl_Interp *interp, Tcl_Channel chan, const char *fileName, \
+	Tcl_Obj *format, Tk_PhotoHandle handle, int destX, int destY, \
+	int width, int height, int srcX, int srcY) \
+{ \
+	return photo_file_read(i, interp, chan, fileName, handle, \
+		destX, destY, width, height, srcX, srcY); \
+} \
+static int string_read_##i(Tcl_Interp *interp, Tcl_Obj *data, Tcl_Obj *format, \
+	Tk_PhotoHandle handle, int destX, int destY, int width, int height, int srcX, int srcY) \
+{ \
+	return _gotk_go_photo_read(i, interp, data, 1, handle, \
+		destX, destY, width, height, srcX, srcY); \
+} \
+static int file_write_##i(Tcl_Interp *interp, const char *fileName, Tcl_Obj *format, \
+	Tk_PhotoImageBlock *block) \
+{ \
+	return _gotk_go_photo_write(i, interp, (char*)fileName, block); \
+} \
+static int string_write_##i(Tcl_Interp *interp, Tcl_Obj *format, Tk_PhotoImageBlock *block) { \
+	return _gotk_go_photo_write(i, interp, NULL, block); \
+}
+
+#define PHOTO_FORMAT(i) \
+	{NULL, file_match_##i, string_match_##i, file_read_##i, string_read_##i, \
+	 file_write_##i, string_write_##i, NULL},
+
+PHOTO_FORMAT_PROCS(0) PHOTO_FORMAT_PROCS(1) PHOTO_FORMAT_PROCS(2) PHOTO_FORMAT_PROCS(3)
+PHOTO_FORMAT_PROCS(4) PHOTO_FORMAT_PROCS(5) PHOTO_FORMAT_PROCS(6) PHOTO_FORMAT_PROCS(7)
+PHOTO_FORMAT_PROCS(8) PHOTO_FORMAT_PROCS(9) PHOTO_FORMAT_PROCS(10) PHOTO_FORMAT_PROCS(11)
+PHOTO_FORMAT_PROCS(12) PHOTO_FORMAT_PROCS(13) PHOTO_FORMAT_PROCS(14) PHOTO_FORMAT_PROCS(15)
+
+static Tk_PhotoImageFormat photo_formats[GOTHIC_PHOTO_FORMATS] = {
+	PHOTO_FORMAT(0) PHOTO_FORMAT(1) PHOTO_FORMAT(2) PHOTO_FORMAT(3)
+	PHOTO_FORMAT(4) PHOTO_FORMAT(5) PHOTO_FORMAT(6) PHOTO_FORMAT(7)
+	PHOTO_FORMAT(8) PHOTO_FORMAT(9) PHOTO_FORMAT(10) PHOTO_FORMAT(11)
+	PHOTO_FORMAT(12) PHOTO_FORMAT(13) PHOTO_FORMAT(14) PHOTO_FORMAT(15)
+};
+
+// `name` must stay valid forever
+void _gotk_c_photo_format_init(int slot, const char *name, int canwrite) {
+	photo_formats[slot].name = name;
+	if (!canwrite) {
+		photo_formats[slot].fileWriteProc = NULL;
+		photo_formats[slot].stringWriteProc = NULL;
+	}
+}
+
+// Tk keeps the list of formats per thread
+void _gotk_c_photo_format_register(int slot) {
+	Tk_CreatePhotoImageFormat(&photo_formats[slot]);
+}
+
+//------------------------------------------------------------------------------
+// Canvas items
+//------------------------------------------------------------------------------
+
+// Canvas items defined by two points (like rectangles) rendered by Go into a
+// photo image (see canvasitem.go). The options are plain strings stored in
+// the item record, Tk parses and reports them. The procedures find the type
+// slot by the item's typePtr.
+
+#include <math.h>
+#include <stddef.h>
+
+extern int _gotk_go_item_draw(int, GoTkItem*, Tk_PhotoHandle);
+
+static Tk_ItemType item_types[GOTHIC_ITEM_TYPES];
+
+static Tk_CustomOption item_tags_option = {
+	Tk_CanvasTagsParseProc, Tk_CanvasTagsPrintProc, NULL
+};
+
+static int item_slot(Tk_Item *itemPtr) {
+	return (int)(itemPtr->typePtr - item_types);
+}
+
+static void item_bbox(Tk_Canvas canvas, GoTkItem *item) {
+	double x1 = item->coords[0], y1 = item->coords[1];
+	double x2 = item->coords[2], y2 = item->coords[3];
+	item->header.x1 = (int)(x1 < x2 ? x1 : x2);
+	item->header.y1 = (int)(y1 < y2 ? y1 : y2);
+	item->header.x2 = (int)(x1 < x2 ? x2 : x1) + 1;
+	item->header.y2 = (int)(y1 < y2 ? y2 : y1) + 1;
+}
+
+static int item_set_coords(Tcl_Interp *interp, Tk_Canvas canvas, GoTkItem *item,
+	int objc, Tcl_Obj *const objv[])
+{
+	int i;
+	double coords[4];
+	if (objc == 1 && Tcl_ListObjGetElements(interp, objv[0], &objc, (Tcl_Obj***)&objv) != TCL_OK)
+		return TCL_ERROR;
+	if (objc != 4) {
+		Tcl_SetObjResult(interp, Tcl_NewStringObj("wrong # coordinates: expected 4", -1));
+		return TCL_ERROR;
+	}
+	for (i = 0; i < 4; i++) {
+		if (Tk_CanvasGetCoordFromObj(interp, canvas, objv[i], &coords[i]) != TCL_OK)
+			return TCL_ERROR;
+	}
+	memcpy(item->coords, coords, sizeof(coords));
+	item_bbox(canvas, item);
+	return TCL_OK;
+}
+
+static void item_image_changed(ClientData cd, int x, int y, int width, int height,
+	int imageWidth, int imageHeight)
+{
+	// the image is only changed by the item itself while it's displayed
+}
+
+static int item_configure(Tcl_Interp *interp, Tk_Canvas canvas, Tk_Item *itemPtr,
+	int objc, Tcl_Obj *const objv[], int flags)
+{
+	GoTkItem *item = (GoTkItem*)itemPtr;
+	if (Tk_ConfigureWidget(interp, Tk_CanvasTkwin(canvas), itemPtr->typePtr->configSpecs,
+			objc, (CONST84 char**)objv, (char*)item, flags | TK_CONFIG_OBJS) != TCL_OK)
+		return TCL_ERROR;
+	item->dirty = 1;
+	return TCL_OK;
+}
+
+static void item_delete(Tk_Canvas canvas, Tk_Item *itemPtr, Display *display) {
+	GoTkItem *item = (GoTkItem*)itemPtr;
+	Tk_FreeOptions(itemPtr->typePtr->configSpecs, (char*)item, display, 0);
+	if (item->image)
+		Tk_FreeImage(item->image);
+	if (item->image_name) {
+		Tk_DeleteImage(item->interp, item->image_name);
+		free(item->image_name);
+	}
+}
+
+static int item_create(Tcl_Interp *interp, Tk_Canvas canvas, Tk_Item *itemPtr,
+	int objc, Tcl_Obj *const objv[])
+{
+	GoTkItem *item = (GoTkItem*)itemPtr;
+	int ncoords, n;
+	const char *name;
+
+	item->interp = interp;
+	item->canvas = canvas;
+	item->dirty = 1;
+	item->image_name = NULL;
+	item->image = NULL;
+	item->width = item->height = 0;
+	memset(item->values, 0, sizeof(item->values));
+
+	// the coordinates go before the options
+	for (ncoords = 0; ncoords < objc; ncoords++) {
+		const char *arg = Tcl_GetString(objv[ncoords]);
+		if (arg[0] == '-' && arg[1] >= 'a' && arg[1] <= 'z')
+			break;
+	}
+	if (item_set_coords(interp, canvas, item, ncoords, objv) != TCL_OK)
+		goto error;
+
+	if (Tcl_EvalEx(interp, "image create photo", -1, TCL_EVAL_GLOBAL) != TCL_OK)
+		goto error;
+	name = Tcl_GetStringFromObj(Tcl_GetObjResult(interp), &n);
+	item->image_name = malloc(n + 1);
+	memcpy(item->image_name, name, n + 1);
+	item->image = Tk_GetImage(interp, Tk_CanvasTkwin(canvas), item->image_name,
+		item_image_changed, (ClientData)item);
+	if (!item->image)
+		goto error;
+	Tcl_ResetResult(interp);
+
+	if (item_configure(interp, canvas, itemPtr, objc - ncoords, objv + ncoords, 0) != TCL_OK)
+		goto error;
+	return TCL_OK;
+
+error:
+	item_delete(canvas, itemPtr, Tk_Display(Tk_CanvasTkwin(canvas)));
+	return TCL_ERROR;
+}
+
+static int item_coords(Tcl_Interp *interp, Tk_Canvas canvas, Tk_Item *itemPtr,
+	int objc, Tcl_Obj *const objv[])
+{
+	GoTkItem *item = (GoTkItem*)itemPtr;
+	if (objc == 0) {
+		int i;
+		Tcl_Obj *list = Tcl_NewListObj(0, NULL);
+		for (i = 0; i < 4; i++)
+			Tcl_ListObjAppendElement(NULL, list, Tcl_NewDoubleObj(item->coords[i]));
+		Tcl_SetObjResult(interp, list);
+		return TCL_OK;
+	}
+	return item_set_coords(interp, canvas, item, objc, objv);
+}
+
+static void item_display(Tk_Canvas canvas, Tk_Item *itemPtr, Display *display,
+	Drawable drawable, int x, int y, int width, int height)
+{
+	GoTkItem *item = (GoTkItem*)itemPtr;
+	short dx, dy;
+	int w = itemPtr->x2 - itemPtr->x1 - 1;
+	int h = itemPtr->y2 - itemPtr->y1 - 1;
+	if (w <= 0 || h <= 0)
+		return;
+
+	if (item->dirty || item->width != w || item->height != h) {
+		Tk_PhotoHandle photo = Tk_FindPhoto(item->interp, item->image_name);
+		if (!photo || Tk_PhotoSetSize(item->interp, photo, w, h) != TCL_OK)
+			return;
+		item->width = w;
+		item->height = h;
+		item->dirty = 0;
+		if (_gotk_go_item_draw(item_slot(itemPtr), item, photo) != TCL_OK)
+			return;
+	}
+	Tk_CanvasDrawableCoords(canvas, itemPtr->x1, itemPtr->y1, &dx, &dy);
+	Tk_RedrawImage(item->image, 0, 0, w, h, drawable, dx, dy);
+}
+
+static double item_point(Tk_Canvas canvas, Tk_Item *itemPtr, double *pointPtr) {
+	double x = pointPtr[0], y = pointPtr[1], dx = 0, dy = 0;
+	if (x < itemPtr->x1)
+		dx = itemPtr->x1 - x;
+	else if (x >= itemPtr->x2)
+		dx = x - itemPtr->x2 + 1;
+	if (y < itemPtr->y1)
+		dy = itemPtr->y1 - y;
+	else if (y >= itemPtr->y2)
+		dy = y - itemPtr->y2 + 1;
+	return hypot(dx, dy);
+}
+
+static int item_area(Tk_Canvas canvas, Tk_Item *itemPtr, double *rectPtr) {
+	if (rectPtr[2] <= itemPtr->x1 || rectPtr[0] >= itemPtr->x2 ||
+	    rectPtr[3] <= itemPtr->y1 || rectPtr[1] >= itemPtr->y2)
+		return -1;
+	if (rectPtr[0] <= itemPtr->x1 && rectPtr[2] >= itemPtr->x2 &&
+	    rectPtr[1] <= itemPtr->y1 && rectPtr[3] >= itemPtr->y2)
+		return 1;
+	return 0;
+}
+
+static void item_scale(Tk_Canvas canvas, Tk_Item *itemPtr, double originX, double originY,
+	double scaleX, double scaleY)
+{
+	GoTkItem *item = (GoTkItem*)itemPtr;
+	item->coords[0] = originX + scaleX * (item->coords[0] - originX);
+	item->coords[1] = originY + scaleY * (item->coords[1] - originY);
+	item->coords[2] = originX + scaleX * (item->coords[2] - originX);
+	item->coords[3] = originY + scaleY * (item->coords[3] - originY);
+	item_bbox(canvas, item);
+}
+
+static void item_translate(Tk_Canvas canvas, Tk_Item *itemPtr, double deltaX, double deltaY) {
+	GoTkItem *item = (GoTkItem*)itemPtr;
+	item->coords[0] += deltaX;
+	item->coords[1] += deltaY;
+	item->coords[2] += deltaX;
+	item->coords[3] += deltaY;
+	item_bbox(canvas, item);
+}
+
+// `name` and the option names and defaults must stay valid forever
+void _gotk_c_item_type_init(int slot, const char *name, int nopts) {
+	Tk_ItemType *t = &item_types[slot];
+	// -tags, the options and the terminating entry
+	Tk_ConfigSpec *specs = calloc(nopts + 2, sizeof(Tk_ConfigSpec));
+	specs[0].type = TK_CONFIG_CUSTOM;
+	specs[0].argvName = "-tags";
+	specs[0].specFlags = TK_CONFIG_NULL_OK;
+	specs[0].customPtr = &item_tags_option;
+	specs[nopts + 1].type = TK_CONFIG_END;
+
+	t->name = name;
+	t->itemSize = sizeof(GoTkItem);
+	t->createProc = item_create;
+	t->configSpecs = specs;
+	t->configProc = item_configure;
+	t->coordProc = item_coords;
+	t->deleteProc = item_delete;
+	t->displayProc = item_display;
+	t->alwaysRedraw = TK_CONFIG_OBJS;
+	t->pointProc = item_point;
+	t->areaProc = item_area;
+	t->scaleProc = item_scale;
+	t->translateProc = item_translate;
+}
+
+void _gotk_c_item_type_option(int slot, int i, const char *name, const char *def) {
+	Tk_ConfigSpec *spec = (Tk_ConfigSpec*)&item_types[slot].configSpecs[i + 1];
+	spec->type = TK_CONFIG_STRING;
+	spec->argvName = name;
+	spec->defValue = def;
+	spec->offset = offsetof(GoTkItem, values) + i * sizeof(char*);
+	spec->specFlags = TK_CONFIG_NULL_OK;
+}
+
+const char *_gotk_c_item_canvas(GoTkItem *item) {
+	return Tk_PathName(Tk_CanvasTkwin(item->canvas));
+}
+
+// the list of item types is global
+void _gotk_c_item_type_register(int slot) {
+	Tk_CreateItemType(&item_types[slot]);
+}
+
+//------------------------------------------------------------------------------
+// Writer channels
+//------------------------------------------------------------------------------
+
+// Write-only channels passing the data to Go io.Writers, identified by an id
+// (see channel.go).
+
+extern int _gotk_go_writer_output(int, char*, int, int*);
+
+static int writer_close(ClientData cd, Tcl_Interp *interp) {
+	return 0;
+}
+
+static int writer_output(ClientData cd, const char *buf, int toWrite, int *errorCodePtr) {
+	return _gotk_go_writer_output((int)(intptr_t)cd, (char*)buf, toWrite, errorCodePtr);
+}
+
+static Tcl_ChannelType writer_channel_type = {
+	.typeName = "gothic-writer",
+	.version = TCL_CHANNEL_VERSION_5,
+	.closeProc = writer_close,
+	.outputProc = writer_output,
+	.watchProc = file_watch,
+	.getHandleProc = file_get_handle,
+};
+
+Tcl_Channel _gotk_c_writer_channel(Tcl_Interp *interp, int id) {
+	char name[32];
+	Tcl_Channel chan;
+	snprintf(name, sizeof(name), "gothic-writer%d", id);
+	chan = Tcl_CreateChannel(&writer_channel_type, name, (ClientData)(intptr_t)id, TCL_WRITABLE);
+	Tcl_RegisterChannel(interp, chan);
+	// our own reference, a script closing the channel only unregisters it
+	// from the interpreter
+	Tcl_RegisterChannel(NULL, chan);
+	return chan;
+}
+
+void _gotk_c_close_writer_channel(Tcl_Interp *interp, Tcl_Channel chan) {
+	if (Tcl_IsChannelRegistered(interp, chan)) {
+		Tcl_UnregisterChannel(interp, chan);
+	}
+	// drops the last reference, flushing and closing the channel
+	Tcl_UnregisterChannel(NULL, chan);
+}
+
+//------------------------------------------------------------------------------
+// Colors
+//------------------------------------------------------------------------------
+
+// Parses the color `name` into 16-bit red, green and blue components.
+int _gotk_c_parse_color(Tcl_Interp *interp, const char *name, unsigned short *rgb) {
+	XColor *color;
+	Tk_Window tkwin = Tk_MainWindow(interp);
+	if (!tkwin)
+		return TCL_ERROR;
+	color = Tk_GetColor(interp, tkwin, Tk_GetUid(name));
+	if (!color)
+		return TCL_ERROR;
+	rgb[0] = color->red;
+	rgb[1] = color->green;
+	rgb[2] = color->blue;
+	Tk_FreeColor(color);
+	return TCL_OK;
+}
+
+//------------------------------------------------------------------------------
+// Event loop
+//------------------------------------------------------------------------------
+
+// Tcl_SetMaxBlockTime only applies to the next wait, an event source sets it
+// before every wait of the thread.
+
+typedef struct {
+	int installed;
+	Tcl_Time max_block;
+} LoopData;
+
+static Tcl_ThreadDataKey loop_key;
+
+static void loop_setup(ClientData cd, int flags) {
+	LoopData *d = (LoopData*)cd;
+	Tcl_SetMaxBlockTime(&d->max_block);
+}
+
+static void loop_check(ClientData cd, int flags) {
+}
+
+// Limits the time the event loop of the calling thread blocks waiting for
+// events, no limit if `usec` isn't positive.
+void _gotk_c_set_max_block_time(long usec) {
+	LoopData *d = (LoopData*)Tcl_GetThreadData(&loop_key, sizeof(LoopData));
+	if (usec <= 0) {
+		if (d->installed) {
+			Tcl_DeleteEventSource(loop_setup, loop_check, d);
+			d->installed = 0;
+		}
+		return;
+	}
+	d->max_block.sec = usec / 1000000;
+	d->max_block.usec = usec % 1000000;
+	if (!d->installed) {
+		Tcl_CreateEventSource(loop_setup, loop_check, d);
+		d->installed = 1;
+	}
+}
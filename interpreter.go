@@ -1,15 +1,18 @@
 package gothic
 
 /*
-#cgo LDFLAGS: -ltcl8.6 -ltk8.6
+#cgo !gothic_dlopen LDFLAGS: -ltcl8.6 -ltk8.6
 #cgo CFLAGS: -I/usr/include/tcl8.6
-#cgo tcl85 LDFLAGS: -ltcl8.5 -ltk8.5
+#cgo LDFLAGS: -lm
+#cgo tcl85,!gothic_dlopen LDFLAGS: -ltcl8.5 -ltk8.5
 #cgo tcl85 CFLAGS: -I/usr/include/tcl8.5
 
 #include "interpreter.h"
 */
 import "C"
 import (
+	"context"
+	"encoding"
 	"errors"
 	"reflect"
 	"runtime"
@@ -17,85 +20,217 @@ import (
 	"bytes"
 	"unsafe"
 	"image"
-	"sync"
 	"fmt"
+	"time"
+	"io"
+	"sync/atomic"
+	"runtime/cgo"
+	"runtime/debug"
+
+	"github.com/nsf/gothic/internal/script"
 )
 
 const (
-	debug = false
-	alot  = 999999
+	alot = 999999
 )
 
 //------------------------------------------------------------------------------
 // Utils
 //------------------------------------------------------------------------------
 
-func cgo_string_to_go_string(p *C.char, n C.int) string {
-	var x reflect.StringHeader
-	x.Data = uintptr(unsafe.Pointer(p))
-	x.Len = int(n)
-	return *(*string)(unsafe.Pointer(&x))
-}
-
-func go_string_to_cgo_string(s string) (*C.char, C.int) {
-	x := *(*reflect.StringHeader)(unsafe.Pointer(&s))
-	return (*C.char)(unsafe.Pointer(x.Data)), C.int(x.Len)
-}
-
-func c_interface_to_go_interface(iface [2]unsafe.Pointer) interface{} {
-	return *(*interface{})(unsafe.Pointer(&iface))
-}
-
-func go_interface_to_c_interface(iface interface{}) *unsafe.Pointer {
-	return (*unsafe.Pointer)(unsafe.Pointer(&iface))
-}
+// cgo_string_to_go_string and go_string_to_cgo_string are in
+// unsafestrings.go and safestrings.go, see the gothic_safestrings build tag.
 
 // A handle that is used to manipulate a TCL interpreter. All handle methods
 // can be safely invoked from different threads. Each method invocation is
 // synchronous, it means that the method will be blocked until the action is
 // actually executed.
 //
-// `Done` field returns 0 when Tk's main loop exits.
+// `Done` field is closed (receives return 0) when Tk's main loop exits: all
+// Tk windows are destroyed or Quit is called. Any number of goroutines can
+// wait for it. The interpreter is deleted at that point, the methods return
+// ErrInterpreterClosed, a new one can be created to show the GUI again.
 type Interpreter struct {
 	ir   *interpreter
 	Done <-chan int
 }
 
+// name of the TCL array mirroring the process environment
+var env_array_name = C.CString("env")
+
+// The default capacity of the async queue, see Options.QueueSize.
+const DefaultQueueSize = 50
+
+// Returned (wrapped) by New when gothic is built with the gothic_dlopen tag and
+// the TCL library can't be loaded, or by InitTk when the Tk library can't.
+var ErrLibraryNotFound = errors.New("gothic: TCL/Tk library not found")
+
+// Returned by the methods called from other goroutines once the event loop of
+// the interpreter has exited or Quit was called.
+var ErrInterpreterClosed = errors.New("gothic: interpreter is closed")
+
+// Additional parameters of the interpreter creation, see NewWithOptions. Zero
+// value means defaults.
+type Options struct {
+	// Capacity of the queue of actions sent to the interpreter from foreign
+	// threads. When the queue is full, senders block until the interpreter
+	// catches up. Defaults to DefaultQueueSize.
+	QueueSize int
+
+	// Don't initialize Tk, see NewTclInterpreter. Tk can be initialized
+	// later using Interpreter.InitTk.
+	NoTk bool
+
+	// Parameters of the Tk initialization, see TkOptions.
+	Display   string
+	AppName   string
+	Geometry  string
+	Sync      bool
+	ClassName string
+	Use       string
+
+	// Command-line arguments (without the program name) and the program or
+	// script name, they become `::argv`, `::argc` and `::argv0`. If Args is
+	// not nil, Tk consumes its standard options from it the way wish does
+	// (see TkOptions.UseArgv), so that e.g. -display and -geometry work for
+	// gothic binaries:
+	//
+	//  gothic.NewWithOptions(init, gothic.Options{Argv0: os.Args[0], Args: os.Args[1:]})
+	Argv0 string
+	Args  []string
+
+	// Environment variables set in the TCL `env` array (which also updates
+	// the C environment seen by Tk and extensions, but not os.Getenv) before
+	// Tk is initialized, e.g. LANG or XMODIFIERS.
+	Env map[string]string
+
+	// Called after the functions registered with RegisterAppInit, see
+	// AppInitFunc.
+	AppInit AppInitFunc
+
+	// The maximum time a call from another goroutine waits for the
+	// interpreter thread to start its action, e.g. when the thread is stuck
+	// in a modal native dialog. The call fails with a *WaitTimeoutError then.
+	// Zero means no limit, see also Interpreter.SetWaitTimeout.
+	WaitTimeout time.Duration
+
+	// Prepended to the names passed to RegisterCommand, RegisterCommands and
+	// their Unregister counterparts, e.g. "mylib::", so that the commands of
+	// a library don't collide with the ones of the application or Tk. Fully
+	// qualified names (starting with "::") are used as is.
+	CommandPrefix string
+}
+
+func (opts *Options) tk_options() *TkOptions {
+	return &TkOptions{
+		Display:   opts.Display,
+		AppName:   opts.AppName,
+		Geometry:  opts.Geometry,
+		Sync:      opts.Sync,
+		ClassName: opts.ClassName,
+		Use:       opts.Use,
+		UseArgv:   opts.Args != nil,
+	}
+}
+
 // Creates a new instance of the *gothic.Interpreter. But before interpreter
 // enters the Tk's main loop it will execute `init`. Init argument could be a
-// string or a function with this signature: "func(*gothic.Interpreter)".
+// string or a function with one of these signatures:
+// "func(*gothic.Interpreter)" or "func(*gothic.Interpreter) error". A failing
+// init script or function makes the constructor fail: New and NewWithOptions
+// return the error, the others panic.
 func NewInterpreter(init interface{}) *Interpreter {
-	initdone := make(chan int)
+	return must_new(NewWithOptions(init, Options{}))
+}
+
+// Creates a new instance of the *gothic.Interpreter without Tk. It doesn't
+// need a display, instead of Tk's main loop the interpreter thread services
+// TCL events (timers, file events, queued actions) in a loop. Useful for
+// headless scripting, config files and tests. The `init` argument has the
+// same meaning as in NewInterpreter.
+func NewTclInterpreter(init interface{}) *Interpreter {
+	return must_new(NewWithOptions(init, Options{NoTk: true}))
+}
+
+// Works exactly as NewInterpreter, but allows you to specify additional
+// interpreter parameters.
+//
+// Deprecated: use NewWithOptions, which returns the error instead of
+// panicking.
+func NewInterpreterWithOptions(init interface{}, opts Options) *Interpreter {
+	return must_new(NewWithOptions(init, opts))
+}
+
+func must_new(ir *Interpreter, err error) *Interpreter {
+	if err != nil {
+		panic(err)
+	}
+	return ir
+}
+
+// Works exactly as NewInterpreter, but failures of the TCL and Tk
+// initialization (e.g. no display or missing Tk library) and of the `init`
+// script are returned as errors instead of panics. Applications can use it to
+// fall back to a command-line mode gracefully.
+func New(init interface{}) (*Interpreter, error) {
+	return NewWithOptions(init, Options{})
+}
+
+// Works exactly as New, but allows you to specify additional interpreter
+// parameters, e.g. the display and the application class.
+func NewWithOptions(init interface{}, opts Options) (*Interpreter, error) {
+	initdone := make(chan error)
+	// closed, so that every receiver wakes up and the thread is released
+	// even if nobody waits
 	done := make(chan int)
 
 	ir := new(Interpreter)
 	ir.Done = done
 
 	go func() {
-		var err error
+		// the goroutine exits locked to the thread, which terminates the
+		// thread, TCL and Tk release their thread data before that, so
+		// that a new interpreter can be created later
 		runtime.LockOSThread()
-		ir.ir, err = new_interpreter()
+		iir, err := new_interpreter(opts)
 		if err != nil {
-			panic(err)
+			C.Tcl_FinalizeThread()
+			initdone <- err
+			return
 		}
+		ir.ir = iir
 
 		switch realinit := init.(type) {
 		case string:
 			err = ir.ir.eval([]byte(realinit))
 			if err != nil {
-				panic(err)
+				ir.ir.finalize()
+				initdone <- err
+				return
 			}
 		case func(*Interpreter):
 			realinit(ir)
+		case func(*Interpreter) error:
+			err = realinit(ir)
+			if err != nil {
+				ir.ir.finalize()
+				initdone <- err
+				return
+			}
 		}
+		ir.ir.repanic()
 
-		initdone <- 0
-		C.Tk_MainLoop()
-		done <- 0
+		initdone <- nil
+		ir.ir.main_loop()
+		ir.ir.finalize()
+		close(done)
 	}()
 
-	<-initdone
-	return ir
+	err := <-initdone
+	if err != nil {
+		return nil, err
+	}
+	return ir, nil
 }
 
 // Queue script for evaluation and wait for its completion. This function uses
@@ -119,6 +254,8 @@ func NewInterpreter(init interface{}) *Interpreter {
 // Additional notes:
 //
 //  1. Formatter is extended to do TCL-specific quoting on %q format specifier.
+//     The %m specifier quotes the text of an encoding.TextMarshaler (e.g.
+//     time.Time, net.IP) the same way, other specifiers don't use it.
 //  2. Named abbrev is only allowed when there is one argument and the type of
 //     this argument is gothic.ArgMap.
 //
@@ -139,12 +276,13 @@ func NewInterpreter(init interface{}) *Interpreter {
 func (ir *Interpreter) Eval(format string, args ...interface{}) error {
 	// interpreter thread
 	if C.Tcl_GetCurrentThread() == ir.ir.thread {
-		ir.ir.cmdbuf.Reset()
-		err := sprintf(&ir.ir.cmdbuf, format, args...)
+		buf := ir.ir.push_cmdbuf()
+		defer ir.ir.pop_cmdbuf()
+		err := sprintf(buf, format, args...)
 		if err != nil {
 			return ir.ir.filt(err)
 		}
-		err = ir.ir.eval(ir.ir.cmdbuf.Bytes())
+		err = ir.ir.eval(buf.Bytes())
 		return ir.ir.filt(err)
 	}
 
@@ -176,15 +314,21 @@ func (ir *Interpreter) EvalBytes(s []byte) error {
 
 // Works exactly as Eval with exception that it writes the result of executed
 // code into `out`.
+//
+// If `out` points to a struct, the result is treated as a flat key/value list
+// (as produced by `array get` or `dict create`). Keys are matched against
+// `tcl:"key"` field tags or field names, a leading "-" is ignored, so that
+// `cget`-style option names work as well.
 func (ir *Interpreter) EvalAs(out interface{}, format string, args ...interface{}) error {
 	// interpreter thread
 	if C.Tcl_GetCurrentThread() == ir.ir.thread {
-		ir.ir.cmdbuf.Reset()
-		err := sprintf(&ir.ir.cmdbuf, format, args...)
+		buf := ir.ir.push_cmdbuf()
+		defer ir.ir.pop_cmdbuf()
+		err := sprintf(buf, format, args...)
 		if err != nil {
 			return ir.ir.filt(err)
 		}
-		err = ir.ir.eval_as(out, ir.ir.cmdbuf.Bytes())
+		err = ir.ir.eval_as(out, buf.Bytes())
 		return ir.ir.filt(err)
 	}
 
@@ -203,6 +347,36 @@ func (ir *Interpreter) EvalAs(out interface{}, format string, args ...interface{
 	return err
 }
 
+// Evaluates `script` and returns the bytes of its result, the same bytes
+// EvalAs gives for a []byte, without building a Go string. Called on the
+// interpreter thread, the slice points into the TCL object and stays valid
+// until the next evaluation, it must not be modified. From other threads the
+// result is copied, use EvalRawAppend to reuse a buffer.
+func (ir *Interpreter) EvalRaw(script []byte) ([]byte, error) {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		err := ir.ir.eval(script)
+		if err != nil {
+			return nil, ir.ir.filt(err)
+		}
+		return ir.ir.result_bytes(), nil
+	}
+	return ir.EvalRawAppend(nil, script)
+}
+
+// Evaluates `script` and appends the bytes of its result to `dst`, returns
+// the extended buffer like append does.
+func (ir *Interpreter) EvalRawAppend(dst, script []byte) ([]byte, error) {
+	err := ir.ir.run(func() error {
+		err := ir.ir.eval(script)
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		dst = append(dst, ir.ir.result_bytes()...)
+		return nil
+	})
+	return dst, err
+}
+
 // Sets the TCL variable `name` to the `val`. Sometimes it's nice to be able to
 // avoid going through TCL's syntax. Especially for things like passing a whole
 // buffer of text to TCL.
@@ -215,11 +389,40 @@ func (ir *Interpreter) Set(name string, val interface{}) error {
 	})
 }
 
+// Returns the number of actions currently waiting in the async queue and the
+// capacity of the queue. Safe to call from any thread, never blocks.
+func (ir *Interpreter) QueueDepth() (depth, capacity int) {
+	return ir.ir.queue.len(), ir.ir.queue.cap()
+}
+
+// Changes the wait timeout of the calls from other goroutines, see
+// Options.WaitTimeout. Zero means no limit. Safe to call from any thread.
+func (ir *Interpreter) SetWaitTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&ir.ir.wait_timeout, int64(timeout))
+}
+
+// Waits until the actions queued before the call are done and the pending
+// idle tasks (redraws, geometry calculations) have run, so that the UI has
+// settled, e.g. before taking a snapshot in tests. Called on the interpreter
+// thread, it runs `update`, which processes all the pending events including
+// the queued actions.
+func (ir *Interpreter) Sync() error {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return ir.ir.filt(ir.ir.eval([]byte("update")))
+	}
+	// the queue is FIFO, the action runs after the ones queued before
+	return ir.ir.run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.eval([]byte("update idletasks")))
+	})
+}
+
 // Every TCL error goes through the filter passed to this function. If you pass
-// nil, then no error filter is set.
+// nil, then no error filter is set. The errors of the evaluations made by the
+// filter itself aren't filtered.
 func (ir *Interpreter) ErrorFilter(filt func(error)error) {
 	if C.Tcl_GetCurrentThread() == ir.ir.thread {
 		ir.ir.errfilt = filt
+		return
 	}
 	ir.ir.run_and_wait(func() error {
 		ir.ir.errfilt = filt
@@ -227,6 +430,22 @@ func (ir *Interpreter) ErrorFilter(filt func(error)error) {
 	})
 }
 
+// Every script evaluated by the interpreter (on both the interpreter thread
+// and foreign threads) is reported to the trace function along with its
+// execution time and the resulting error (before it goes through the error
+// filter). The `script` slice is only valid during the call. If you pass nil,
+// then no trace function is set.
+func (ir *Interpreter) SetTraceFunc(trace func(script []byte, d time.Duration, err error)) {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		ir.ir.trace = trace
+		return
+	}
+	ir.ir.run_and_wait(func() error {
+		ir.ir.trace = trace
+		return nil
+	})
+}
+
 func (ir *Interpreter) UploadImage(name string, img image.Image) error {
 	if C.Tcl_GetCurrentThread() == ir.ir.thread {
 		return ir.ir.filt(ir.ir.upload_image(name, img))
@@ -236,31 +455,92 @@ func (ir *Interpreter) UploadImage(name string, img image.Image) error {
 	})
 }
 
-// Register a new TCL command called `name`.
-func (ir *Interpreter) RegisterCommand(name string, cbfunc interface{}) error {
+// Register a new TCL command called `name`. TCL arguments are converted to
+// the types of the `cbfunc` arguments. The trailing pointer arguments are
+// optional: nil if the argument is omitted, a pointer to the converted value
+// otherwise, so that "omitted" and "passed 0" differ. A variadic `cbfunc`
+// takes any number of the trailing arguments (see also OptionArgs). Calls
+// with too few or too many arguments fail with the usual "wrong # args"
+// error.
+//
+// If the last return value of `cbfunc` is a non-nil error, the command fails
+// with it, otherwise the first return value (if any) becomes the command
+// result. Return values of types which can't be converted to TCL values
+// (e.g. maps) are ignored, the type is checked when the command is
+// registered. A *Future result is returned as a token for `::gothic::await`, a
+// receive channel result is returned as a token for `::gothic::stream`.
+//
+// `cbfunc` runs on the interpreter thread and may use the interpreter: the
+// scripts it evaluates can call other commands, including itself, at any
+// depth.
+//
+// If the first argument of `cbfunc` is a context.Context, it gets the
+// context of the command, which is canceled when the command is deleted
+// (unregistered, renamed to "" or deleted with the interpreter). The
+// goroutines started by the handler should stop when it's done.
+//
+// The failures of the command can be handled by the command itself, see
+// CommandOpts.
+func (ir *Interpreter) RegisterCommand(name string, cbfunc interface{}, opts ...CommandOpts) error {
+	name = ir.ir.prefixed(name)
+	site := caller_site(1)
+	var o *CommandOpts
+	if len(opts) > 0 {
+		o = &opts[0]
+	}
 	if C.Tcl_GetCurrentThread() == ir.ir.thread {
-		return ir.ir.filt(ir.ir.register_command(name, cbfunc))
+		return ir.ir.filt(ir.ir.register_command_at(name, cbfunc, site, o))
 	}
 	return ir.ir.run_and_wait(func() error {
-		return ir.ir.filt(ir.ir.register_command(name, cbfunc))
+		return ir.ir.filt(ir.ir.register_command_at(name, cbfunc, site, o))
 	})
 }
 
+// Options for RegisterCommand.
+//
+//	OnError func(name string, err error) error
+//
+// OnError is called on the interpreter thread when the command fails: an
+// argument can't be converted, the function returns an error or panics (the
+// panic is recovered and passed as a *CommandPanicError). The returned error
+// fails the command, nil makes it succeed with an empty result, e.g. after
+// showing the error in a dialog or logging it. The global error filter
+// doesn't see the errors of the commands.
+//
+// Without OnError, a panic fails the command with a TCL error too, as it
+// can't unwind through TCL. The interpreter thread raises it again (as a
+// *CommandPanicError) once the outermost TCL call returns.
+type CommandOpts = script.CommandOpts
+
+// Passed to CommandOpts.OnError when the function of a command panics: its
+// Value is the value passed to panic and its Stack the stack of the
+// panicking goroutine.
+type CommandPanicError = script.CommandPanicError
+
 // Register multiple TCL command within the `name` namespace. The method uses
 // runtime reflection and registers only those methods of the `val` which have
 // one of the following prefixes: "TCL" or "TCL_". The name of the resulting
-// command doesn't include the prefix.
+// command doesn't include the prefix. The methods can take a context.Context
+// first, as in RegisterCommand.
 func (ir *Interpreter) RegisterCommands(name string, val interface{}) error {
+	name = ir.ir.prefixed(name)
+	site := caller_site(1)
+	register := func() error {
+		err := ir.ir.register_commands(name, val)
+		if err == nil {
+			ir.ir.methods[name].site = site
+		}
+		return ir.ir.filt(err)
+	}
 	if C.Tcl_GetCurrentThread() == ir.ir.thread {
-		return ir.ir.filt(ir.ir.register_commands(name, val))
+		return register()
 	}
-	return ir.ir.run_and_wait(func() error {
-		return ir.ir.filt(ir.ir.register_commands(name, val))
-	})
+	return ir.ir.run_and_wait(register)
 }
 
 // Unregisters (deletes) previously registered command `name`.
 func (ir *Interpreter) UnregisterCommand(name string) error {
+	name = ir.ir.prefixed(name)
 	if C.Tcl_GetCurrentThread() == ir.ir.thread {
 		return ir.ir.filt(ir.ir.unregister_command(name))
 	}
@@ -272,6 +552,7 @@ func (ir *Interpreter) UnregisterCommand(name string) error {
 // Unregisters (deletes) previously registered command set within the `name`
 // namespace.
 func (ir *Interpreter) UnregisterCommands(name string) error {
+	name = ir.ir.prefixed(name)
 	if C.Tcl_GetCurrentThread() == ir.ir.thread {
 		return ir.ir.filt(ir.ir.unregister_commands(name))
 	}
@@ -280,71 +561,330 @@ func (ir *Interpreter) UnregisterCommands(name string) error {
 	})
 }
 
+// Registers the command `name` in the namespace `ns` (created if needed),
+// regardless of Options.CommandPrefix. Unregister it using the qualified
+// name, e.g. UnregisterCommand("::ns::name").
+func (ir *Interpreter) RegisterCommandIn(ns, name string, cbfunc interface{}, opts ...CommandOpts) error {
+	name = "::" + strings.Trim(ns, ":") + "::" + name
+	site := caller_site(1)
+	var o *CommandOpts
+	if len(opts) > 0 {
+		o = &opts[0]
+	}
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return ir.ir.filt(ir.ir.register_command_at(name, cbfunc, site, o))
+	}
+	return ir.ir.run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.register_command_at(name, cbfunc, site, o))
+	})
+}
+
+// applies Options.CommandPrefix to the name of a command
+func (ir *interpreter) prefixed(name string) string {
+	if ir.prefix == "" || strings.HasPrefix(name, "::") {
+		return name
+	}
+	return ir.prefix + name
+}
+
 //------------------------------------------------------------------------------
 // interpreter
 //------------------------------------------------------------------------------
 
 type interpreter struct {
+	// see Options.WaitTimeout, in nanoseconds, accessed atomically, goes
+	// first to stay aligned on 32-bit platforms
+	wait_timeout int64
+
 	C *C.Tcl_Interp
 
 	errfilt func(error) error
+	trace   func(script []byte, d time.Duration, err error)
+
+	// background error handler and the TCL handler it replaced
+	bgerror      func(*TclError)
+	bgerror_prev string
+
+	// debug output, see SetDebug
+	debugw   io.Writer
+	debuglvl DebugLevel
 
 	// registered commands
-	commands map[string]interface{}
+	commands map[string]*command_data
 
 	// registered method sets
-	methods map[string]interface{}
+	methods map[string]*method_set
+
+	// the namespaces of the registered dispatchers
+	dispatchers map[string]string
 
 	// just a buffer to avoid allocs in _gotk_go_command_handler
 	valuesbuf []reflect.Value
 
+	// counter for generated command names, see next_command
+	lastid int
+
+	// open windows created by NewToplevel
+	toplevels map[string]*Toplevel
+
+	// classes and instances of widgets defined with DefineWidget
+	widgets *custom_widgets
+
+	// bindings added with AddAccelerator
+	accels *accelerators
+
+	// Tk wasn't initialized
+	notk bool
+
+	// Quit was called (quitting) and handled on the interpreter thread (quit)
+	quitting int32
+	quit     bool
+
 	thread C.Tcl_ThreadId
-	queue  chan async_action
-	cmdbuf bytes.Buffer
+	handle cgo.Handle // passed to C instead of the pointer
+	queue  async_queue
+
+	// script buffers, see push_cmdbuf
+	cmdbufs  []*bytes.Buffer
+	cmddepth int
+
+	// the error filter is running, see filt
+	filtering bool
+
+	// see SetEventLoopOptions, loop_events counts the events since the last
+	// yield
+	loop        EventLoopOptions
+	loop_events int
+
+	// the depth of the nested Go command calls and its limit, see
+	// SetNestingLimit
+	nesting       int
+	nesting_limit int
+
+	// see Options.CommandPrefix, immutable
+	prefix string
+
+	// the first panic of a command waiting to be raised again, see repanic
+	panicked *CommandPanicError
+
+	// see UseCommandMiddleware, the generation changes with the list and
+	// invalidates the chains cached by the commands
+	middleware     []func(Handler) Handler
+	middleware_gen int
+
+	stats *interpreter_stats
 }
 
-func new_interpreter() (*interpreter, error) {
+func new_interpreter(opts Options) (_ *interpreter, err error) {
+	err = load_tcl()
+	if err != nil {
+		return nil, err
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
 	ir := &interpreter{
 		C:         C.Tcl_CreateInterp(),
 		errfilt:   func(err error) error { return err },
-		commands:  make(map[string]interface{}),
-		methods:   make(map[string]interface{}),
+		commands:  make(map[string]*command_data),
+		methods:   make(map[string]*method_set),
 		valuesbuf: make([]reflect.Value, 0, 10),
-		queue:     make(chan async_action, 50),
 		thread:    C.Tcl_GetCurrentThread(),
+		stats:     new(interpreter_stats),
+
+		nesting_limit: DefaultNestingLimit,
+		prefix:        opts.CommandPrefix,
 	}
+	ir.queue.init(opts.QueueSize)
+	ir.wait_timeout = int64(opts.WaitTimeout)
+	ir.handle = cgo.NewHandle(ir)
 
+	defer func() {
+		if err != nil {
+			C.Tcl_DeleteInterp(ir.C)
+			ir.handle.Delete()
+		}
+	}()
+
+	err = ir.init_runtime()
+	if err != nil {
+		return nil, err
+	}
 	status := C.Tcl_Init(ir.C)
 	if status != C.TCL_OK {
-		return nil, errors.New(C.GoString(C.Tcl_GetStringResult(ir.C)))
+		return nil, ir.result_error()
+	}
+	if RuntimeFS != nil {
+		err = ir.eval([]byte(init_runtime_encodings))
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	status = C.Tk_Init(ir.C)
-	if status != C.TCL_OK {
-		return nil, errors.New(C.GoString(C.Tcl_GetStringResult(ir.C)))
+	if opts.Argv0 != "" {
+		err = ir.set_var("::argv0", opts.Argv0, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Args != nil {
+		var buf bytes.Buffer
+		buf.WriteString("set ::argv [list")
+		for _, arg := range opts.Args {
+			buf.WriteString(" ")
+			quote(&buf, arg)
+		}
+		buf.WriteString("]; set ::argc [llength $::argv]")
+		err = ir.eval(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for name, value := range opts.Env {
+		cname := C.CString(name)
+		err = ir.set_element(env_array_name, cname, value, GlobalOnly)
+		C.free(unsafe.Pointer(cname))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ir.notk = true
+	if !opts.NoTk {
+		err := ir.init_tk(opts.tk_options())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = ir.app_init(opts.AppInit)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ir.init_api()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ir.init_futures()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ir.init_streams()
+	if err != nil {
+		return nil, err
 	}
 
 	return ir, nil
 }
 
+// works the same way as Tk_MainLoop, but Tk can be initialized later (see
+// InitTk), until then it loops until Quit is called
+func (ir *interpreter) main_loop() {
+	for !ir.quit && (ir.notk || C.Tk_GetNumMainWindows() > 0) {
+		C.Tcl_DoOneEvent(0)
+		ir.repanic()
+		ir.event_handled()
+	}
+}
+
+// Raises the panic of a command without an error handler again, called by
+// the interpreter thread outside of TCL, where no C frames are left to
+// unwind. Until then the panic fails the command (and the scripts calling
+// it) with a TCL error.
+func (ir *interpreter) repanic() {
+	if p := ir.panicked; p != nil {
+		ir.panicked = nil
+		panic(p)
+	}
+}
+
+// Deletes the interpreter and releases the TCL and Tk data of the thread,
+// the displays opened by Tk are closed. The actions sent from other threads
+// that haven't run fail with ErrInterpreterClosed.
+func (ir *interpreter) finalize() {
+	ir.queue.close()
+	ir.queue.cancel()
+	C.Tcl_DeleteInterp(ir.C)
+	C.Tcl_FinalizeThread()
+	ir.handle.Delete()
+}
+
+// Stops the event loop of the interpreter: the main window is destroyed (if
+// Tk is initialized) and Done fires once the current event is handled. Unlike
+// the `exit` command, it doesn't terminate the process, so that applications
+// can close the GUI from Go, e.g. on a remote shutdown request, and carry on.
+// Calls after the first one do nothing.
+func (ir *Interpreter) Quit() error {
+	if !atomic.CompareAndSwapInt32(&ir.ir.quitting, 0, 1) {
+		return nil
+	}
+	return ir.ir.run(func() error {
+		ir.ir.quit = true
+		ir.ir.queue.close()
+		if ir.ir.notk {
+			return nil
+		}
+		return ir.ir.filt(ir.ir.eval([]byte("destroy .")))
+	})
+}
+
 func (ir *interpreter) filt(err error) error {
-	errfilt := ir.errfilt
-	ir.errfilt = nil
-	if errfilt != nil {
-		err = errfilt(err)
+	if ir.errfilt == nil || ir.filtering {
+		return err
 	}
-	ir.errfilt = errfilt
-	return err
+	ir.filtering = true
+	defer func() { ir.filtering = false }()
+	return ir.errfilt(err)
+}
+
+// Returns a buffer for formatting a script on the interpreter thread, the
+// buffers form a stack, so that an evaluation started from a command called
+// by another evaluation doesn't clobber the script TCL is still reading. Must
+// be paired with pop_cmdbuf.
+func (ir *interpreter) push_cmdbuf() *bytes.Buffer {
+	if ir.cmddepth == len(ir.cmdbufs) {
+		ir.cmdbufs = append(ir.cmdbufs, new(bytes.Buffer))
+	}
+	buf := ir.cmdbufs[ir.cmddepth]
+	ir.cmddepth++
+	buf.Reset()
+	return buf
+}
+
+func (ir *interpreter) pop_cmdbuf() {
+	ir.cmddepth--
 }
 
 func (ir *interpreter) eval(script []byte) error {
+	if ir.trace == nil && ir.debuglvl < DebugScripts {
+		return ir.eval_untraced(script)
+	}
+	start := time.Now()
+	err := ir.eval_untraced(script)
+	d := time.Since(start)
+	if ir.trace != nil {
+		ir.trace(script, d, err)
+	}
+	ir.debug_eval(script, d, err)
+	return err
+}
+
+func (ir *interpreter) eval_untraced(script []byte) error {
 	if len(script) == 0 {
 		return nil
 	}
+	atomic.AddUint64(&ir.stats.evals, 1)
+	if s := unsafe.String(&script[0], len(script)); !tcl_utf_valid(s) {
+		script = append_tcl_utf(make([]byte, 0, len(script)+8), s)
+	}
 	status := C.Tcl_EvalEx(ir.C, (*C.char)(unsafe.Pointer(&script[0])),
 		C.int(len(script)), 0)
 	if status != C.TCL_OK {
-		return errors.New(C.GoString(C.Tcl_GetStringResult(ir.C)))
+		return ir.result_error()
 	}
 	return nil
 }
@@ -364,44 +904,66 @@ func (ir *interpreter) eval_as(out interface{}, script []byte) error {
 	return ir.tcl_obj_to_go_value(C.Tcl_GetObjResult(ir.C), v)
 }
 
-func go_value_to_tcl_obj(value interface{}) *C.Tcl_Obj {
+// returns the bytes of the interpreter result, valid until the result changes
+func (ir *interpreter) result_bytes() []byte {
+	var n C.int
+	p := C.Tcl_GetByteArrayFromObj(C.Tcl_GetObjResult(ir.C), &n)
+	if n == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(p)), int(n))
+}
+
+func go_value_to_tcl_obj(value interface{}) (*C.Tcl_Obj, error) {
 	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return nil, errors.New("gothic: cannot convert Go value to TCL object")
+	}
+	if obj, ok, err := convert_to_tcl_obj(v); ok {
+		return obj, err
+	}
+	if is_time_type(v.Type()) {
+		return time_to_tcl_obj(v), nil
+	}
+	if m, ok := value.(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return NewStringObj(string(text)).p, nil
+	}
 	switch v.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return C.Tcl_NewWideIntObj(C.Tcl_WideInt(v.Int()))
+		return C.Tcl_NewWideIntObj(C.Tcl_WideInt(v.Int())), nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return C.Tcl_NewWideIntObj(C.Tcl_WideInt(v.Uint()))
+		return C.Tcl_NewWideIntObj(C.Tcl_WideInt(v.Uint())), nil
 	case reflect.Float32, reflect.Float64:
-		return C.Tcl_NewDoubleObj(C.double(v.Float()))
+		return C.Tcl_NewDoubleObj(C.double(v.Float())), nil
 	case reflect.Bool:
 		if v.Bool() {
-			return C.Tcl_NewBooleanObj(1)
+			return C.Tcl_NewBooleanObj(1), nil
 		}
-		return C.Tcl_NewBooleanObj(0)
+		return C.Tcl_NewBooleanObj(0), nil
 	case reflect.String:
-		s := v.String()
-		sh := *(*reflect.StringHeader)(unsafe.Pointer(&s))
-		return C.Tcl_NewStringObj((*C.char)(unsafe.Pointer(sh.Data)), C.int(len(s)))
+		return new_tcl_string_obj(v.String()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := v.Bytes()
+			if len(b) == 0 {
+				return C.Tcl_NewByteArrayObj(nil, 0), nil
+			}
+			return C.Tcl_NewByteArrayObj((*C.uchar)(unsafe.Pointer(&b[0])), C.int(len(b))), nil
+		}
 	}
-	return nil
+	return nil, fmt.Errorf("gothic: cannot convert Go value of type %s to TCL object", v.Type())
 }
 
 func (ir *interpreter) set(name string, value interface{}) error {
-	obj := go_value_to_tcl_obj(value)
-	if obj == nil {
-		return errors.New("gothic: cannot convert Go value to TCL object")
-	}
-
-	cname := C.CString(name)
-	obj = C.Tcl_SetVar2Ex(ir.C, cname, nil, obj, C.TCL_LEAVE_ERR_MSG)
-	C.free(unsafe.Pointer(cname))
-	if obj == nil {
-		return errors.New(C.GoString(C.Tcl_GetStringResult(ir.C)))
-	}
-	return nil
+	return ir.set_var(name, value, 0)
 }
 
 func (ir *interpreter) upload_image(name string, img image.Image) error {
+	atomic.AddUint64(&ir.stats.image_uploads, 1)
 	var buf bytes.Buffer
 	err := sprintf(&buf, "image create photo %{}", name)
 	if err != nil {
@@ -433,6 +995,11 @@ func (ir *interpreter) upload_image(name string, img image.Image) error {
 		}
 	}
 	C.free(unsafe.Pointer(cname))
+
+	// the block points to the Go pixels for the duration of the call
+	var pinner runtime.Pinner
+	pinner.Pin(&nrgba.Pix[0])
+	defer pinner.Unpin()
 	block := C.Tk_PhotoImageBlock{
 		(*C.uchar)(unsafe.Pointer(&nrgba.Pix[0])),
 		C.int(nrgba.Rect.Max.X),
@@ -446,7 +1013,7 @@ func (ir *interpreter) upload_image(name string, img image.Image) error {
 		C.int(nrgba.Rect.Max.X), C.int(nrgba.Rect.Max.Y),
 		C.TK_PHOTO_COMPOSITE_SET)
 	if status != C.TCL_OK {
-		return errors.New(C.GoString(C.Tcl_GetStringResult(ir.C)))
+		return ir.result_error()
 	}
 	return nil
 }
@@ -454,6 +1021,18 @@ func (ir *interpreter) upload_image(name string, img image.Image) error {
 func (ir *interpreter) tcl_obj_to_go_value(obj *C.Tcl_Obj, v reflect.Value) error {
 	var status C.int
 
+	if ok, err := convert_from_tcl_obj(obj, v); ok {
+		return err
+	}
+	if is_time_type(v.Type()) {
+		return ir.tcl_obj_to_time(obj, v)
+	}
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(tcl_obj_to_go_string(obj)))
+		}
+	}
+
 	switch v.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		var out C.Tcl_WideInt
@@ -468,9 +1047,7 @@ func (ir *interpreter) tcl_obj_to_go_value(obj *C.Tcl_Obj, v reflect.Value) erro
 			v.SetUint(uint64(out))
 		}
 	case reflect.String:
-		var n C.int
-		out := C.Tcl_GetStringFromObj(obj, &n)
-		v.SetString(C.GoStringN(out, n))
+		v.SetString(tcl_obj_to_go_string(obj))
 	case reflect.Float32, reflect.Float64:
 		var out C.double
 		status = C.Tcl_GetDoubleFromObj(ir.C, obj, &out)
@@ -483,12 +1060,33 @@ func (ir *interpreter) tcl_obj_to_go_value(obj *C.Tcl_Obj, v reflect.Value) erro
 		if status == C.TCL_OK {
 			v.SetBool(out == 1)
 		}
+	case reflect.Struct:
+		return ir.tcl_list_to_go_struct(obj, v)
+	case reflect.Ptr:
+		// optional arguments, see RegisterCommand
+		p := reflect.New(v.Type().Elem())
+		err := ir.tcl_obj_to_go_value(obj, p.Elem())
+		if err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("gothic: cannot convert TCL object to Go type: %s", v.Type())
+		}
+		var n C.int
+		out := C.Tcl_GetByteArrayFromObj(obj, &n)
+		b := reflect.MakeSlice(v.Type(), int(n), int(n))
+		if n > 0 {
+			copy(b.Bytes(), unsafe.Slice((*byte)(unsafe.Pointer(out)), int(n)))
+		}
+		v.Set(b)
 	default:
 		return fmt.Errorf("gothic: cannot convert TCL object to Go type: %s", v.Type())
 	}
 
 	if status != C.TCL_OK {
-		return errors.New(C.GoString(C.Tcl_GetStringResult(ir.C)))
+		return ir.result_error()
 	}
 	return nil
 }
@@ -497,69 +1095,168 @@ func (ir *interpreter) tcl_obj_to_go_value(obj *C.Tcl_Obj, v reflect.Value) erro
 // interpreter.commands
 //------------------------------------------------------------------------------
 
-//export _gotk_go_command_handler
-func _gotk_go_command_handler(clidataup unsafe.Pointer, objc C.int, objv unsafe.Pointer) C.int {
-	// TODO: There is an idea of optimizing everything by a large margin,
-	// we can preprocess the type of a command in RegisterCommand function
-	// and then avoid calling reflect.New for every argument passed to that
-	// function. And we can even do additional error checks for unsupported
-	// argument types and handle multiple return values case.
+// The client data of a command registered by register_command or
+// register_commands, C side only has a cgo.Handle of it.
+type command_data struct {
+	ir   *interpreter
+	name string
+	fn   reflect.Value
+	recv reflect.Value // the method receiver, invalid for commands
 
-	clidata := (*C.GoTkClientData)(clidataup)
-	ir := (*interpreter)(clidata.go_interp)
-	args := (*(*[alot]*C.Tcl_Obj)(objv))[1:objc]
-	cb := c_interface_to_go_interface(clidata.iface)
-	f := reflect.ValueOf(cb)
-	ft := f.Type()
+	// passed to the handlers taking a context.Context first, canceled when
+	// the command is deleted
+	ctx       context.Context
+	cancel    context.CancelFunc
+	takes_ctx bool
 
-	ir.valuesbuf = ir.valuesbuf[:0]
-	for i, n := 0, ft.NumIn(); i < n; i++ {
-		in := ft.In(i)
+	site string // file:line of the RegisterCommand call, see Commands
 
-		// use default value, if there is not enough args
-		if len(args) <= i {
-			ir.valuesbuf = append(ir.valuesbuf, reflect.New(in).Elem())
-			continue
-		}
+	// the middleware chain ending with call, see chain
+	handler     Handler
+	handler_gen int
 
-		v := reflect.New(in).Elem()
-		err := ir.tcl_obj_to_go_value(args[i], v)
-		if err != nil {
-			C._gotk_c_tcl_set_result(ir.C, C.CString(err.Error()))
-			return C.TCL_ERROR
-		}
+	onerror func(name string, err error) error // see CommandOpts
 
-		ir.valuesbuf = append(ir.valuesbuf, v)
-	}
+	// the last argument is an options struct (see OptionArgs), defaults
+	// caches its default value
+	options  bool
+	defaults reflect.Value
+
+	// the number of the TCL arguments is checked (see RegisterCommand), max
+	// is -1 if unlimited
+	strict   bool
+	min, max int
+	usage    string
+
+	// the first return value becomes the result, see tcl_result_type
+	result bool
+}
 
-	// TODO: handle return value
-	f.Call(ir.valuesbuf)
+// A method set registered by register_commands.
+type method_set struct {
+	val  interface{}
+	site string // file:line of the RegisterCommands call
+}
+
+var context_type = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+//export _gotk_go_command_handler
+func _gotk_go_command_handler(data C.uintptr_t, objc C.int, objv unsafe.Pointer) C.int {
+	cd := cgo.Handle(data).Value().(*command_data)
+	ir := cd.ir
+	if ir.nesting >= ir.nesting_limit {
+		msg := fmt.Sprintf("gothic: too many nested Go commands (limit %d, infinite recursion?)", ir.nesting_limit)
+		C._gotk_c_tcl_set_result(ir.C, C.CString(msg))
+		return C.TCL_ERROR
+	}
+	ir.nesting++
+	defer func() { ir.nesting-- }()
+	if cd.recv.IsValid() {
+		ir.debug_dispatch("method", objc, objv)
+	} else {
+		ir.debug_dispatch("command", objc, objv)
+	}
+	args := (*(*[alot]*C.Tcl_Obj)(objv))[1:objc]
 
+	err := cd.dispatch(args)
+	if perr, ok := err.(*CommandPanicError); ok && cd.onerror == nil && ir.panicked == nil {
+		// the panic can't unwind through the C frames of TCL, it's raised
+		// again once the interpreter thread is out of TCL, see repanic
+		ir.panicked = perr
+	}
+	if err != nil && cd.onerror != nil {
+		err = cd.onerror(cd.name, err)
+		if err == nil {
+			C.Tcl_ResetResult(ir.C)
+		}
+	}
+	if err != nil {
+		C._gotk_c_tcl_set_result(ir.C, C.CString(err.Error()))
+		return C.TCL_ERROR
+	}
 	return C.TCL_OK
 }
 
-//export _gotk_go_method_handler
-func _gotk_go_method_handler(clidataup unsafe.Pointer, objc C.int, objv unsafe.Pointer) C.int {
+// Calls the command through the middleware. Panics are returned as a
+// *CommandPanicError, they must not unwind the C frames the command is
+// called from.
+func (cd *command_data) dispatch(args []*C.Tcl_Obj) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &CommandPanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	if len(cd.ir.middleware) == 0 {
+		return cd.call(args)
+	}
+	return cd.chain()(&CommandCall{Name: cd.name, Args: *(*[]Obj)(unsafe.Pointer(&args))})
+}
+
+// Converts the arguments, calls the Go function and sets the result of the
+// command.
+func (cd *command_data) call(args []*C.Tcl_Obj) error {
 	// TODO: There is an idea of optimizing everything by a large margin,
 	// we can preprocess the type of a command in RegisterCommand function
 	// and then avoid calling reflect.New for every argument passed to that
 	// function. And we can even do additional error checks for unsupported
 	// argument types and handle multiple return values case.
 
-	clidata := (*C.GoTkClientData)(clidataup)
-	ir := (*interpreter)(clidata.go_interp)
-	args := (*(*[alot]*C.Tcl_Obj)(objv))[1:objc]
-	cb := c_interface_to_go_interface(clidata.iface)
-	recv := c_interface_to_go_interface(clidata.iface2)
-	f := reflect.ValueOf(cb)
-	ft := f.Type()
-
-	ir.valuesbuf = ir.valuesbuf[:0]
-	ir.valuesbuf = append(ir.valuesbuf, reflect.ValueOf(recv))
-	for i, n := 1, ft.NumIn(); i < n; i++ {
-		ia := i - 1
+	ir := cd.ir
+	ft := cd.fn.Type()
+	if cd.strict && (len(args) < cd.min || cd.max >= 0 && len(args) > cd.max) {
+		return fmt.Errorf("wrong # args: should be \"%s\"", cd.usage)
+	}
+
+	// the arguments go on top of the ones of the commands up the stack, the
+	// conversions and the call can evaluate scripts calling other commands
+	base := len(ir.valuesbuf)
+	defer func() { ir.valuesbuf = ir.valuesbuf[:base] }()
+	first := 0
+	if cd.recv.IsValid() {
+		ir.valuesbuf = append(ir.valuesbuf, cd.recv)
+		first = 1
+	}
+	if cd.takes_ctx {
+		ir.valuesbuf = append(ir.valuesbuf, reflect.ValueOf(&cd.ctx).Elem())
+		first++
+	}
+	for i, n := first, ft.NumIn(); i < n; i++ {
+		ia := i - first
 		in := ft.In(i)
 
+		// the options struct takes the rest of the arguments
+		if cd.options && i == n-1 {
+			v, err := cd.option_defaults()
+			if err != nil {
+				return err
+			}
+			if ia < len(args) {
+				err = ir.tcl_args_to_go_options(args[ia:], v)
+				if err != nil {
+					return err
+				}
+			}
+			ir.valuesbuf = append(ir.valuesbuf, v)
+			break
+		}
+
+		// and so does the variadic argument
+		if ft.IsVariadic() && i == n-1 {
+			rest := 0
+			if ia < len(args) {
+				rest = len(args) - ia
+			}
+			v := reflect.MakeSlice(in, rest, rest)
+			for j := 0; j < rest; j++ {
+				err := ir.tcl_obj_to_go_value(args[ia+j], v.Index(j))
+				if err != nil {
+					return err
+				}
+			}
+			ir.valuesbuf = append(ir.valuesbuf, v)
+			break
+		}
+
 		// use default value, if there is not enough args
 		if len(args) <= ia {
 			ir.valuesbuf = append(ir.valuesbuf, reflect.New(in).Elem())
@@ -569,24 +1266,164 @@ func _gotk_go_method_handler(clidataup unsafe.Pointer, objc C.int, objv unsafe.P
 		v := reflect.New(in).Elem()
 		err := ir.tcl_obj_to_go_value(args[ia], v)
 		if err != nil {
-			C._gotk_c_tcl_set_result(ir.C, C.CString(err.Error()))
-			return C.TCL_ERROR
+			return err
 		}
 
 		ir.valuesbuf = append(ir.valuesbuf, v)
 	}
 
-	// TODO: handle return value
-	f.Call(ir.valuesbuf)
+	atomic.AddUint64(&ir.stats.command_calls, 1)
+	if ft.IsVariadic() {
+		return cd.set_result(cd.fn.CallSlice(ir.valuesbuf[base:]))
+	}
+	return cd.set_result(cd.fn.Call(ir.valuesbuf[base:]))
+}
 
-	return C.TCL_OK
+// Returns the minimum and maximum (-1 if unlimited) number of the TCL
+// arguments of the command and its usage for the "wrong # args" error, e.g.
+// "cmd arg1 ?arg2?". The arguments of the function start at `first`.
+func (cd *command_data) arity(first int) (min, max int, usage string) {
+	ft := cd.fn.Type()
+	n := ft.NumIn()
+	var buf bytes.Buffer
+	buf.WriteString(cd.name)
+	tail := ""
+	switch {
+	case cd.options:
+		n--
+		max = -1
+		tail = " ?-option value ...?"
+	case ft.IsVariadic():
+		n--
+		max = -1
+		tail = " ?arg ...?"
+	default:
+		max = n - first
+	}
+	// pointer arguments are optional, unless followed by a required one
+	for i := first; i < n; i++ {
+		if ft.In(i).Kind() != reflect.Ptr {
+			min = i - first + 1
+		}
+	}
+	for i := first; i < n; i++ {
+		if i-first < min {
+			fmt.Fprintf(&buf, " arg%d", i-first+1)
+		} else {
+			fmt.Fprintf(&buf, " ?arg%d?", i-first+1)
+		}
+	}
+	buf.WriteString(tail)
+	return min, max, buf.String()
+}
+
+// Returns a copy of the default value of the options argument.
+func (cd *command_data) option_defaults() (reflect.Value, error) {
+	ft := cd.fn.Type()
+	t := ft.In(ft.NumIn() - 1)
+	if !cd.defaults.IsValid() {
+		v, err := cd.ir.option_defaults(t)
+		if err != nil {
+			return v, err
+		}
+		cd.defaults = v
+	}
+	v := reflect.New(t).Elem()
+	v.Set(cd.defaults)
+	return v, nil
+}
+
+var error_type = reflect.TypeOf((*error)(nil)).Elem()
+
+var text_marshaler_type = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// Reports whether the values of type `t` returned by a command handler are
+// converted to the result of the command (see go_value_to_tcl_obj).
+// Interface values are checked when they are converted, nil is an empty
+// result. Other types were ignored before return values became results and
+// still are.
+func tcl_result_type(t reflect.Type) bool {
+	if c, ok := lookup_converter(t); ok && c.to != nil {
+		return true
+	}
+	if is_time_type(t) || t.Implements(text_marshaler_type) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool, reflect.String,
+		reflect.Interface:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.Chan:
+		return t.ChanDir()&reflect.RecvDir != 0
+	}
+	return false
+}
+
+// Handles the return values of a command handler. If the last one is an error
+// and it's not nil, it's returned. Otherwise the first return value (if any)
+// becomes the result of the command if its type is a result type (see
+// tcl_result_type), receive channels become stream tokens (see
+// start_stream). Failing to convert it fails the command.
+func (cd *command_data) set_result(results []reflect.Value) error {
+	if n := len(results); n > 0 && results[n-1].Type() == error_type {
+		if err := results[n-1].Interface(); err != nil {
+			return err.(error)
+		}
+	}
+	if !cd.result {
+		return nil
+	}
+
+	ir := cd.ir
+	result := results[0].Interface()
+	switch results[0].Kind() {
+	case reflect.Chan:
+		result = ir.start_stream(results[0])
+	case reflect.Interface:
+		if result == nil {
+			return nil
+		}
+	}
+	obj, err := go_value_to_tcl_obj(result)
+	if err != nil {
+		return err
+	}
+	C.Tcl_SetObjResult(ir.C, obj)
+	return nil
 }
 
 //export _gotk_go_command_deleter
-func _gotk_go_command_deleter(data unsafe.Pointer) {
-	clidata := (*C.GoTkClientData)(data)
-	ir := (*interpreter)(clidata.go_interp)
-	delete(ir.commands, cgo_string_to_go_string(clidata.strp, clidata.strn))
+func _gotk_go_command_deleter(data C.uintptr_t) {
+	h := cgo.Handle(data)
+	cd := h.Value().(*command_data)
+	if !cd.recv.IsValid() {
+		delete(cd.ir.commands, cd.name)
+	}
+	cd.cancel()
+	h.Delete()
+}
+
+func (ir *interpreter) add_command(cd *command_data) {
+	first := 0
+	if cd.recv.IsValid() {
+		first = 1
+	}
+	ft := cd.fn.Type()
+	cd.takes_ctx = ft.NumIn() > first && ft.In(first) == context_type
+	cd.options = ft.NumIn() > first && is_option_args(ft.In(ft.NumIn()-1))
+	if cd.takes_ctx {
+		first++
+	}
+	cd.min, cd.max, cd.usage = cd.arity(first)
+	cd.result = ft.NumOut() > 0 && ft.Out(0) != error_type && tcl_result_type(ft.Out(0))
+	cd.ctx, cd.cancel = context.WithCancel(context.Background())
+	cname := C.CString(cd.name)
+	C._gotk_c_add_command(ir.C, cname, C.uintptr_t(cgo.NewHandle(cd)))
+	C.free(unsafe.Pointer(cname))
 }
 
 func (ir *interpreter) register_command(name string, cbfunc interface{}) error {
@@ -597,12 +1434,24 @@ func (ir *interpreter) register_command(name string, cbfunc interface{}) error {
 	if _, ok := ir.commands[name]; ok {
 		return errors.New("gothic: command with the same name was already registered")
 	}
-	ir.commands[name] = cbfunc
-	cp, cn := go_string_to_cgo_string(name)
-	cname := C.CString(name)
-	C._gotk_c_add_command(ir.C, cname, unsafe.Pointer(ir), cp, cn,
-		go_interface_to_c_interface(cbfunc))
-	C.free(unsafe.Pointer(cname))
+	cd := &command_data{ir: ir, name: name, fn: reflect.ValueOf(cbfunc)}
+	ir.commands[name] = cd
+	ir.add_command(cd)
+	return nil
+}
+
+// registers a command on behalf of the caller at `site`, `opts` can be nil
+func (ir *interpreter) register_command_at(name string, cbfunc interface{}, site string, opts *CommandOpts) error {
+	err := ir.register_command(name, cbfunc)
+	if err != nil {
+		return err
+	}
+	cd := ir.commands[name]
+	cd.site = site
+	cd.strict = true
+	if opts != nil {
+		cd.onerror = opts.OnError
+	}
 	return nil
 }
 
@@ -610,7 +1459,7 @@ func (ir *interpreter) register_commands(name string, val interface{}) error {
 	if _, ok := ir.methods[name]; ok {
 		return errors.New("gothic: method set with the same name was already registered")
 	}
-	ir.methods[name] = val
+	ir.methods[name] = &method_set{val: val}
 	t := reflect.TypeOf(val)
 	for i, n := 0, t.NumMethod(); i < n; i++ {
 		m := t.Method(i)
@@ -623,16 +1472,22 @@ func (ir *interpreter) register_commands(name string, val interface{}) error {
 			subname = m.Name[4:]
 		}
 
-		cname := C.CString(name + "::" + subname)
-		C._gotk_c_add_method(ir.C, cname, unsafe.Pointer(ir),
-			go_interface_to_c_interface(m.Func.Interface()),
-			go_interface_to_c_interface(val))
-		C.free(unsafe.Pointer(cname))
+		ir.add_command(&command_data{
+			ir:   ir,
+			name: name + "::" + subname,
+			fn:   m.Func,
+			recv: reflect.ValueOf(val),
+
+			strict: true,
+		})
 	}
 	return nil
 }
 
 func (ir *interpreter) unregister_command(name string) error {
+	if ok, err := ir.unregister_dispatcher(name); ok {
+		return err
+	}
 	if _, ok := ir.commands[name]; !ok {
 		return errors.New("gothic: trying to unregister a non-existent command")
 	}
@@ -640,7 +1495,7 @@ func (ir *interpreter) unregister_command(name string) error {
 	status := C.Tcl_DeleteCommand(ir.C, cname)
 	C.free(unsafe.Pointer(cname))
 	if status != C.TCL_OK {
-		return errors.New(C.GoString(C.Tcl_GetStringResult(ir.C)))
+		return ir.result_error()
 	}
 	return nil
 }
@@ -649,8 +1504,7 @@ func (ir *interpreter) unregister_commands(name string) error {
 	if _, ok := ir.methods[name]; !ok {
 		return errors.New("gothic: trying to unregister a non-existent method set")
 	}
-	val := ir.methods[name]
-	t := reflect.TypeOf(val)
+	t := reflect.TypeOf(ir.methods[name].val)
 	for i, n := 0, t.NumMethod(); i < n; i++ {
 		m := t.Method(i)
 		if !strings.HasPrefix(m.Name, "TCL") {
@@ -666,7 +1520,7 @@ func (ir *interpreter) unregister_commands(name string) error {
 		status := C.Tcl_DeleteCommand(ir.C, cname)
 		C.free(unsafe.Pointer(cname))
 		if status != C.TCL_OK {
-			return errors.New(C.GoString(C.Tcl_GetStringResult(ir.C)))
+			return ir.result_error()
 		}
 	}
 	delete(ir.methods, name)
@@ -677,44 +1531,96 @@ func (ir *interpreter) unregister_commands(name string) error {
 // interpreter.async
 //------------------------------------------------------------------------------
 
-type async_action struct {
-	result *error
-	action func() error
-	cond   *sync.Cond
+// returns a unique name for an internal command within ::gothic namespace,
+// must be called on the interpreter thread
+func (ir *interpreter) next_command(prefix string) string {
+	ir.lastid++
+	return fmt.Sprintf("::gothic::%s%d", prefix, ir.lastid)
+}
+
+// runs the action on the interpreter thread, either directly or through the
+// queue
+func (ir *interpreter) run(action func() error) error {
+	if C.Tcl_GetCurrentThread() == ir.thread {
+		return action()
+	}
+	return ir.run_and_wait(action)
+}
+
+func (ir *interpreter) run_and_wait(action func() error) error {
+	return ir.submit(action, true)
 }
 
-func (ir *interpreter) run_and_wait(action func() error) (err error) {
-	cond := sync.NewCond(&sync.Mutex{})
-	cond.L.Lock()
+// same as run_and_wait, but returns ErrBusy instead of blocking when the
+// queue is full
+func (ir *interpreter) try_run_and_wait(action func() error) error {
+	return ir.submit(action, false)
+}
+
+func (ir *interpreter) submit(action func() error, block bool) error {
+	if err := ir.queue.reserve(block); err != nil {
+		return err
+	}
 
 	// send event
-	ir.queue <- async_action{result: &err, action: action, cond: cond}
-	ev := C._gotk_c_new_async_event(unsafe.Pointer(ir))
-	C.Tcl_ThreadQueueEvent(ir.thread, ev, C.TCL_QUEUE_TAIL)
-	C.Tcl_ThreadAlert(ir.thread)
+	a := new_async_action(action)
+	ir.queue.push(a)
+	ir.stats.queued(ir.queue.len())
+	if ir.queue.schedule() {
+		ir.notify()
+	}
 
 	// wait for result
-	cond.Wait()
-	cond.L.Unlock()
+	return ir.queue.wait(a, time.Duration(atomic.LoadInt64(&ir.wait_timeout)))
+}
 
-	return
+// queues a Tcl event that drains the async queue on the interpreter thread
+func (ir *interpreter) notify() {
+	ev := C._gotk_c_new_async_event(C.uintptr_t(ir.handle))
+	C.Tcl_ThreadQueueEvent(ir.thread, ev, C.TCL_QUEUE_TAIL)
+	C.Tcl_ThreadAlert(ir.thread)
 }
 
+// The maximum number of actions run per async event. The rest waits for
+// another event queued at the tail, so that the other events (redraws,
+// timers, input) aren't starved under load.
+const async_budget = 64
+
 //export _gotk_go_async_handler
 func _gotk_go_async_handler(ev unsafe.Pointer, flags C.int) C.int {
 	if flags != C.TK_ALL_EVENTS {
 		return 0
 	}
 	event := (*C.GoTkAsyncEvent)(ev)
-	ir := (*interpreter)(event.go_interp)
-	action := <-ir.queue
-	if action.result == nil {
-		action.action()
-	} else {
-		*action.result = action.action()
+	ir := cgo.Handle(event.go_interp).Value().(*interpreter)
+	// a single event is queued when the queue becomes non-empty, drain the
+	// actions pushed so far
+	ir.queue.unschedule()
+	for i := 0; i < async_budget; i++ {
+		action := ir.queue.pop()
+		if action == nil {
+			// empty, or a push is in progress, its producer schedules
+			// the queue again
+			return 1
+		}
+		wait := time.Since(action.queued)
+		ir.stats.dequeued(wait)
+		ir.debugf(DebugQueue, "async action waited in queue for %s", wait)
+		if !action.start() {
+			// timed out, nobody waits for it
+			action.free()
+			continue
+		}
+		if ir.queue.is_closed() {
+			// Quit was called, the loop exits after this event
+			action.err = ErrInterpreterClosed
+		} else {
+			action.err = action.action()
+		}
+		action.done <- struct{}{}
+	}
+	if ir.queue.len() > 0 && ir.queue.schedule() {
+		ir.notify()
 	}
-	action.cond.L.Lock()
-	action.cond.Signal()
-	action.cond.L.Unlock()
 	return 1
 }
@@ -1,18 +1,12 @@
+#ifndef GOTHIC_INTERPRETER_H
+#define GOTHIC_INTERPRETER_H
+
 #include <stdlib.h>
+#include <stdint.h>
 #include <tcl.h>
 #include <tk.h>
 
-typedef struct {
-	void *go_interp; // go tcl/tk interpreter
-	char *strp;      // go string ptr
-	int strn;        // go string len
-	void *iface[2];  // go interface
-	void *iface2[2]; // go interface (used for receiver/method pair)
-} GoTkClientData;
-
-
 void _gotk_c_tcl_set_result(Tcl_Interp *interp, char *result);
-GoTkClientData *_gotk_c_client_data_new(void *go_interp, char *strp, int strn, void **iface, void **iface2);
 
 //------------------------------------------------------------------------------
 // Command
@@ -20,10 +14,11 @@ GoTkClientData *_gotk_c_client_data_new(void *go_interp, char *strp, int strn, v
 
 int _gotk_c_command_handler(ClientData cd, Tcl_Interp *interp, int objc, Tcl_Obj *CONST objv[]);
 void _gotk_c_command_deleter(ClientData cd);
-void _gotk_c_add_command(Tcl_Interp *interp, const char *name, void *go_interp,
-	char *strp, int strn, void **iface);
-void _gotk_c_add_method(Tcl_Interp *interp, const char *name, void *go_interp,
-	void **iface, void **iface2);
+// `data` is a cgo.Handle of the Go side command data
+void _gotk_c_add_command(Tcl_Interp *interp, const char *name, uintptr_t data);
+// Tcl_IncrRefCount and Tcl_DecrRefCount are macros
+void _gotk_c_incr_ref_count(Tcl_Obj *obj);
+void _gotk_c_decr_ref_count(Tcl_Obj *obj);
 
 //------------------------------------------------------------------------------
 // Async
@@ -31,7 +26,69 @@ void _gotk_c_add_method(Tcl_Interp *interp, const char *name, void *go_interp,
 
 typedef struct {
 	Tcl_Event header;
-	void *go_interp;
+	uintptr_t go_interp; // cgo.Handle of the go interpreter
 } GoTkAsyncEvent;
 
-Tcl_Event *_gotk_c_new_async_event(void *go_interp);
+Tcl_Event *_gotk_c_new_async_event(uintptr_t go_interp);
+
+//------------------------------------------------------------------------------
+// Filesystem
+//------------------------------------------------------------------------------
+
+int _gotk_c_fs_register(void);
+void _gotk_c_fs_changed(void);
+void _gotk_c_fs_append(Tcl_Obj *result, Tcl_Obj *dir, const char *name, int n);
+
+//------------------------------------------------------------------------------
+// Photo formats
+//------------------------------------------------------------------------------
+
+#define GOTHIC_PHOTO_FORMATS 16
+
+void _gotk_c_photo_format_init(int slot, const char *name, int canwrite);
+void _gotk_c_photo_format_register(int slot);
+
+//------------------------------------------------------------------------------
+// Canvas items
+//------------------------------------------------------------------------------
+
+#define GOTHIC_ITEM_TYPES 16
+#define GOTHIC_ITEM_OPTIONS 32
+
+typedef struct {
+	Tk_Item header;
+	double coords[4];
+	Tcl_Interp *interp;
+	Tk_Canvas canvas;
+	char *image_name;
+	Tk_Image image;
+	int dirty;
+	int width, height; // size of the rendered image
+	char *values[GOTHIC_ITEM_OPTIONS];
+} GoTkItem;
+
+void _gotk_c_item_type_init(int slot, const char *name, int nopts);
+void _gotk_c_item_type_option(int slot, int i, const char *name, const char *def);
+void _gotk_c_item_type_register(int slot);
+const char *_gotk_c_item_canvas(GoTkItem *item);
+
+//------------------------------------------------------------------------------
+// Writer channels
+//------------------------------------------------------------------------------
+
+Tcl_Channel _gotk_c_writer_channel(Tcl_Interp *interp, int id);
+void _gotk_c_close_writer_channel(Tcl_Interp *interp, Tcl_Channel chan);
+
+//------------------------------------------------------------------------------
+// Colors
+//------------------------------------------------------------------------------
+
+int _gotk_c_parse_color(Tcl_Interp *interp, const char *name, unsigned short *rgb);
+
+//------------------------------------------------------------------------------
+// Event loop
+//------------------------------------------------------------------------------
+
+void _gotk_c_set_max_block_time(long usec);
+
+#endif
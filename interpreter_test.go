@@ -1,8 +1,19 @@
 package gothic
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+	"unsafe"
+
+	"github.com/nsf/gothic/gothictest"
 )
 
 var ir *Interpreter
@@ -45,3 +56,822 @@ func BenchmarkNativeGo(b *testing.B) {
 	})
 	ir.Eval(`test`)
 }
+
+func TestTclInterpreter(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		var x int
+		err := ir.EvalAs(&x, "expr {%{} + 2}", 40)
+		if err != nil {
+			t.Error(err)
+		} else if x != 42 {
+			t.Errorf("%d != 42", x)
+		}
+
+		err = ir.Eval("winfo exists .")
+		must_contain(t, err, `invalid command name "winfo"`)
+
+		err = ir.InitTk(TkOptions{Display: "gothic-nonexistent:99"})
+		must_contain(t, err, `couldn't connect to display`)
+		err = ir.Eval("winfo exists .")
+		must_contain(t, err, `invalid command name "winfo"`)
+	})
+}
+
+func TestNewError(t *testing.T) {
+	t.Setenv("DISPLAY", "gothic-nonexistent:99")
+	ir, err := New(nil)
+	must_contain(t, err, `couldn't connect to display`)
+	if ir != nil {
+		t.Error("expected nil interpreter on failure")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	_, err := NewWithOptions(func(ir *Interpreter) {
+		var s string
+		err := ir.EvalAs(&s, "list $argv0 $argv $argc")
+		if err != nil {
+			t.Error(err)
+		} else if gold := "prog {-v {a b}} 2"; s != gold {
+			t.Errorf("%s != %s", gold, s)
+		}
+	}, Options{NoTk: true, Argv0: "prog", Args: []string{"-v", "a b"}})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAppInit(t *testing.T) {
+	called := false
+	_, err := NewWithOptions(nil, Options{NoTk: true, AppInit: func(interp unsafe.Pointer) error {
+		called = interp != nil
+		return errors.New("init failed")
+	}})
+	must_contain(t, err, "init failed")
+	if !called {
+		t.Error("AppInit wasn't called with the interpreter")
+	}
+}
+
+func TestSync(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		var n int
+		err := ir.Eval("set ::n 0; after idle {incr ::n}")
+		if err == nil {
+			err = ir.Sync()
+		}
+		if err == nil {
+			err = ir.EvalAs(&n, "set ::n")
+		}
+		if err != nil {
+			t.Error(err)
+		} else if n != 1 {
+			t.Errorf("the idle task didn't run before Sync returned")
+		}
+	})
+}
+
+func TestQuit(t *testing.T) {
+	ir := NewTclInterpreter(func(ir *Interpreter) {
+		err := ir.Quit()
+		if err != nil {
+			t.Error(err)
+		}
+		if err := ir.Quit(); err != nil {
+			t.Error(err)
+		}
+	})
+	select {
+	case <-ir.Done:
+	case <-time.After(5 * time.Second):
+		t.Error("Done didn't fire after Quit")
+	}
+}
+
+func TestQuitTcl(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ir.Eval("after 10000 {set ::never 1}")
+	if err != nil {
+		t.Error(err)
+	}
+	err = ir.Quit()
+	if err != nil {
+		t.Error(err)
+	}
+	select {
+	case <-ir.Done:
+	case <-time.After(5 * time.Second):
+		t.Error("the event loop without Tk didn't stop after Quit")
+	}
+}
+
+func TestRestart(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		ir := NewTclInterpreter(func(ir *Interpreter) {
+			var exists bool
+			err := ir.EvalAs(&exists, "info exists ::marker")
+			if err != nil {
+				t.Error(err)
+			} else if exists {
+				t.Error("the state of the previous interpreter leaked")
+			}
+			ir.Eval("set ::marker 1")
+			ir.Quit()
+		})
+		select {
+		case <-ir.Done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Done didn't fire after Quit")
+		}
+	}
+}
+
+func TestConsoleReadDeadline(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	defer r.Close()
+	ir.StartConsole(r, io.Discard)
+	w.Write([]byte("set x 1\n"))
+	for exists := false; !exists; {
+		err = ir.EvalAs(&exists, "info exists x")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ir.Quit()
+	<-ir.Done
+
+	// the blocked read is interrupted, the next line is left in the pipe
+	time.Sleep(100 * time.Millisecond)
+	w.Write([]byte("set y 2\n"))
+	r.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	n, err := r.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(buf[:n]) != "set y 2\n" {
+		t.Errorf("unexpected input %q", buf[:n])
+	}
+}
+
+func TestInitError(t *testing.T) {
+	ir, err := NewWithOptions(func(ir *Interpreter) error {
+		return ir.Eval("source /nonexistent/assets.tcl")
+	}, Options{NoTk: true})
+	must_contain(t, err, "/nonexistent/assets.tcl")
+	if ir != nil {
+		t.Error("expected nil interpreter on failure")
+	}
+
+	ir, err = NewWithOptions(func(ir *Interpreter) error {
+		return ir.Quit()
+	}, Options{NoTk: true})
+	if err != nil {
+		t.Error(err)
+	} else {
+		<-ir.Done
+	}
+}
+
+func TestEvalRaw(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		b, err := ir.EvalRaw([]byte("string repeat ab 3"))
+		if err != nil {
+			t.Error(err)
+		} else if string(b) != "ababab" {
+			t.Errorf("ababab != %s", b)
+		}
+
+		buf := []byte("x:")
+		buf, err = ir.EvalRawAppend(buf, []byte("binary format c3 {0 1 255}"))
+		if err != nil {
+			t.Error(err)
+		} else if string(buf) != "x:\x00\x01\xff" {
+			t.Errorf("unexpected result %q", buf)
+		}
+
+		_, err = ir.EvalRaw([]byte("error oops"))
+		must_contain(t, err, "oops")
+	})
+}
+
+func TestByteArray(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		// larger than the fixed size arrays C memory used to be cast to
+		in := make([]byte, 3<<20)
+		for i := range in {
+			in[i] = byte(i)
+		}
+		err := ir.Set("data", in)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var out []byte
+		err = ir.EvalAs(&out, "set data")
+		if err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(in, out) {
+			t.Error("the byte array changed on the round trip")
+		}
+
+		err = ir.EvalAs(&out, "binary format x1000001")
+		if err != nil {
+			t.Error(err)
+		} else if len(out) != 1000001 {
+			t.Errorf("%d != 1000001", len(out))
+		}
+	})
+}
+
+// the fake of gothictest implements the same methods
+var _ gothictest.Interp = (*Interpreter)(nil)
+
+func TestForeignThread(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Quit()
+
+	err = ir.RegisterCommand("add", func(a, b int) int { return a + b })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ir.RegisterCommands("counter", &test_counter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var x int
+	err = ir.EvalAs(&x, "add 40 2")
+	if err != nil {
+		t.Error(err)
+	} else if x != 42 {
+		t.Errorf("%d != 42", x)
+	}
+
+	err = ir.EvalAs(&x, "counter::Incr 2; counter::Incr 3")
+	if err != nil {
+		t.Error(err)
+	} else if x != 5 {
+		t.Errorf("%d != 5", x)
+	}
+
+	err = ir.UnregisterCommand("add")
+	if err != nil {
+		t.Error(err)
+	}
+	err = ir.Eval("add 1 2")
+	must_contain(t, err, `invalid command name "add"`)
+}
+
+type test_counter struct{ n int }
+
+func (c *test_counter) TCL_Incr(n int) int {
+	c.n += n
+	return c.n
+}
+
+func TestClosed(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ir.Quit()
+	if err != nil {
+		t.Error(err)
+	}
+	err = ir.Eval("set x 1")
+	if err != ErrInterpreterClosed {
+		t.Errorf("expected ErrInterpreterClosed, got %v", err)
+	}
+	<-ir.Done
+	err = ir.Set("x", 1)
+	if err != ErrInterpreterClosed {
+		t.Errorf("expected ErrInterpreterClosed, got %v", err)
+	}
+	errs := ir.EvalBatch(NewScript("set x 1"), NewScript("set y 2"))
+	for _, err := range errs {
+		if err != ErrInterpreterClosed {
+			t.Errorf("expected ErrInterpreterClosed, got %v", err)
+		}
+	}
+}
+
+func TestNestedEval(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		// the nested evaluation must not clobber the rest of the outer script
+		err := ir.RegisterCommand("inner", func(n int) (int, error) {
+			var x int
+			err := ir.EvalAs(&x, "expr {%{} * 2}; # %{}", n, strings.Repeat("padding ", 100))
+			return x, err
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var s string
+		err = ir.EvalAs(&s, "list [inner 1] [inner [inner 2]] %{%q}", "tail")
+		if err != nil {
+			t.Error(err)
+		} else if s != "2 8 tail" {
+			t.Errorf("2 8 tail != %s", s)
+		}
+
+		// the filter can evaluate scripts and change the filter
+		filtered := 0
+		ir.ErrorFilter(func(err error) error {
+			if err == nil {
+				return nil
+			}
+			filtered++
+			ir.Eval("error nested")
+			return errors.New("filtered")
+		})
+		err = ir.Eval("error outer")
+		must_contain(t, err, "filtered")
+		if filtered != 1 {
+			t.Errorf("the filter was called %d times", filtered)
+		}
+		ir.ErrorFilter(nil)
+	})
+}
+
+func TestNestingLimit(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		depth := 0
+		err := ir.RegisterCommand("recurse", func() error {
+			depth++
+			return ir.Eval("recurse")
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		prev, err := ir.SetNestingLimit(50)
+		if err != nil {
+			t.Error(err)
+		} else if prev != DefaultNestingLimit {
+			t.Errorf("%d != %d", prev, DefaultNestingLimit)
+		}
+		err = ir.Eval("recurse")
+		must_contain(t, err, `too many nested Go commands \(limit 50`)
+		if depth != 50 {
+			t.Errorf("recursed %d times", depth)
+		}
+
+		prev, err = ir.SetRecursionLimit(20)
+		if err != nil {
+			t.Error(err)
+		} else if prev != 1000 {
+			t.Errorf("%d != 1000", prev)
+		}
+		err = ir.Eval("proc r {} {r}; r")
+		must_contain(t, err, "too many nested evaluations")
+	})
+}
+
+type retained_obj struct{ o Obj }
+
+func TestRetainObj(t *testing.T) {
+	RegisterConverter(reflect.TypeOf(retained_obj{}), nil, func(o Obj, v reflect.Value) error {
+		v.Set(reflect.ValueOf(retained_obj{o.Retain()}))
+		return nil
+	})
+	NewTclInterpreter(func(ir *Interpreter) {
+		var kept retained_obj
+		ir.RegisterCommand("keep", func(o retained_obj) {
+			kept = o
+		})
+		// the command deletes itself while it runs
+		ir.RegisterCommand("once", func() error {
+			return ir.Eval("rename once {}")
+		})
+
+		err := ir.Eval("keep [string repeat ab 2]; once; string repeat cd 2")
+		if err != nil {
+			t.Error(err)
+		} else if s := kept.o.String(); s != "abab" {
+			t.Errorf("abab != %s", s)
+		}
+		err = ir.ReleaseObj(kept.o)
+		if err != nil {
+			t.Error(err)
+		}
+		err = ir.Eval("once")
+		must_contain(t, err, `invalid command name "once"`)
+	})
+}
+
+type test_list []string
+
+func TestObjList(t *testing.T) {
+	RegisterConverter(reflect.TypeOf(test_list{}), nil, func(o Obj, v reflect.Value) error {
+		elems, err := o.List()
+		if err != nil {
+			return err
+		}
+		l := test_list{}
+		for _, e := range elems {
+			l = append(l, e.String())
+		}
+		v.Set(reflect.ValueOf(l))
+		return nil
+	})
+	NewTclInterpreter(func(ir *Interpreter) {
+		ir.RegisterCommand("count", func(l test_list) int {
+			return len(l)
+		})
+		for script, gold := range map[string]int{
+			"count {a {b c} d}":         3,
+			"count {}":                  0,
+			"count [lrepeat 1500000 x]": 1500000,
+		} {
+			var n int
+			err := ir.EvalAs(&n, script)
+			if err != nil {
+				t.Error(err)
+			} else if n != gold {
+				t.Errorf("%s: %d != %d", script, gold, n)
+			}
+		}
+		err := ir.Eval("count \"a {b\"")
+		must_contain(t, err, "expected list")
+	})
+}
+
+func TestEventLoopOptions(t *testing.T) {
+	ir, err := NewWithOptions(func(ir *Interpreter) error {
+		return ir.SetEventLoopOptions(EventLoopOptions{
+			MaxBlockTime: 5 * time.Millisecond,
+			YieldEvery:   1,
+		})
+	}, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Quit()
+
+	var n int
+	err = ir.EvalAs(&n, "after 20 {set ::n 1}; vwait ::n; set ::n")
+	if err != nil {
+		t.Error(err)
+	} else if n != 1 {
+		t.Errorf("%d != 1", n)
+	}
+	err = ir.SetEventLoopOptions(EventLoopOptions{})
+	if err != nil {
+		t.Error(err)
+	}
+	err = ir.Eval("set x 1")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCommandContext(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		var ctxs []context.Context
+		err := ir.RegisterCommand("work", func(ctx context.Context, n int) int {
+			ctxs = append(ctxs, ctx)
+			return n * 2
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var x int
+		err = ir.EvalAs(&x, "work 21")
+		if err != nil {
+			t.Error(err)
+		} else if x != 42 {
+			t.Errorf("%d != 42", x)
+		}
+		if len(ctxs) != 1 || ctxs[0].Err() != nil {
+			t.Error("expected a live context")
+			return
+		}
+		ir.UnregisterCommand("work")
+		if ctxs[0].Err() != context.Canceled {
+			t.Error("the context wasn't canceled by UnregisterCommand")
+		}
+	})
+
+	// the interpreter deletion deletes the commands
+	c := &test_worker{}
+	ir := NewTclInterpreter(func(ir *Interpreter) {
+		ir.RegisterCommands("worker", c)
+		ir.Eval("worker::Start")
+		ir.Quit()
+	})
+	<-ir.Done
+	if c.ctx == nil {
+		t.Fatal("the method wasn't called")
+	}
+	if c.ctx.Err() != context.Canceled {
+		t.Error("the context wasn't canceled by the interpreter deletion")
+	}
+}
+
+type test_worker struct{ ctx context.Context }
+
+func (w *test_worker) TCL_Start(ctx context.Context) {
+	w.ctx = ctx
+}
+
+func TestCommandPrefix(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true, CommandPrefix: "mylib::"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Quit()
+
+	ir.RegisterCommand("add", func(a, b int) int { return a + b })
+	ir.RegisterCommand("::global_add", func(a, b int) int { return a + b })
+	ir.RegisterCommandIn("other::", "add", func(a, b int) int { return a + b })
+	ir.RegisterCommands("counter", &test_counter{})
+
+	var s string
+	err = ir.EvalAs(&s, "list [mylib::add 1 2] [global_add 2 2] [other::add 2 3] [mylib::counter::Incr 6]")
+	if err != nil {
+		t.Error(err)
+	} else if s != "3 4 5 6" {
+		t.Errorf("3 4 5 6 != %s", s)
+	}
+
+	err = ir.UnregisterCommand("add")
+	if err != nil {
+		t.Error(err)
+	}
+	err = ir.UnregisterCommand("::other::add")
+	if err != nil {
+		t.Error(err)
+	}
+	err = ir.Eval("mylib::add 1 2")
+	must_contain(t, err, `invalid command name "mylib::add"`)
+}
+
+func TestCommands(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		ir.RegisterCommand("add", func(a, b int) int { return a + b })
+		ir.RegisterCommands("counter", &test_counter{})
+
+		var add, incr *CommandInfo
+		cmds := ir.Commands()
+		for i := range cmds {
+			switch cmds[i].Name {
+			case "add":
+				add = &cmds[i]
+			case "counter::Incr":
+				incr = &cmds[i]
+			}
+		}
+		if add == nil || incr == nil {
+			t.Errorf("missing commands in %v", cmds)
+			return
+		}
+		if add.Signature != "func(int, int) int" || !strings.HasPrefix(add.Site, "interpreter_test.go:") {
+			t.Errorf("unexpected %+v", *add)
+		}
+		if incr.Signature != "func(int) int" || incr.MethodSet != "counter" || incr.Site == "" {
+			t.Errorf("unexpected %+v", *incr)
+		}
+		if sets := ir.MethodSets(); !reflect.DeepEqual(sets, []string{"counter"}) {
+			t.Errorf("unexpected method sets %v", sets)
+		}
+	})
+}
+
+func TestCommandOnError(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		var errs []error
+		opts := CommandOpts{OnError: func(name string, err error) error {
+			errs = append(errs, err)
+			if name == "silent" {
+				return nil
+			}
+			return fmt.Errorf("%s: %v", name, err)
+		}}
+		ir.RegisterCommand("loud", func(n int) error {
+			return errors.New("oops")
+		}, opts)
+		ir.RegisterCommand("silent", func(n int) int {
+			var m map[int]int
+			m[n] = n
+			return n
+		}, opts)
+
+		err := ir.Eval("loud 1")
+		must_contain(t, err, "loud: oops")
+		err = ir.Eval("loud x")
+		must_contain(t, err, `loud: .*"x"`)
+
+		var s string
+		err = ir.EvalAs(&s, "silent 1")
+		if err != nil {
+			t.Error(err)
+		} else if s != "" {
+			t.Errorf("unexpected result %q", s)
+		}
+		if len(errs) != 3 {
+			t.Errorf("the handler was called %d times", len(errs))
+			return
+		}
+		perr, ok := errs[2].(*CommandPanicError)
+		if !ok {
+			t.Errorf("unexpected error %v", errs[2])
+		} else if !strings.Contains(fmt.Sprint(perr.Value), "nil map") || len(perr.Stack) == 0 {
+			t.Errorf("unexpected panic %v", perr.Value)
+		}
+
+		// the nesting depth is restored after the panic
+		err = ir.EvalAs(&s, "silent 2; silent 3")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestOptionalArgs(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		ir.RegisterCommand("opt", func(a int, b *int, s *string) string {
+			out := fmt.Sprint(a)
+			if b != nil {
+				out += fmt.Sprintf(" b=%d", *b)
+			}
+			if s != nil {
+				out += fmt.Sprintf(" s=%q", *s)
+			}
+			return out
+		})
+		for script, gold := range map[string]string{
+			"opt 1":        "1",
+			"opt 1 0":      "1 b=0",
+			`opt 1 2 {}`:   `1 b=2 s=""`,
+			`opt 1 3 text`: `1 b=3 s="text"`,
+		} {
+			var s string
+			err := ir.EvalAs(&s, script)
+			if err != nil {
+				t.Error(err)
+			} else if s != gold {
+				t.Errorf("%s: %s != %s", script, gold, s)
+			}
+		}
+		err := ir.Eval("opt 1 x")
+		must_contain(t, err, `expected integer but got "x"`)
+	})
+}
+
+func TestCommandArity(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		ir.RegisterCommand("two", func(a, b int, c *int) int { return a + b })
+		ir.RegisterCommand("join", func(sep string, parts ...string) string {
+			return strings.Join(parts, sep)
+		})
+		ir.RegisterCommands("counter", &test_counter{})
+
+		err := ir.Eval("two 1")
+		must_contain(t, err, `wrong # args: should be "two arg1 arg2 \?arg3\?"`)
+		err = ir.Eval("two 1 2 3 4")
+		must_contain(t, err, `wrong # args: should be "two arg1 arg2 \?arg3\?"`)
+		err = ir.Eval("join")
+		must_contain(t, err, `wrong # args: should be "join arg1 \?arg ...\?"`)
+		err = ir.Eval("counter::Incr")
+		must_contain(t, err, `wrong # args: should be "counter::Incr arg1"`)
+
+		var s string
+		err = ir.EvalAs(&s, "list [two 1 2] [two 1 2 3] [join ,] [join , a b c]")
+		if err != nil {
+			t.Error(err)
+		} else if gold := "3 3 {} a,b,c"; s != gold {
+			t.Errorf("%s != %s", gold, s)
+		}
+	})
+}
+
+func TestSetVarFlags(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		ir.SetVar("log", "a", GlobalOnly|AppendValue)
+		ir.SetVar("log", "b", GlobalOnly|AppendValue)
+		ir.SetVar("items", "x", AppendValue|ListElement)
+		ir.SetVar("items", "y z", AppendValue|ListElement)
+
+		var s string
+		err := ir.EvalAs(&s, "list $log [llength $items] [lindex $items 1]")
+		if err != nil {
+			t.Error(err)
+		} else if gold := "ab 2 {y z}"; s != gold {
+			t.Errorf("%s != %s", gold, s)
+		}
+	})
+}
+
+func TestUnconvertibleResult(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		ir.RegisterCommand("lookup", func() map[string]int {
+			return map[string]int{"a": 1}
+		})
+		var s string
+		err := ir.EvalAs(&s, "lookup")
+		if err != nil {
+			t.Error(err)
+		} else if s != "" {
+			t.Errorf("unexpected result %q", s)
+		}
+
+		// the result types are checked when registering, the conversion
+		// errors of the others aren't ignored
+		ir.RegisterCommand("any", func(v int) interface{} {
+			if v == 0 {
+				return nil
+			}
+			return map[string]int{"a": 1}
+		})
+		err = ir.EvalAs(&s, "any 0")
+		if err != nil {
+			t.Error(err)
+		} else if s != "" {
+			t.Errorf("unexpected result %q", s)
+		}
+		err = ir.Eval("any 1")
+		must_contain(t, err, "cannot convert Go value of type map")
+
+		ir.RegisterCommand("marshal", func() failing_marshaler {
+			return failing_marshaler{}
+		})
+		err = ir.Eval("marshal")
+		must_contain(t, err, "marshal failed")
+	})
+}
+
+type failing_marshaler struct{}
+
+func (failing_marshaler) MarshalText() ([]byte, error) {
+	return nil, errors.New("marshal failed")
+}
+
+func TestDoneWithConsole(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w := io.Pipe()
+	defer w.Close()
+	ir.StartConsole(r, io.Discard)
+	ir.Quit()
+
+	// the console doesn't consume Done, all the receivers get it
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ir.Done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Done didn't fire after Quit")
+		}
+	}
+}
+
+func TestNestedPanic(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		var outer error
+		ir.RegisterCommand("outer", func() error {
+			return ir.Eval("inner")
+		}, CommandOpts{OnError: func(name string, err error) error {
+			outer = err
+			return err
+		}})
+		ir.RegisterCommand("inner", func() {
+			panic("inner failed")
+		})
+
+		err := ir.Eval("outer")
+		must_contain(t, err, "command panicked: inner failed")
+		if outer == nil {
+			t.Error("the error handler of the outer command wasn't called")
+		}
+		p := ir.ir.panicked
+		if p == nil || p.Value != "inner failed" {
+			t.Errorf("unexpected pending panic %v", p)
+		}
+		// don't let the interpreter thread raise it
+		ir.ir.panicked = nil
+
+		// TCL is in a sane state after the panic
+		var x int
+		err = ir.EvalAs(&x, "expr {40 + 2}")
+		if err != nil || x != 42 {
+			t.Errorf("%d, %v", x, err)
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Information about a widget, see Interpreter.Children.
+type WidgetInfo struct {
+	Path    string `tcl:"path"`
+	Class   string `tcl:"class"`   // e.g. "TButton"
+	Manager string `tcl:"manager"` // geometry manager, empty if unmanaged
+}
+
+// Information about a widget option, as returned by `$w configure`.
+type OptionInfo struct {
+	Name    string // e.g. "-text"
+	DBName  string // option database name
+	DBClass string // option database class
+	Default string
+	Value   string
+
+	// for synonym options (e.g. "-bg") the name of the option it stands for,
+	// other fields except Name are empty
+	Synonym string
+}
+
+// Information about a Go command, see Interpreter.Commands.
+type CommandInfo struct {
+	Name      string // the full TCL name
+	Signature string // the Go function type, e.g. "func(int, int) int"
+
+	// the name of the method set for the commands registered by
+	// RegisterCommands, empty otherwise
+	MethodSet string
+
+	// "file.go:line" of the registration call, empty for the commands
+	// registered by gothic itself
+	Site string
+}
+
+// Returns information about the children of the widget `path`, wrapping
+// `winfo children`.
+func (ir *Interpreter) Children(path string) ([]WidgetInfo, error) {
+	var out []WidgetInfo
+	err := ir.ir.run(func() error {
+		err := ir.Eval(`apply {{w} {
+			set out {}
+			foreach c [winfo children $w] {
+				lappend out [list path $c class [winfo class $c] manager [winfo manager $c]]
+			}
+			return $out
+		}} %{%q}`, path)
+		if err != nil {
+			return err
+		}
+		elems, err := Obj{C.Tcl_GetObjResult(ir.ir.C)}.List()
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		out = make([]WidgetInfo, len(elems))
+		for i, e := range elems {
+			err := ir.ir.tcl_list_to_go_struct(e.p, reflect.ValueOf(&out[i]).Elem())
+			if err != nil {
+				return ir.ir.filt(err)
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Returns all options of the widget `path`, wrapping `$path configure`. The
+// map is keyed by the option name without the leading "-".
+func (ir *Interpreter) Configure(path string) (map[string]OptionInfo, error) {
+	var out map[string]OptionInfo
+	err := ir.ir.run(func() error {
+		err := ir.Eval("%{%q} configure", path)
+		if err != nil {
+			return err
+		}
+		opts, err := Obj{C.Tcl_GetObjResult(ir.ir.C)}.List()
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		out = make(map[string]OptionInfo, len(opts))
+		for _, o := range opts {
+			fields, err := o.List()
+			if err != nil {
+				return ir.ir.filt(err)
+			}
+			s := make([]string, len(fields))
+			for i, f := range fields {
+				s[i] = f.String()
+			}
+
+			var info OptionInfo
+			switch len(s) {
+			case 2:
+				info = OptionInfo{Name: s[0], Synonym: s[1]}
+			case 5:
+				info = OptionInfo{s[0], s[1], s[2], s[3], s[4], ""}
+			default:
+				continue
+			}
+			out[strings.TrimPrefix(info.Name, "-")] = info
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Returns the commands registered by RegisterCommand and RegisterCommands
+// (including the ones gothic registers for itself), sorted by name.
+func (ir *Interpreter) Commands() []CommandInfo {
+	var out []CommandInfo
+	ir.ir.run(func() error {
+		for name, cd := range ir.ir.commands {
+			out = append(out, CommandInfo{
+				Name:      name,
+				Signature: cd.fn.Type().String(),
+				Site:      cd.site,
+			})
+		}
+		for set, ms := range ir.ir.methods {
+			v := reflect.ValueOf(ms.val)
+			t := v.Type()
+			for i, n := 0, t.NumMethod(); i < n; i++ {
+				m := t.Method(i)
+				if !strings.HasPrefix(m.Name, "TCL") {
+					continue
+				}
+				out = append(out, CommandInfo{
+					Name:      set + "::" + strings.TrimPrefix(m.Name[3:], "_"),
+					Signature: v.Method(i).Type().String(),
+					MethodSet: set,
+					Site:      ms.site,
+				})
+			}
+		}
+		return nil
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Returns the names of the method sets registered by RegisterCommands,
+// sorted.
+func (ir *Interpreter) MethodSets() []string {
+	var out []string
+	ir.ir.run(func() error {
+		for name := range ir.ir.methods {
+			out = append(out, name)
+		}
+		return nil
+	})
+	sort.Strings(out)
+	return out
+}
+
+// returns "file.go:line" of the caller of the function calling caller_site,
+// `skip` frames up
+func caller_site(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(file), line)
+}
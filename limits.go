@@ -0,0 +1,39 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+
+// The default maximum nesting of Go commands, see SetNestingLimit.
+const DefaultNestingLimit = 1000
+
+// Sets the maximum nesting depth of TCL evaluations (procedure calls, eval,
+// uplevel and the like, see Tcl_SetRecursionLimit), deeper scripts fail with
+// "too many nested evaluations". TCL's default is 1000. A non-positive `n`
+// leaves the limit unchanged. Returns the previous limit.
+func (ir *Interpreter) SetRecursionLimit(n int) (prev int, err error) {
+	err = ir.ir.run(func() error {
+		prev = int(C.Tcl_SetRecursionLimit(ir.ir.C, C.int(n)))
+		return nil
+	})
+	return prev, err
+}
+
+// Sets the maximum nesting depth of Go commands: a command evaluating a
+// script that calls a command evaluating a script and so on. Each level
+// takes much more C stack than a TCL procedure call, a runaway recursion
+// would crash the process before hitting the TCL recursion limit. Past the
+// limit commands fail with an error instead. Defaults to
+// DefaultNestingLimit, a non-positive `n` leaves the limit unchanged.
+// Returns the previous limit.
+func (ir *Interpreter) SetNestingLimit(n int) (prev int, err error) {
+	err = ir.ir.run(func() error {
+		prev = ir.ir.nesting_limit
+		if n > 0 {
+			ir.ir.nesting_limit = n
+		}
+		return nil
+	})
+	return prev, err
+}
@@ -0,0 +1,152 @@
+package gothic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A node of a layout description file, see Interpreter.LoadLayout. In JSON:
+//
+//	{
+//	        "type": "ttk::frame", "name": "f",
+//	        "layout": {"manager": "grid", "row": 0, "sticky": "nsew"},
+//	        "children": [
+//	                {"type": "ttk::entry", "name": "query",
+//	                 "events": {"<Return>": "search"}},
+//	                {"type": "ttk::button", "options": {"text": "Search"},
+//	                 "command": "search"}
+//	        ]
+//	}
+//
+// Commands and events refer to commands registered beforehand (e.g. with
+// RegisterCommand). Event handlers are invoked with the widget path as an
+// argument, -command callbacks are invoked without arguments, as Tk does.
+type LayoutNode struct {
+	Type    string                 `json:"type" yaml:"type"`
+	Name    string                 `json:"name" yaml:"name"`
+	Options map[string]interface{} `json:"options" yaml:"options"`
+
+	// geometry manager ("manager" key, "pack" by default) and its options
+	Layout map[string]interface{} `json:"layout" yaml:"layout"`
+
+	// name of the command used as the -command option
+	Command string `json:"command" yaml:"command"`
+
+	// event pattern -> command name
+	Events map[string]string `json:"events" yaml:"events"`
+
+	Children []LayoutNode `json:"children" yaml:"children"`
+}
+
+func (n LayoutNode) Spec() Generic {
+	spec := Generic{Type: n.Type, Name: n.Name, Options: n.Options}
+	if n.Command != "" {
+		spec.Options = with_options(n.Options, "command", n.Command)
+	}
+	if n.Layout != nil {
+		spec.Layout = map_layout(n.Layout)
+	}
+	if len(n.Events) > 0 {
+		spec.Bindings = make(map[string]interface{}, len(n.Events))
+		for pattern, cmd := range n.Events {
+			spec.Bindings[pattern] = cmd
+		}
+	}
+	for _, child := range n.Children {
+		spec.Children = append(spec.Children, child)
+	}
+	return spec
+}
+
+// geometry options from a layout description
+type map_layout map[string]interface{}
+
+func (m map_layout) layout_script(window string) []byte {
+	manager, _ := m["manager"].(string)
+	if manager == "" {
+		manager = "pack"
+	}
+	g := new_geometry(manager, []string{window})
+	for name, value := range m {
+		if name != "manager" {
+			g.opts[name] = value
+		}
+	}
+	return g.script()
+}
+
+// returns the names of commands the tree refers to
+func (n *LayoutNode) commands(out []string) []string {
+	if n.Command != "" {
+		out = append(out, n.Command)
+	}
+	for _, cmd := range n.Events {
+		out = append(out, cmd)
+	}
+	for i := range n.Children {
+		out = n.Children[i].commands(out)
+	}
+	return out
+}
+
+var layout_formats = struct {
+	sync.RWMutex
+	m map[string]func([]byte, interface{}) error
+}{m: map[string]func([]byte, interface{}) error{".json": json.Unmarshal}}
+
+// Registers the `unmarshal` function for layout files with the extension
+// `ext` (e.g. ".yaml"), JSON is supported out of the box. To support YAML
+// without adding a dependency to gothic:
+//
+//	gothic.RegisterLayoutFormat(".yaml", yaml.Unmarshal)
+//	gothic.RegisterLayoutFormat(".yml", yaml.Unmarshal)
+func RegisterLayoutFormat(ext string, unmarshal func(data []byte, v interface{}) error) {
+	layout_formats.Lock()
+	layout_formats.m[strings.ToLower(ext)] = unmarshal
+	layout_formats.Unlock()
+}
+
+// Instantiates the layout description `data` within the `parent` window,
+// decoding it with `unmarshal` (e.g. json.Unmarshal). All commands the layout
+// refers to must exist.
+func (ir *Interpreter) LoadLayout(parent string, data []byte, unmarshal func([]byte, interface{}) error) (*UI, error) {
+	var root LayoutNode
+	err := unmarshal(data, &root)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cmd := range root.commands(nil) {
+		var n int
+		err := ir.EvalAs(&n, "llength [info commands %{%q}]", cmd)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ir.ir.filt(fmt.Errorf("gothic: layout refers to unknown command %q", cmd))
+		}
+	}
+	return ir.Build(parent, root)
+}
+
+// Reads and instantiates the layout description file `filename`, the format
+// is chosen by the extension, see RegisterLayoutFormat.
+func (ir *Interpreter) LoadLayoutFile(parent, filename string) (*UI, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	layout_formats.RLock()
+	unmarshal := layout_formats.m[ext]
+	layout_formats.RUnlock()
+	if unmarshal == nil {
+		return nil, fmt.Errorf("gothic: unknown layout format %q", ext)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ir.LoadLayout(parent, data, unmarshal)
+}
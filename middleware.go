@@ -0,0 +1,65 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// A call of a Go command passed through the command middleware, see
+// Interpreter.UseCommandMiddleware.
+type CommandCall struct {
+	Name string // the name the command was registered with
+
+	// the TCL arguments (without the command name), only valid until the
+	// handler returns
+	Args []Obj
+}
+
+// Handles a call of a Go command. The innermost handler converts the
+// arguments, calls the Go function and sets the result of the command, a
+// non-nil error fails the command.
+type Handler func(call *CommandCall) error
+
+// Wraps the handlers of all Go commands (the ones registered before and
+// after the call, including the ones gothic registers for itself) with `mw`,
+// for cross-cutting concerns: logging the calls, measuring the latency of the
+// handlers, rejecting the calls while the application is locked, etc. The
+// middleware added last runs first. `mw` is called on the interpreter thread
+// when a command is called for the first time after a change of the
+// middleware list; the handlers it returns run on the interpreter thread too.
+//
+//	ir.UseCommandMiddleware(func(next gothic.Handler) gothic.Handler {
+//		return func(call *gothic.CommandCall) error {
+//			start := time.Now()
+//			err := next(call)
+//			log.Printf("%s %v: %s", call.Name, err, time.Since(start))
+//			return err
+//		}
+//	})
+func (ir *Interpreter) UseCommandMiddleware(mw func(next Handler) Handler) error {
+	return ir.ir.run(func() error {
+		ir.ir.middleware = append(ir.ir.middleware, mw)
+		ir.ir.middleware_gen++
+		return nil
+	})
+}
+
+// Returns the handler of the command wrapped with the middleware, builds it
+// if the middleware list has changed.
+func (cd *command_data) chain() Handler {
+	ir := cd.ir
+	if cd.handler == nil || cd.handler_gen != ir.middleware_gen {
+		h := Handler(func(call *CommandCall) error {
+			return cd.call(*(*[]*C.Tcl_Obj)(unsafe.Pointer(&call.Args)))
+		})
+		for _, mw := range ir.middleware {
+			h = mw(h)
+		}
+		cd.handler = h
+		cd.handler_gen = ir.middleware_gen
+	}
+	return cd.handler
+}
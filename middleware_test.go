@@ -0,0 +1,51 @@
+package gothic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCommandMiddleware(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		ir.RegisterCommand("add", func(a, b int) int { return a + b })
+
+		var log []string
+		locked := false
+		ir.UseCommandMiddleware(func(next Handler) Handler {
+			return func(call *CommandCall) error {
+				if locked {
+					return errors.New("locked")
+				}
+				return next(call)
+			}
+		})
+		ir.UseCommandMiddleware(func(next Handler) Handler {
+			return func(call *CommandCall) error {
+				args := make([]string, len(call.Args))
+				for i, a := range call.Args {
+					args[i] = a.String()
+				}
+				err := next(call)
+				log = append(log, call.Name+" "+strings.Join(args, " "))
+				return err
+			}
+		})
+
+		var x int
+		err := ir.EvalAs(&x, "add 40 2")
+		if err != nil {
+			t.Error(err)
+		} else if x != 42 {
+			t.Errorf("%d != 42", x)
+		}
+
+		locked = true
+		err = ir.Eval("add 1 2")
+		must_contain(t, err, "locked")
+
+		if gold := "add 40 2,add 1 2"; strings.Join(log, ",") != gold {
+			t.Errorf("%s != %s", gold, strings.Join(log, ","))
+		}
+	})
+}
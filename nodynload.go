@@ -0,0 +1,14 @@
+//go:build !gothic_dlopen
+
+package gothic
+
+// TCL and Tk are linked at build time, see dynload.go for the gothic_dlopen
+// build tag.
+
+func load_tcl() error {
+	return nil
+}
+
+func load_tk() error {
+	return nil
+}
@@ -0,0 +1,116 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// A handle to a TCL object, mostly used by custom type converters (see
+// RegisterConverter). TCL objects belong to the interpreter thread, Obj
+// values must not be created or used outside of it. The objects a callback
+// receives are only valid until it returns, unless they are retained (see
+// Retain).
+type Obj struct {
+	p *C.Tcl_Obj
+}
+
+// Creates a new TCL string object.
+func NewStringObj(s string) Obj {
+	return Obj{new_tcl_string_obj(s)}
+}
+
+// Creates a new TCL integer object.
+func NewIntObj(i int64) Obj {
+	return Obj{C.Tcl_NewWideIntObj(C.Tcl_WideInt(i))}
+}
+
+// Creates a new TCL double object.
+func NewDoubleObj(f float64) Obj {
+	return Obj{C.Tcl_NewDoubleObj(C.double(f))}
+}
+
+// Creates a new TCL boolean object.
+func NewBoolObj(b bool) Obj {
+	if b {
+		return Obj{C.Tcl_NewBooleanObj(1)}
+	}
+	return Obj{C.Tcl_NewBooleanObj(0)}
+}
+
+// Creates a new TCL list object containing `elems`.
+func NewListObj(elems ...Obj) Obj {
+	list := C.Tcl_NewListObj(0, nil)
+	for _, e := range elems {
+		C.Tcl_ListObjAppendElement(nil, list, e.p)
+	}
+	return Obj{list}
+}
+
+// Returns the string representation of the object.
+func (o Obj) String() string {
+	return tcl_obj_to_go_string(o.p)
+}
+
+// Returns the integer value of the object.
+func (o Obj) Int() (int64, error) {
+	var out C.Tcl_WideInt
+	if C.Tcl_GetWideIntFromObj(nil, o.p, &out) != C.TCL_OK {
+		return 0, fmt.Errorf("gothic: expected integer but got %q", o.String())
+	}
+	return int64(out), nil
+}
+
+// Returns the floating point value of the object.
+func (o Obj) Float() (float64, error) {
+	var out C.double
+	if C.Tcl_GetDoubleFromObj(nil, o.p, &out) != C.TCL_OK {
+		return 0, fmt.Errorf("gothic: expected floating-point number but got %q", o.String())
+	}
+	return float64(out), nil
+}
+
+// Returns the boolean value of the object.
+func (o Obj) Bool() (bool, error) {
+	var out C.int
+	if C.Tcl_GetBooleanFromObj(nil, o.p, &out) != C.TCL_OK {
+		return false, fmt.Errorf("gothic: expected boolean but got %q", o.String())
+	}
+	return out != 0, nil
+}
+
+// Returns the elements of the object treated as a list.
+func (o Obj) List() ([]Obj, error) {
+	var objc C.int
+	var objv **C.Tcl_Obj
+	if C.Tcl_ListObjGetElements(nil, o.p, &objc, &objv) != C.TCL_OK {
+		return nil, fmt.Errorf("gothic: expected list but got %q", o.String())
+	}
+	// objv is NULL for an empty list
+	elems := unsafe.Slice(objv, int(objc))
+	out := make([]Obj, len(elems))
+	for i, e := range elems {
+		out[i] = Obj{e}
+	}
+	return out, nil
+}
+
+// Keeps the object alive after the callback which received it returns, e.g.
+// for a goroutine started by the callback, until it's released with
+// Interpreter.ReleaseObj. Must be called on the interpreter thread.
+func (o Obj) Retain() Obj {
+	C._gotk_c_incr_ref_count(o.p)
+	return o
+}
+
+// Releases an object retained with Obj.Retain, it's freed if nothing else
+// refers to it. Can be called from any goroutine.
+func (ir *Interpreter) ReleaseObj(o Obj) error {
+	return ir.ir.run(func() error {
+		C._gotk_c_decr_ref_count(o.p)
+		return nil
+	})
+}
@@ -0,0 +1,79 @@
+package gothic
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"image/color"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Helpers for turning Go values into Tk-style "-option value" lists.
+
+// writes a value as a single quoted TCL word
+func quote_value(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		quote(buf, v)
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		if err != nil {
+			quote(buf, fmt.Sprint(value))
+			return
+		}
+		quote(buf, string(text))
+	case color.Color:
+		quote(buf, FormatColor(v))
+	default:
+		quote(buf, fmt.Sprint(value))
+	}
+}
+
+// writes " -name value", a leading "-" is added to the name if necessary
+func write_option(buf *bytes.Buffer, name string, value interface{}) {
+	buf.WriteString(" ")
+	if !strings.HasPrefix(name, "-") {
+		buf.WriteString("-")
+	}
+	buf.WriteString(name)
+	buf.WriteString(" ")
+	quote_value(buf, value)
+}
+
+// writes options from a map, sorted by name for determinism
+func write_map_options(buf *bytes.Buffer, opts map[string]interface{}) {
+	names := make([]string, 0, len(opts))
+	for name := range opts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		write_option(buf, name, opts[name])
+	}
+}
+
+// writes options from the exported fields of a struct tagged with
+// `tcl:"name"`, fields having zero values are skipped, map[string]interface{}
+// fields are treated as additional options
+func write_struct_options(buf *bytes.Buffer, opts interface{}) {
+	v := reflect.Indirect(reflect.ValueOf(opts))
+	t := v.Type()
+	for i, n := 0, t.NumField(); i < n; i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+		if f.PkgPath != "" || fv.IsZero() {
+			continue
+		}
+		if m, ok := fv.Interface().(map[string]interface{}); ok {
+			write_map_options(buf, m)
+			continue
+		}
+		name := f.Tag.Get("tcl")
+		if name == "" || name == "-" {
+			continue
+		}
+		write_option(buf, name, fv.Interface())
+	}
+}
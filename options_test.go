@@ -0,0 +1,19 @@
+package gothic
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStructOptions(t *testing.T) {
+	var buf bytes.Buffer
+	write_struct_options(&buf, StyleOpts{
+		Background: "red",
+		Font:       "Helvetica 12",
+		Extra:      map[string]interface{}{"width": 10, "-text": "[x]"},
+	})
+	gold := ` -background "red" -font "Helvetica 12" -text "\[x\]" -width "10"`
+	if s := buf.String(); s != gold {
+		t.Errorf("%s != %s", gold, s)
+	}
+}
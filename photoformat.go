@@ -0,0 +1,188 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// A photo image format implemented in Go, see RegisterPhotoFormat. The
+// function signatures match the ones of Go image codecs, e.g.
+//
+//	gothic.RegisterPhotoFormat(gothic.PhotoFormat{
+//		Name:         "webp",
+//		DecodeConfig: webp.DecodeConfig,
+//		Decode:       webp.Decode,
+//	})
+type PhotoFormat struct {
+	// The name used with -format, must be lowercase (Tk treats formats
+	// with a capitalized name as the ones using the old C API).
+	Name string
+
+	// Reports the size of the image, an error means the data is not in
+	// this format. Used to detect the format when -format is not given.
+	DecodeConfig func(r io.Reader) (image.Config, error)
+
+	Decode func(r io.Reader) (image.Image, error)
+
+	// Can be nil if the format is read-only, otherwise it's used by the
+	// `write` and `data` photo subcommands.
+	Encode func(w io.Writer, img image.Image) error
+}
+
+var photo_formats struct {
+	sync.RWMutex
+	list []*PhotoFormat
+}
+
+// Registers a photo image format implemented in Go, so that `image create
+// photo -file foo.webp`, `-data` and `$img read` decode images of this
+// format and (if Encode is set) `$img write` and `$img data` encode them. The
+// data given with -data can be binary or base64-encoded, `$img data` returns
+// base64. The formats are installed in interpreters when Tk is initialized,
+// so it should be called before the interpreters are created, e.g. in an
+// init function. Up to 16 formats can be registered.
+func RegisterPhotoFormat(f PhotoFormat) error {
+	if f.Name == "" || f.Name != strings.ToLower(f.Name) {
+		return errors.New("gothic: photo format name must be lowercase")
+	}
+	if f.DecodeConfig == nil || f.Decode == nil {
+		return errors.New("gothic: photo format needs DecodeConfig and Decode")
+	}
+
+	photo_formats.Lock()
+	defer photo_formats.Unlock()
+	slot := len(photo_formats.list)
+	if slot >= C.GOTHIC_PHOTO_FORMATS {
+		return errors.New("gothic: too many photo formats")
+	}
+	canwrite := 0
+	if f.Encode != nil {
+		canwrite = 1
+	}
+	// the name is never freed, Tk may keep it
+	C._gotk_c_photo_format_init(C.int(slot), C.CString(f.Name), C.int(canwrite))
+	photo_formats.list = append(photo_formats.list, &f)
+	return nil
+}
+
+// Installs the registered formats, must be called on the interpreter thread
+// after Tk is initialized.
+func init_photo_formats() {
+	photo_formats.RLock()
+	defer photo_formats.RUnlock()
+	for slot := range photo_formats.list {
+		C._gotk_c_photo_format_register(C.int(slot))
+	}
+}
+
+func photo_format(slot C.int) *PhotoFormat {
+	photo_formats.RLock()
+	defer photo_formats.RUnlock()
+	return photo_formats.list[slot]
+}
+
+// Returns the image data in `obj`, the data given with -data is decoded if
+// it's base64.
+func photo_data(obj *C.Tcl_Obj, isstring C.int) []byte {
+	var n C.int
+	p := C.Tcl_GetByteArrayFromObj(obj, &n)
+	data := C.GoBytes(unsafe.Pointer(p), n)
+	if isstring != 0 {
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+		if err == nil {
+			return decoded
+		}
+	}
+	return data
+}
+
+func photo_error(interp *C.Tcl_Interp, err error) C.int {
+	C.Tcl_SetObjResult(interp, new_tcl_string_obj(err.Error()))
+	return C.TCL_ERROR
+}
+
+//export _gotk_go_photo_match
+func _gotk_go_photo_match(slot C.int, interp *C.Tcl_Interp, obj *C.Tcl_Obj, isstring C.int, w, h *C.int) C.int {
+	f := photo_format(slot)
+	cfg, err := f.DecodeConfig(bytes.NewReader(photo_data(obj, isstring)))
+	if err != nil {
+		return 0
+	}
+	*w, *h = C.int(cfg.Width), C.int(cfg.Height)
+	return 1
+}
+
+//export _gotk_go_photo_read
+func _gotk_go_photo_read(slot C.int, interp *C.Tcl_Interp, obj *C.Tcl_Obj, isstring C.int,
+	handle C.Tk_PhotoHandle, destx, desty, width, height, srcx, srcy C.int) C.int {
+	f := photo_format(slot)
+	img, err := f.Decode(bytes.NewReader(photo_data(obj, isstring)))
+	if err != nil {
+		return photo_error(interp, err)
+	}
+	src := img.Bounds().Min.Add(image.Pt(int(srcx), int(srcy)))
+	return photo_put_image(interp, handle, img, src, int(destx), int(desty), int(width), int(height))
+}
+
+// Puts the `width` x `height` region of `img` starting at `src` into the
+// photo at (destx, desty).
+func photo_put_image(interp *C.Tcl_Interp, handle C.Tk_PhotoHandle, img image.Image, src image.Point,
+	destx, desty, width, height int) C.int {
+	if width <= 0 || height <= 0 {
+		return C.TCL_OK
+	}
+
+	// the pixels are passed in C memory, the block can't point to Go memory
+	pix := C.malloc(C.size_t(width * height * 4))
+	defer C.free(pix)
+	region := &image.NRGBA{
+		Pix:    unsafe.Slice((*byte)(pix), width*height*4),
+		Stride: width * 4,
+		Rect:   image.Rect(0, 0, width, height),
+	}
+	draw.Draw(region, region.Rect, img, src, draw.Src)
+
+	block := C.Tk_PhotoImageBlock{
+		(*C.uchar)(pix),
+		C.int(width),
+		C.int(height),
+		C.int(region.Stride),
+		4,
+		[...]C.int{0, 1, 2, 3},
+	}
+	return C.Tk_PhotoPutBlock(interp, handle, &block, C.int(destx), C.int(desty),
+		C.int(width), C.int(height), C.TK_PHOTO_COMPOSITE_SET)
+}
+
+//export _gotk_go_photo_write
+func _gotk_go_photo_write(slot C.int, interp *C.Tcl_Interp, filename *C.char, block *C.Tk_PhotoImageBlock) C.int {
+	f := photo_format(slot)
+	var buf bytes.Buffer
+	err := f.Encode(&buf, photo_block_to_image(block))
+	if err != nil {
+		return photo_error(interp, err)
+	}
+
+	if filename != nil {
+		err = os.WriteFile(C.GoString(filename), buf.Bytes(), 0666)
+		if err != nil {
+			return photo_error(interp, err)
+		}
+		return C.TCL_OK
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	C.Tcl_SetObjResult(interp, new_tcl_string_obj(data))
+	return C.TCL_OK
+}
@@ -0,0 +1,113 @@
+package gothic
+
+import (
+	"errors"
+	"sync"
+)
+
+// Returned by Pool methods after Pool.Close.
+var ErrPoolClosed = errors.New("gothic: pool is closed")
+
+// A pool of Tk-less interpreters (see NewTclInterpreter), each running on its
+// own OS thread, for applications using TCL as an embedded scripting engine
+// (rule evaluation, templating) which need parallelism. Work is dispatched to
+// an idle interpreter, interpreters don't share any state.
+type Pool struct {
+	interps []*Interpreter
+	idle    chan *Interpreter
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// Creates a pool of `n` interpreters, `init` (can be nil) is run on each of
+// them in its own thread before it's used, e.g. to define procedures. It has
+// the same meaning as in NewTclInterpreter. If an interpreter can't be
+// created or its `init` fails, the others are stopped and the error is
+// returned.
+func NewPool(n int, init interface{}) (*Pool, error) {
+	if n <= 0 {
+		n = 1
+	}
+	p := &Pool{
+		interps: make([]*Interpreter, n),
+		idle:    make(chan *Interpreter, n),
+	}
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range p.interps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p.interps[i], errs[i] = NewWithOptions(init, Options{NoTk: true})
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		for _, ir := range p.interps {
+			if ir != nil {
+				ir.Quit()
+				<-ir.Done
+			}
+		}
+		return nil, err
+	}
+	for _, ir := range p.interps {
+		p.idle <- ir
+	}
+	return p, nil
+}
+
+// Returns the number of interpreters in the pool.
+func (p *Pool) Size() int {
+	return len(p.interps)
+}
+
+// Waits for an idle interpreter and runs `f` on its thread, `f` has exclusive
+// access to the interpreter until it returns.
+func (p *Pool) Do(f func(*Interpreter) error) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	ir := <-p.idle
+	defer func() { p.idle <- ir }()
+	return ir.ir.run(func() error {
+		return f(ir)
+	})
+}
+
+// Evaluates the script on an idle interpreter, see Interpreter.Eval.
+func (p *Pool) Eval(format string, args ...interface{}) error {
+	return p.Do(func(ir *Interpreter) error {
+		return ir.Eval(format, args...)
+	})
+}
+
+// Evaluates the script on an idle interpreter and stores the result in
+// `out`, see Interpreter.EvalAs.
+func (p *Pool) EvalAs(out interface{}, format string, args ...interface{}) error {
+	return p.Do(func(ir *Interpreter) error {
+		return ir.EvalAs(out, format, args...)
+	})
+}
+
+// Waits for the work in progress to finish and stops the interpreters (see
+// Interpreter.Quit), further calls fail with ErrPoolClosed.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, ir := range p.interps {
+		ir.Quit()
+		<-ir.Done
+	}
+}
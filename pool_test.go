@@ -0,0 +1,48 @@
+package gothic
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	p, err := NewPool(3, "proc square {x} {expr {$x * $x}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	interps := append([]*Interpreter(nil), p.interps...)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var x int
+			err := p.EvalAs(&x, "square %{}", i)
+			if err != nil {
+				t.Error(err)
+			} else if x != i*i {
+				t.Errorf("%d != %d", x, i*i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	p.Close()
+	for i, ir := range interps {
+		select {
+		case <-ir.Done:
+		default:
+			t.Errorf("interpreter %d is still running after Close", i)
+		}
+	}
+	if err := p.Eval("set x 1"); err != ErrPoolClosed {
+		t.Errorf("expected ErrPoolClosed, got %v", err)
+	}
+	p.Close()
+
+	_, err = NewPool(2, func(ir *Interpreter) error {
+		return ir.Eval("error {init failed}")
+	})
+	must_contain(t, err, "init failed")
+}
@@ -0,0 +1,171 @@
+package gothic
+
+import (
+	"sync"
+	"time"
+)
+
+// The minimum interval between the updates of the progress widgets.
+const progress_interval = 50 * time.Millisecond
+
+// Reports the progress of a background job: the worker goroutine updates it
+// as often as it likes, the widgets (a ttk::progressbar and a label) are
+// updated on the interpreter thread at most every 50ms. The user can cancel
+// the job, the worker learns it from Canceled. See Interpreter.NewProgress
+// and Interpreter.NewProgressDialog.
+type Progress struct {
+	ir      *Interpreter
+	bar     string
+	label   string
+	dialog  *Toplevel // nil unless created by NewProgressDialog
+	cancel  string    // the command of the dialog's cancel button
+	updates *Coalescer
+
+	mu       sync.Mutex
+	fraction float64
+	message  string
+
+	canceled chan struct{}
+	once     sync.Once
+
+	// the bar is in the indeterminate mode, interpreter thread only
+	indeterminate bool
+}
+
+// Returns a Progress driving the existing ttk::progressbar `bar` and the
+// label `label` (showing the message, can be empty). Its Cancel method can
+// be bound to a button of the application.
+func (ir *Interpreter) NewProgress(bar, label string) *Progress {
+	return &Progress{
+		ir:       ir,
+		bar:      bar,
+		label:    label,
+		updates:  ir.NewCoalescer(progress_interval),
+		canceled: make(chan struct{}),
+	}
+}
+
+// Shows a dialog with a message, a progress bar and a Cancel button, the
+// button and closing the window cancel the job. Done destroys the dialog.
+func (ir *Interpreter) NewProgressDialog(title string) (*Progress, error) {
+	var p *Progress
+	err := ir.ir.run(func() error {
+		var err error
+		p, err = ir.ir.new_progress_dialog(ir, title)
+		return ir.ir.filt(err)
+	})
+	return p, err
+}
+
+func (ir *interpreter) new_progress_dialog(iir *Interpreter, title string) (*Progress, error) {
+	var p *Progress
+	t, err := ir.new_toplevel(iir, &ToplevelOpts{
+		Name:    "progress",
+		Title:   title,
+		OnClose: func() { p.Cancel() },
+	})
+	if err != nil {
+		return nil, err
+	}
+	w := t.Path()
+	p = iir.NewProgress(w+".f.bar", w+".f.msg")
+	p.dialog = t
+	p.cancel = ir.next_command("progress_cancel")
+	err = ir.register_command(p.cancel, p.Cancel)
+	if err != nil {
+		iir.Eval("destroy %{%q}", w)
+		return nil, err
+	}
+	err = iir.Eval(`
+		wm resizable %{0%q} 0 0
+		ttk::frame %{0}.f -padding 12
+		ttk::label %{0}.f.msg -width 40
+		ttk::progressbar %{0}.f.bar -length 300 -maximum 1
+		ttk::button %{0}.f.cancel -text Cancel -command %{1%q}
+		grid %{0}.f.msg -sticky w -pady {0 6}
+		grid %{0}.f.bar -sticky ew
+		grid %{0}.f.cancel -sticky e -pady {12 0}
+		grid %{0}.f -sticky nsew
+	`, w, p.cancel)
+	if err != nil {
+		iir.Eval("destroy %{%q}", w)
+		ir.unregister_command(p.cancel)
+		return nil, err
+	}
+	return p, nil
+}
+
+// Sets the completed fraction of the job, from 0 to 1. A negative fraction
+// switches the bar to the indeterminate mode (an animated bar, for jobs of
+// unknown length). Can be called from any goroutine.
+func (p *Progress) SetFraction(f float64) {
+	if f > 1 {
+		f = 1
+	}
+	p.mu.Lock()
+	p.fraction = f
+	p.mu.Unlock()
+	p.updates.Set(p.update)
+}
+
+// Sets the message shown next to the bar. Can be called from any goroutine.
+func (p *Progress) SetMessage(msg string) {
+	p.mu.Lock()
+	p.message = msg
+	p.mu.Unlock()
+	p.updates.Set(p.update)
+}
+
+// Returns a channel closed when the job is canceled.
+func (p *Progress) Canceled() <-chan struct{} {
+	return p.canceled
+}
+
+// Cancels the job: closes the Canceled channel and disables the Cancel
+// button of the dialog. The job keeps running until the worker notices it.
+func (p *Progress) Cancel() {
+	p.once.Do(func() {
+		close(p.canceled)
+		if p.dialog != nil {
+			p.ir.Eval("%{}.f.cancel state disabled", p.dialog.Path())
+		}
+	})
+}
+
+// Shows the last state of the job and destroys the dialog created by
+// NewProgressDialog. Must be called by the worker when the job is over,
+// including when it was canceled.
+func (p *Progress) Done() error {
+	p.updates.Cancel()
+	return p.ir.ir.run(func() error {
+		p.update(p.ir)
+		if p.dialog == nil {
+			return nil
+		}
+		p.ir.ir.unregister_command(p.cancel)
+		return p.dialog.Close()
+	})
+}
+
+// updates the widgets, on the interpreter thread
+func (p *Progress) update(ir *Interpreter) {
+	p.mu.Lock()
+	f, msg := p.fraction, p.message
+	p.mu.Unlock()
+
+	if f < 0 {
+		if !p.indeterminate {
+			ir.Eval("%{0%q} configure -mode indeterminate; %{0%q} start", p.bar)
+			p.indeterminate = true
+		}
+	} else {
+		if p.indeterminate {
+			ir.Eval("%{0%q} stop; %{0%q} configure -mode determinate", p.bar)
+			p.indeterminate = false
+		}
+		ir.Eval("%{%q} configure -maximum 1 -value %{}", p.bar, f)
+	}
+	if p.label != "" {
+		ir.Eval("%{%q} configure -text %{%q}", p.label, msg)
+	}
+}
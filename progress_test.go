@@ -0,0 +1,47 @@
+package gothic
+
+import (
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	ir, err := NewWithOptions(func(ir *Interpreter) error {
+		// fake widgets recording their configuration
+		return ir.Eval(`
+			set log {}
+			proc .bar {args} {lappend ::log [list .bar {*}$args]}
+			proc .msg {args} {lappend ::log [list .msg {*}$args]}
+		`)
+	}, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Quit()
+
+	p := ir.NewProgress(".bar", ".msg")
+	p.SetFraction(0.25)
+	p.SetMessage("working")
+	p.SetFraction(0.5)
+	p.SetMessage("half")
+	err = p.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last string
+	err = ir.EvalAs(&last, "join [lrange $log end-1 end] \\n")
+	if err != nil {
+		t.Error(err)
+	} else if gold := ".bar configure -maximum 1 -value 0.5\n.msg configure -text half"; last != gold {
+		t.Errorf("%s != %s", gold, last)
+	}
+
+	select {
+	case <-p.Canceled():
+		t.Error("canceled before Cancel")
+	default:
+	}
+	p.Cancel()
+	p.Cancel()
+	<-p.Canceled()
+}
@@ -0,0 +1,293 @@
+package gothic
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+//------------------------------------------------------------------------------
+// async_queue
+//
+// A bounded multi-producer single-consumer queue of the actions sent to the
+// interpreter thread. Producers push with a single atomic swap, the consumer
+// (the interpreter thread) pops without locking. The nodes are intrusive, an
+// action is its own node and also the completion token the producer waits on.
+// Producers only take a lock when the queue is full.
+//------------------------------------------------------------------------------
+
+// Returned (wrapped in a *WaitTimeoutError) by the methods called from other
+// goroutines when the interpreter thread doesn't get to the action within the
+// wait timeout, see Options.WaitTimeout.
+var ErrWaitTimeout = errors.New("gothic: timed out waiting for the interpreter thread")
+
+// The diagnostics of a wait timeout. The action is dropped from the queue,
+// it never runs.
+type WaitTimeoutError struct {
+	Timeout       time.Duration
+	QueueDepth    int // actions waiting in the queue at the timeout
+	QueueCapacity int
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return fmt.Sprintf("gothic: the interpreter thread didn't run the action within %s (queue %d/%d)",
+		e.Timeout, e.QueueDepth, e.QueueCapacity)
+}
+
+func (e *WaitTimeoutError) Unwrap() error {
+	return ErrWaitTimeout
+}
+
+// the states of an async_action
+const (
+	action_queued int32 = iota
+	action_running
+	action_canceled // by a wait timeout
+)
+
+type async_action struct {
+	next   unsafe.Pointer // *async_action, accessed atomically
+	action func() error
+	err    error
+	done   chan struct{} // signaled by the interpreter thread, capacity 1
+	queued time.Time
+	state  int32 // accessed atomically
+}
+
+// actions are recycled, a call from a foreign thread allocates nothing for
+// the queue itself once the pool is warm
+var async_action_pool = sync.Pool{
+	New: func() interface{} {
+		return &async_action{done: make(chan struct{}, 1)}
+	},
+}
+
+func new_async_action(action func() error) *async_action {
+	a := async_action_pool.Get().(*async_action)
+	a.action = action
+	a.queued = time.Now()
+	a.state = action_queued
+	return a
+}
+
+// Called by the consumer before running the action, returns false if the
+// producer has given up waiting for it.
+func (a *async_action) start() bool {
+	return atomic.CompareAndSwapInt32(&a.state, action_queued, action_running)
+}
+
+func (a *async_action) free() {
+	a.action = nil
+	a.err = nil
+	async_action_pool.Put(a)
+}
+
+type async_queue struct {
+	head unsafe.Pointer // *async_action, the last pushed node, producers only
+	tail *async_action  // the next node to pop, consumer only
+	stub async_action   // keeps the list non-empty
+
+	length   int32 // reserved slots, accessed atomically
+	capacity int32
+
+	// the interpreter thread was notified and hasn't drained the queue yet,
+	// accessed atomically
+	scheduled int32
+
+	// producers waiting for a free slot
+	waiters int32 // accessed atomically
+	mu      sync.Mutex
+	free    *sync.Cond
+
+	// the interpreter is closed, the pending actions are never run, see
+	// close
+	closed     int32 // accessed atomically
+	closedchan chan struct{}
+}
+
+func (q *async_queue) init(capacity int) {
+	q.head = unsafe.Pointer(&q.stub)
+	q.tail = &q.stub
+	q.capacity = int32(capacity)
+	q.free = sync.NewCond(&q.mu)
+	q.closedchan = make(chan struct{})
+}
+
+// Returns the number of actions in the queue (including the ones being
+// pushed) and its capacity.
+func (q *async_queue) len() int {
+	return int(atomic.LoadInt32(&q.length))
+}
+
+func (q *async_queue) cap() int {
+	return int(q.capacity)
+}
+
+// Reserves a slot for the next push. If the queue is full, it waits for a
+// slot when `block` is true, returns ErrBusy otherwise. Returns
+// ErrInterpreterClosed once the queue is closed.
+func (q *async_queue) reserve(block bool) error {
+	for {
+		if q.is_closed() {
+			return ErrInterpreterClosed
+		}
+		n := atomic.LoadInt32(&q.length)
+		if n < q.capacity {
+			if atomic.CompareAndSwapInt32(&q.length, n, n+1) {
+				return nil
+			}
+			continue
+		}
+		if !block {
+			return ErrBusy
+		}
+		q.mu.Lock()
+		// registered before the check, the consumer sees it after freeing a
+		// slot or we see the freed slot
+		atomic.AddInt32(&q.waiters, 1)
+		if atomic.LoadInt32(&q.length) >= q.capacity && !q.is_closed() {
+			q.free.Wait()
+		}
+		atomic.AddInt32(&q.waiters, -1)
+		q.mu.Unlock()
+	}
+}
+
+// Closes the queue: new reservations fail, the producers waiting for a slot
+// or for the completion of an action are released. Called on the
+// interpreter thread, the consumer completes the remaining actions with
+// ErrInterpreterClosed (see cancel) instead of running them.
+func (q *async_queue) close() {
+	if !atomic.CompareAndSwapInt32(&q.closed, 0, 1) {
+		return
+	}
+	q.mu.Lock()
+	q.free.Broadcast()
+	q.mu.Unlock()
+}
+
+func (q *async_queue) is_closed() bool {
+	return atomic.LoadInt32(&q.closed) != 0
+}
+
+// Completes the actions left in the closed queue with ErrInterpreterClosed
+// and releases the producers still pushing or waiting for a completion. Must
+// be called by the consumer, the last time it touches the queue.
+func (q *async_queue) cancel() {
+	for a := q.pop(); a != nil; a = q.pop() {
+		a.err = ErrInterpreterClosed
+		a.done <- struct{}{}
+	}
+	close(q.closedchan)
+}
+
+// Waits for the completion of `a` pushed to the queue, returns its result.
+// The action is recycled unless the queue was canceled with the action in
+// it, the action is left to the garbage collector then.
+//
+// If `timeout` is positive and the action is still in the queue after it,
+// the action is canceled and a *WaitTimeoutError is returned, the consumer
+// frees it when popping. Once the action has started, it's waited for.
+func (q *async_queue) wait(a *async_action, timeout time.Duration) error {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case <-a.done:
+		case <-q.closedchan:
+			select {
+			case <-a.done:
+			default:
+				return ErrInterpreterClosed
+			}
+		case <-expired:
+			if !atomic.CompareAndSwapInt32(&a.state, action_queued, action_canceled) {
+				expired = nil
+				continue
+			}
+			return &WaitTimeoutError{
+				Timeout:       timeout,
+				QueueDepth:    q.len(),
+				QueueCapacity: q.cap(),
+			}
+		}
+		break
+	}
+	err := a.err
+	a.free()
+	return err
+}
+
+// Appends `a` to the queue, a slot must be reserved first.
+func (q *async_queue) push(a *async_action) {
+	atomic.StorePointer(&a.next, nil)
+	prev := (*async_action)(atomic.SwapPointer(&q.head, unsafe.Pointer(a)))
+	// between the swap and the store the node is unreachable from the tail,
+	// pop treats the queue as empty until then
+	atomic.StorePointer(&prev.next, unsafe.Pointer(a))
+}
+
+// Removes the first action from the queue and frees its slot, returns nil if
+// the queue is empty. Must be called by the consumer only.
+func (q *async_queue) pop() *async_action {
+	a := q.pop_node()
+	if a == nil {
+		return nil
+	}
+	atomic.AddInt32(&q.length, -1)
+	if atomic.LoadInt32(&q.waiters) > 0 {
+		q.mu.Lock()
+		q.free.Signal()
+		q.mu.Unlock()
+	}
+	return a
+}
+
+func (q *async_queue) pop_node() *async_action {
+	tail := q.tail
+	next := (*async_action)(atomic.LoadPointer(&tail.next))
+	if tail == &q.stub {
+		if next == nil {
+			return nil
+		}
+		q.tail = next
+		tail = next
+		next = (*async_action)(atomic.LoadPointer(&next.next))
+	}
+	if next != nil {
+		q.tail = next
+		return tail
+	}
+	if tail != (*async_action)(atomic.LoadPointer(&q.head)) {
+		// a producer is in the middle of a push after the tail
+		return nil
+	}
+	// the tail is the last node, put the stub after it to pop it
+	q.push(&q.stub)
+	next = (*async_action)(atomic.LoadPointer(&tail.next))
+	if next != nil {
+		q.tail = next
+		return tail
+	}
+	return nil
+}
+
+// Marks the queue as scheduled for draining, returns false if it already
+// was. Producers call it after a push, the one that succeeds notifies the
+// interpreter thread.
+func (q *async_queue) schedule() bool {
+	return atomic.CompareAndSwapInt32(&q.scheduled, 0, 1)
+}
+
+// Called by the consumer before draining the queue, the pushes that complete
+// after that schedule it again.
+func (q *async_queue) unschedule() {
+	atomic.StoreInt32(&q.scheduled, 0)
+}
@@ -0,0 +1,168 @@
+package gothic
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncQueue(t *testing.T) {
+	const producers = 8
+	const actions = 2000
+
+	var q async_queue
+	q.init(16)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < actions; i++ {
+				q.reserve(true)
+				q.push(&async_action{err: queue_item{p, i}})
+			}
+		}(p)
+	}
+
+	// actions of each producer come out in order
+	next := make([]int, producers)
+	for n := 0; n < producers*actions; {
+		a := q.pop()
+		if a == nil {
+			// empty or a push is in progress
+			runtime.Gosched()
+			continue
+		}
+		it := a.err.(queue_item)
+		if it.i != next[it.p] {
+			t.Fatalf("producer %d: got action %d, expected %d", it.p, it.i, next[it.p])
+		}
+		next[it.p]++
+		n++
+	}
+	wg.Wait()
+	if q.pop() != nil || q.len() != 0 {
+		t.Error("queue is not empty")
+	}
+}
+
+func TestAsyncQueueFull(t *testing.T) {
+	var q async_queue
+	q.init(2)
+	for i := 0; i < 2; i++ {
+		if err := q.reserve(false); err != nil {
+			t.Fatal(err)
+		}
+		q.push(&async_action{})
+	}
+	if err := q.reserve(false); err != ErrBusy {
+		t.Fatalf("expected ErrBusy, got %v", err)
+	}
+
+	reserved := make(chan error)
+	go func() {
+		reserved <- q.reserve(true)
+	}()
+	if q.pop() == nil {
+		t.Fatal("failed to pop an action")
+	}
+	if err := <-reserved; err != nil {
+		t.Error(err)
+	}
+	if q.len() != 2 {
+		t.Errorf("expected 2 slots taken, got %d", q.len())
+	}
+}
+
+type queue_item struct {
+	p, i int
+}
+
+func (queue_item) Error() string { return "" }
+
+func TestAsyncQueueClose(t *testing.T) {
+	var q async_queue
+	q.init(1)
+	q.reserve(true)
+	a := new_async_action(nil)
+	q.push(a)
+
+	// waiting for a slot
+	reserved := make(chan error)
+	go func() {
+		reserved <- q.reserve(true)
+	}()
+	waited := make(chan error)
+	go func() {
+		waited <- q.wait(a, 0)
+	}()
+
+	q.close()
+	if err := <-reserved; err != ErrInterpreterClosed {
+		t.Errorf("expected ErrInterpreterClosed, got %v", err)
+	}
+	q.cancel()
+	if err := <-waited; err != ErrInterpreterClosed {
+		t.Errorf("expected ErrInterpreterClosed, got %v", err)
+	}
+	if err := q.reserve(false); err != ErrInterpreterClosed {
+		t.Errorf("expected ErrInterpreterClosed, got %v", err)
+	}
+}
+
+func TestAsyncActionAllocs(t *testing.T) {
+	var q async_queue
+	q.init(1)
+	action := func() error { return nil }
+	allocs := testing.AllocsPerRun(100, func() {
+		q.reserve(true)
+		a := new_async_action(action)
+		q.push(a)
+		q.pop().done <- struct{}{}
+		<-a.done
+		a.free()
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations per action, got %v", allocs)
+	}
+}
+
+func TestAsyncQueueTimeout(t *testing.T) {
+	var q async_queue
+	q.init(2)
+	q.reserve(true)
+	a := new_async_action(nil)
+	q.push(a)
+
+	err := q.wait(a, 10*time.Millisecond)
+	var te *WaitTimeoutError
+	if !errors.As(err, &te) || !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if te.QueueDepth != 1 || te.QueueCapacity != 2 {
+		t.Errorf("unexpected diagnostics %+v", te)
+	}
+	if p := q.pop(); p != a || p.start() {
+		t.Error("the timed out action wasn't canceled")
+	}
+
+	// started actions are waited for
+	q.reserve(true)
+	a = new_async_action(nil)
+	q.push(a)
+	if p := q.pop(); !p.start() {
+		t.Fatal("failed to start the action")
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		a.err = errors.New("done")
+		a.done <- struct{}{}
+	}()
+	err = q.wait(a, time.Millisecond)
+	if err == nil || err.Error() != "done" {
+		t.Errorf("expected the action result, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package gothic
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Changes arriving within this interval are re-sourced once, editors tend to
+// write files in several steps.
+const HotReloadDelay = 100 * time.Millisecond
+
+// ::source is wrapped to report sourced files to Go
+const hot_reload_script = `
+namespace eval ::gothic {
+	if {[info commands ::gothic::source_orig] eq ""} {
+		rename ::source ::gothic::source_orig
+		proc ::source {args} {
+			::gothic::hotreload_add [file normalize [lindex $args end]]
+			uplevel 1 [list ::gothic::source_orig {*}$args]
+		}
+	}
+}
+`
+
+const hot_reload_stop_script = `
+if {[info commands ::gothic::source_orig] ne ""} {
+	rename ::source {}
+	rename ::gothic::source_orig ::source
+}
+`
+
+type hot_reloader struct {
+	ir      *Interpreter
+	watcher *fsnotify.Watcher
+	rebuild func(file string, err error)
+
+	mu      sync.Mutex
+	stopped bool
+	files   map[string]bool
+	pending map[string]*time.Timer
+}
+
+// Enables the development mode: script files sourced after the call (and
+// the `files` given explicitly) are watched for changes and re-sourced on the
+// interpreter thread, then the `rebuild` callback (can be nil) is invoked on
+// the interpreter thread with the file name and the error of re-sourcing it,
+// e.g. to destroy and recreate the UI. Call `stop` to disable it.
+func (ir *Interpreter) HotReload(rebuild func(file string, err error), files ...string) (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	hr := &hot_reloader{
+		ir:      ir,
+		watcher: watcher,
+		rebuild: rebuild,
+		files:   make(map[string]bool),
+		pending: make(map[string]*time.Timer),
+	}
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err == nil {
+			err = hr.add(abs)
+		}
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	err = ir.ir.run(func() error {
+		// files which can't be watched are sourced as usual
+		err := ir.ir.register_command("::gothic::hotreload_add", func(file string) {
+			hr.add(file)
+		})
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		return ir.Eval(hot_reload_script)
+	})
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go hr.loop()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			hr.mu.Lock()
+			hr.stopped = true
+			hr.mu.Unlock()
+			ir.ir.run(func() error {
+				ir.Eval(hot_reload_stop_script)
+				ir.ir.unregister_command("::gothic::hotreload_add")
+				return nil
+			})
+			watcher.Close()
+		})
+	}, nil
+}
+
+// starts watching the file, directories are watched instead of files
+// themselves, because editors often replace files on save
+func (hr *hot_reloader) add(file string) error {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	if hr.files[file] {
+		return nil
+	}
+	err := hr.watcher.Add(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+	hr.files[file] = true
+	return nil
+}
+
+func (hr *hot_reloader) loop() {
+	for {
+		select {
+		case ev, ok := <-hr.watcher.Events:
+			if !ok {
+				return
+			}
+			if !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Create) {
+				continue
+			}
+			hr.schedule(ev.Name)
+		case _, ok := <-hr.watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+func (hr *hot_reloader) schedule(file string) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	if hr.stopped || !hr.files[file] {
+		return
+	}
+	if t, ok := hr.pending[file]; ok {
+		t.Reset(HotReloadDelay)
+		return
+	}
+	hr.pending[file] = time.AfterFunc(HotReloadDelay, func() {
+		hr.mu.Lock()
+		delete(hr.pending, file)
+		stopped := hr.stopped
+		hr.mu.Unlock()
+		if !stopped {
+			hr.reload(file)
+		}
+	})
+}
+
+func (hr *hot_reloader) reload(file string) {
+	hr.ir.ir.run(func() error {
+		err := hr.ir.Eval("uplevel #0 [list source %{%q}]", file)
+		if hr.rebuild != nil {
+			hr.rebuild(file, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,120 @@
+package gothic
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+// The TCL and Tk script libraries (init.tcl, tk.tcl, ttk and the rest) to use
+// instead of the installed ones. Setting it before the first interpreter is
+// created makes the executable independent of the TCL installation: only the
+// shared libraries (or static ones) are needed on the target machine. It must
+// contain the library directories as they are named in the installation,
+// e.g. "tcl8.6" with init.tcl and "tk8.6" with tk.tcl. The gothic-runtime
+// command copies them into a directory of the application, which can then
+// be embedded:
+//
+//	//go:generate go run github.com/nsf/gothic/cmd/gothic-runtime tcltk
+//
+//	//go:embed all:tcltk
+//	var tcltk embed.FS
+//
+//	func init() {
+//		gothic.RuntimeFS, _ = fs.Sub(tcltk, "tcltk")
+//	}
+//
+// TCL 8.6 has no ZipFS, so RuntimeFS is mounted with MountFS under
+// "/gothic:/runtime" instead, nothing is written to the disk.
+var RuntimeFS fs.FS
+
+// The mount point of RuntimeFS.
+const runtime_prefix = "/gothic:/runtime"
+
+var runtime_lib struct {
+	once sync.Once
+	tcl  string
+	tk   string
+	err  error
+}
+
+// Mounts RuntimeFS and returns the paths of the TCL and Tk libraries in it,
+// the Tk one is empty if RuntimeFS doesn't include it.
+func mount_runtime() (tcl, tk string, err error) {
+	runtime_lib.once.Do(func() {
+		tcl, err := find_library(RuntimeFS, "init.tcl")
+		if err != nil {
+			runtime_lib.err = err
+			return
+		}
+		if tcl == "" {
+			runtime_lib.err = errors.New("gothic: RuntimeFS has no TCL library (init.tcl)")
+			return
+		}
+		tk, err := find_library(RuntimeFS, "tk.tcl")
+		if err != nil {
+			runtime_lib.err = err
+			return
+		}
+
+		err = MountFS(runtime_prefix, RuntimeFS)
+		if err != nil {
+			runtime_lib.err = err
+			return
+		}
+		runtime_lib.tcl = path.Join(runtime_prefix, tcl)
+		if tk != "" {
+			runtime_lib.tk = path.Join(runtime_prefix, tk)
+		}
+	})
+	return runtime_lib.tcl, runtime_lib.tk, runtime_lib.err
+}
+
+// Returns the top-level directory of `fsys` containing `file`.
+func find_library(fsys fs.FS, file string) (string, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := fs.Stat(fsys, path.Join(e.Name(), file)); err == nil {
+			return e.Name(), nil
+		}
+	}
+	return "", nil
+}
+
+// Makes Tcl_Init and Tk_Init take their scripts from RuntimeFS, if it's set.
+// Must be called before Tcl_Init.
+func (ir *interpreter) init_runtime() error {
+	if RuntimeFS == nil {
+		return nil
+	}
+	tcl, tk, err := mount_runtime()
+	if err != nil {
+		return err
+	}
+	err = ir.set_var("::tcl_library", tcl, GlobalOnly)
+	if err != nil {
+		return err
+	}
+	if tk != "" {
+		err = ir.set_var("::tk_library", tk, GlobalOnly)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The encoding search path is computed from the compiled-in library location
+// when the process starts, point it to the mounted one as well
+const init_runtime_encodings = `
+if {[file isdirectory [file join $::tcl_library encoding]]} {
+	encoding dirs [linsert [encoding dirs] 0 [file join $::tcl_library encoding]]
+}
+`
@@ -0,0 +1,38 @@
+package gothic
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func TestRuntimeFS(t *testing.T) {
+	var lib string
+	NewTclInterpreter(func(ir *Interpreter) {
+		ir.EvalAs(&lib, "info library")
+	})
+	RuntimeFS = os.DirFS(filepath.Dir(lib))
+	defer func() { RuntimeFS = nil }()
+
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Quit()
+
+	var s string
+	err = ir.EvalAs(&s, "info library")
+	if err != nil {
+		t.Error(err)
+	} else if want := path.Join(runtime_prefix, filepath.Base(lib)); s != want {
+		t.Errorf("%s != %s", s, want)
+	}
+	// the scripts and the encodings of the library are read from RuntimeFS
+	err = ir.EvalAs(&s, "package require msgcat; encoding convertfrom cp1251 \xc0")
+	if err != nil {
+		t.Error(err)
+	} else if s != "А" {
+		t.Errorf("unexpected result %q", s)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build gothic_safestrings
+
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// With the gothic_safestrings build tag strings are copied every time they
+// cross the Go/C boundary: Go memory is never seen by C and Go strings never
+// point to C memory. Slower, but it doesn't depend on the cgo pointer rules
+// being followed by the callers.
+
+func cgo_string_to_go_string(p *C.char, n C.int) string {
+	return C.GoStringN(p, n)
+}
+
+func go_string_to_cgo_string(s string) (*C.char, C.int) {
+	if len(s) == 0 {
+		return nil, 0
+	}
+	return C.CString(s), C.int(len(s))
+}
+
+func free_cgo_string(p *C.char) {
+	C.free(unsafe.Pointer(p))
+}
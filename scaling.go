@@ -0,0 +1,49 @@
+package gothic
+
+// Returns the Tk scaling factor: the number of pixels per point (1/72 of an
+// inch), wrapping `tk scaling`.
+func (ir *Interpreter) Scaling() (float64, error) {
+	var out float64
+	err := ir.EvalAs(&out, "tk scaling")
+	return out, err
+}
+
+// Sets the Tk scaling factor: the number of pixels per point (1/72 of an
+// inch). Affects fonts and distances specified in points, millimeters or
+// inches created after the call.
+func (ir *Interpreter) SetScaling(factor float64) error {
+	return ir.Eval("tk scaling %{}", factor)
+}
+
+// Scales pixel sizes of the standard named fonts and sets the Tk scaling
+// factor according to the actual DPI of the screen. Returns the ratio of the
+// screen DPI to the conventional 96 DPI, use it to scale paddings and other
+// distances specified in pixels. The DPI and the font sizes are taken the
+// first time it's called, so calling it again doesn't scale fonts twice, but
+// it should be called before creating widgets.
+func (ir *Interpreter) AutoScale() (float64, error) {
+	var ratio float64
+	err := ir.EvalAs(&ratio, `apply {{} {
+		namespace eval ::gothic {}
+		if {![info exists ::gothic::screen_dpi]} {
+			# from the size of the screen, setting tk scaling changes
+			# what [winfo fpixels . 1i] returns
+			set ::gothic::screen_dpi [expr {
+				[winfo screenwidth .] * 25.4 / [winfo screenmmwidth .]}]
+			set ::gothic::font_sizes {}
+			foreach name [font names] {
+				dict set ::gothic::font_sizes $name [font configure $name -size]
+			}
+		}
+		set dpi $::gothic::screen_dpi
+		set ratio [expr {$dpi / 96.0}]
+		dict for {name size} $::gothic::font_sizes {
+			if {$size < 0} {
+				font configure $name -size [expr {round($size * $ratio)}]
+			}
+		}
+		tk scaling [expr {$dpi / 72.0}]
+		return $ratio
+	}}`)
+	return ratio, err
+}
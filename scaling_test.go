@@ -0,0 +1,62 @@
+package gothic
+
+import (
+	"testing"
+)
+
+func TestAutoScale(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		// a 192 DPI screen whose millimeters `tk scaling` changes, as Tk does
+		err := ir.Eval(`
+			set mm 254
+			set scaling 1.0
+			proc winfo {cmd args} {
+				switch -- $cmd {
+					screenwidth {return 1920}
+					screenmmwidth {return $::mm}
+				}
+			}
+			proc tk {cmd args} {
+				set ::scaling [lindex $args 0]
+				set ::mm [expr {round(1920 * 25.4 / 72.0 / $::scaling)}]
+			}
+			array set sizes {TkDefaultFont -12 TkFixedFont 9}
+			proc font {cmd args} {
+				switch -- $cmd {
+					names {return [array names ::sizes]}
+					configure {
+						lassign $args name option size
+						if {$size eq ""} {return $::sizes($name)}
+						set ::sizes($name) $size
+					}
+				}
+			}
+		`)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		for i := 0; i < 2; i++ {
+			ratio, err := ir.AutoScale()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ratio != 2 {
+				t.Errorf("ratio %v != 2", ratio)
+			}
+		}
+		var size, fixed int
+		var scaling float64
+		ir.EvalAs(&size, "set sizes(TkDefaultFont)")
+		ir.EvalAs(&fixed, "set sizes(TkFixedFont)")
+		ir.EvalAs(&scaling, "set scaling")
+		if size != -24 || fixed != 9 {
+			t.Errorf("unexpected font sizes %d, %d", size, fixed)
+		}
+		if scaling != 192.0/72.0 {
+			t.Errorf("scaling %v != %v", scaling, 192.0/72.0)
+		}
+	})
+}
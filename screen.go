@@ -0,0 +1,111 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+)
+
+// Information about a screen, see Interpreter.Screens.
+type ScreenInfo struct {
+	Name string `tcl:"name"` // e.g. ":0.0" on X11
+
+	// the size in pixels and millimeters
+	Width    int `tcl:"width"`
+	Height   int `tcl:"height"`
+	WidthMM  int `tcl:"mmwidth"`
+	HeightMM int `tcl:"mmheight"`
+
+	Depth  int    `tcl:"depth"`  // bits per pixel
+	Visual string `tcl:"visual"` // e.g. "truecolor"
+
+	// The virtual root window geometry, relative to the root window. The
+	// virtual root covers all the monitors of the screen on multi-monitor
+	// X11 setups, it's the same as the screen elsewhere.
+	VRootX      int `tcl:"vrootx"`
+	VRootY      int `tcl:"vrooty"`
+	VRootWidth  int `tcl:"vrootwidth"`
+	VRootHeight int `tcl:"vrootheight"`
+}
+
+// Returns the horizontal resolution of the screen in dots per inch.
+func (s *ScreenInfo) DPI() float64 {
+	if s.WidthMM == 0 {
+		return 0
+	}
+	return float64(s.Width) * 25.4 / float64(s.WidthMM)
+}
+
+// ::gothic::screen_windows
+//
+// Returns a window for each screen used by the application: "." and the
+// toplevels opened on other screens (`toplevel -screen`).
+const screen_script = `
+proc ::gothic::screen_windows {} {
+	set screens [dict create [winfo screen .] .]
+	foreach w [winfo children .] {
+		if {[winfo toplevel $w] eq $w && ![dict exists $screens [winfo screen $w]]} {
+			dict set screens [winfo screen $w] $w
+		}
+	}
+	dict values $screens
+}
+`
+
+const screen_info_script = `
+dict create name [winfo screen %{0%q}] \
+	width [winfo screenwidth %{0%q}] height [winfo screenheight %{0%q}] \
+	mmwidth [winfo screenmmwidth %{0%q}] mmheight [winfo screenmmheight %{0%q}] \
+	depth [winfo screendepth %{0%q}] visual [winfo screenvisual %{0%q}] \
+	vrootx [winfo vrootx %{0%q}] vrooty [winfo vrooty %{0%q}] \
+	vrootwidth [winfo vrootwidth %{0%q}] vrootheight [winfo vrootheight %{0%q}]
+`
+
+// Returns the screens the application windows are on, the screen of the
+// main window goes first. Tk has no notion of monitors: on X11 the monitors
+// of a display usually form a single screen, the virtual root geometry
+// reports the whole area.
+func (ir *Interpreter) Screens() ([]ScreenInfo, error) {
+	var screens []ScreenInfo
+	err := ir.ir.run(func() error {
+		var err error
+		screens, err = ir.ir.screens()
+		return ir.ir.filt(err)
+	})
+	return screens, err
+}
+
+func (ir *interpreter) screens() ([]ScreenInfo, error) {
+	err := ir.eval([]byte(screen_script))
+	if err != nil {
+		return nil, err
+	}
+	err = ir.eval([]byte("::gothic::screen_windows"))
+	if err != nil {
+		return nil, err
+	}
+	elems, err := Obj{C.Tcl_GetObjResult(ir.C)}.List()
+	if err != nil {
+		return nil, err
+	}
+	// the result is replaced by the next eval
+	windows := make([]string, len(elems))
+	for i, elem := range elems {
+		windows[i] = elem.String()
+	}
+	screens := make([]ScreenInfo, len(windows))
+	for i, w := range windows {
+		var buf bytes.Buffer
+		err := sprintf(&buf, screen_info_script, w)
+		if err != nil {
+			return nil, err
+		}
+		err = ir.eval_as(&screens[i], buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return screens, nil
+}
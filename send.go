@@ -0,0 +1,19 @@
+package gothic
+
+// Sets the name of the application used by Tk's send mechanism, wrapping
+// `tk appname`. If the name is already taken by another application, Tk
+// appends a suffix like " #2", the actual name is returned.
+func (ir *Interpreter) SetAppName(name string) (string, error) {
+	var out string
+	err := ir.EvalAs(&out, "tk appname %{%q}", name)
+	return out, err
+}
+
+// Evaluates the `script` in the application `app` (a gothic process or e.g.
+// a plain wish) on the same display and returns the result, wrapping `send`.
+// Use `winfo interps` to get the names of available applications.
+func (ir *Interpreter) Send(app, script string) (string, error) {
+	var out string
+	err := ir.EvalAs(&out, "send -- %{%q} %{%q}", app, script)
+	return out, err
+}
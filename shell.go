@@ -0,0 +1,194 @@
+package gothic
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"plugin"
+	"strings"
+)
+
+// The symbol looked up in Go plugins loaded by the shell. It must be a
+// function with the following signature: "func(*gothic.Interpreter) error".
+const PluginInitSymbol = "GothicInit"
+
+// A wish-like shell built on top of the *gothic.Interpreter. Apps can embed it
+// to get the same command-line behaviour as the `gothicsh` command, but with
+// their own Go commands registered in the `Init` function.
+//
+// Command-line syntax:
+//
+//	gothicsh ?-plugin path.so? ?-norc? ?fileName arg arg ...?
+//
+// If `fileName` is given, the shell sources it with `argv0`, `argv` and `argc`
+// set the way wish does and then waits until Tk's main loop exits. Otherwise
+// it sources the rc file (unless -norc is given) and starts reading commands
+// from `Stdin`, printing results to `Stdout` and errors to `Stderr`. When the
+// interpreter exits while a command is being read, the read is interrupted if
+// `Stdin` supports deadlines (see StartConsole). A terminal usually doesn't,
+// then the goroutine reading it stays blocked after Run returns, until the
+// next line or the end of the input.
+type Shell struct {
+	// Invoked on the interpreter thread before plugins are loaded, can be
+	// nil.
+	Init func(*Interpreter) error
+
+	// Defaults to "~/.gothicshrc".
+	RCFile string
+
+	// Default to os.Stdin, os.Stdout and os.Stderr.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Runs the default shell with the given command-line arguments, `args` should
+// not include the program name.
+func Run(args []string) error {
+	var sh Shell
+	return sh.Run(args)
+}
+
+type plugin_list []string
+
+func (p *plugin_list) String() string     { return strings.Join(*p, ",") }
+func (p *plugin_list) Set(v string) error { *p = append(*p, v); return nil }
+
+// Runs the shell with the given command-line arguments, `args` should not
+// include the program name.
+func (sh *Shell) Run(args []string) error {
+	stdin, stdout, stderr := sh.Stdin, sh.Stdout, sh.Stderr
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	var plugins plugin_list
+	fs := flag.NewFlagSet("gothicsh", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Var(&plugins, "plugin", "load a Go plugin (can be repeated)")
+	norc := fs.Bool("norc", false, "don't source the rc file")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	script := ""
+	argv0 := "gothicsh"
+	rest := fs.Args()
+	if len(rest) > 0 {
+		script = rest[0]
+		argv0 = script
+		rest = rest[1:]
+	}
+
+	var initerr error
+	ir, err := New(func(ir *Interpreter) {
+		initerr = sh.init(ir, plugins, argv0, rest, script == "")
+	})
+	if err != nil {
+		return err
+	}
+	if initerr != nil {
+		ir.Eval("destroy .")
+		return initerr
+	}
+
+	if script != "" {
+		err = ir.Eval("source %{%q}", script)
+		if err != nil {
+			ir.Eval("destroy .")
+			return err
+		}
+		<-ir.Done
+		return nil
+	}
+
+	if !*norc {
+		rc := sh.RCFile
+		if rc == "" {
+			home, err := os.UserHomeDir()
+			if err == nil {
+				rc = filepath.Join(home, ".gothicshrc")
+			}
+		}
+		if rc != "" {
+			if _, err := os.Stat(rc); err == nil {
+				err = ir.Eval("source %{%q}", rc)
+				if err != nil {
+					fmt.Fprintln(stderr, err)
+				}
+			}
+		}
+	}
+
+	return sh.interact(ir, stdin, stdout, stderr)
+}
+
+func (sh *Shell) init(ir *Interpreter, plugins []string, argv0 string, argv []string, interactive bool) error {
+	var list bytes.Buffer
+	for i, arg := range argv {
+		if i != 0 {
+			list.WriteString(" ")
+		}
+		quote(&list, arg)
+	}
+
+	tclinteractive := 0
+	if interactive {
+		tclinteractive = 1
+	}
+	err := ir.Eval("set argv0 %{%q}; set argv %{%q}; set argc %{}; set tcl_interactive %{}",
+		argv0, list.String(), len(argv), tclinteractive)
+	if err != nil {
+		return err
+	}
+
+	if sh.Init != nil {
+		err = sh.Init(ir)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, path := range plugins {
+		err = load_plugin(ir, path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func load_plugin(ir *Interpreter, path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	sym, err := p.Lookup(PluginInitSymbol)
+	if err != nil {
+		return err
+	}
+	init, ok := sym.(func(*Interpreter) error)
+	if !ok {
+		return fmt.Errorf("gothic: plugin %q has %s of a wrong type: %T",
+			path, PluginInitSymbol, sym)
+	}
+	return init(ir)
+}
+
+func (sh *Shell) interact(ir *Interpreter, stdin io.Reader, stdout, stderr io.Writer) error {
+	eof, err := console(ir, stdin, stdout, stderr)
+	if eof {
+		ir.Eval("destroy .")
+	}
+	return err
+}
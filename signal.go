@@ -0,0 +1,43 @@
+package gothic
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Catches the `sigs` signals (SIGINT and SIGTERM if none are given) and shuts
+// the interpreter down gracefully: `f` is invoked on the interpreter thread
+// (e.g. to save the application state, can be nil), then all Tk windows are
+// destroyed, which makes Tk's main loop exit and `Done` fire.
+//
+// Returns a function which stops catching the signals.
+func (ir *Interpreter) ShutdownOnSignal(f func(*Interpreter), sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	ch := make(chan os.Signal, 1)
+	quit := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		defer signal.Stop(ch)
+		select {
+		case <-ch:
+		case <-quit:
+			return
+		}
+		ir.ir.run_and_wait(func() error {
+			if f != nil {
+				f(ir)
+			}
+			return ir.ir.eval([]byte("destroy ."))
+		})
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+	}
+}
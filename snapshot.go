@@ -0,0 +1,114 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"unsafe"
+)
+
+// Returns the contents of the Tk photo image `name`.
+func (ir *Interpreter) DownloadImage(name string) (image.Image, error) {
+	var img image.Image
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		var err error
+		img, err = ir.ir.download_image(name)
+		return img, ir.ir.filt(err)
+	}
+	err := ir.ir.run_and_wait(func() error {
+		var err error
+		img, err = ir.ir.download_image(name)
+		return ir.ir.filt(err)
+	})
+	return img, err
+}
+
+// Renders the widget (or toplevel) `path` and returns its contents as an
+// image, useful for golden-image regression tests. Pending idle tasks are
+// processed first, so that the widget is fully drawn. Requires the
+// `img::window` package from tkimg.
+func (ir *Interpreter) Snapshot(path string) (image.Image, error) {
+	var img image.Image
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		var err error
+		img, err = ir.ir.snapshot(path)
+		return img, ir.ir.filt(err)
+	}
+	err := ir.ir.run_and_wait(func() error {
+		var err error
+		img, err = ir.ir.snapshot(path)
+		return ir.ir.filt(err)
+	})
+	return img, err
+}
+
+const snapshot_script = `
+if {[catch {package require img::window}]} {
+	error "gothic: Snapshot requires the img::window package from tkimg"
+}
+update idletasks
+image create photo -format window -data %{%q}
+`
+
+func (ir *interpreter) snapshot(path string) (image.Image, error) {
+	var buf bytes.Buffer
+	err := sprintf(&buf, snapshot_script, path)
+	if err != nil {
+		return nil, err
+	}
+	err = ir.eval(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	name := tcl_obj_to_go_string(C.Tcl_GetObjResult(ir.C))
+	img, err := ir.download_image(name)
+
+	buf.Reset()
+	sprintf(&buf, "image delete %{%q}", name)
+	ir.eval(buf.Bytes())
+	return img, err
+}
+
+func (ir *interpreter) download_image(name string) (image.Image, error) {
+	cname := C.CString(name)
+	handle := C.Tk_FindPhoto(ir.C, cname)
+	C.free(unsafe.Pointer(cname))
+	if handle == nil {
+		return nil, fmt.Errorf("gothic: image %q doesn't exist", name)
+	}
+
+	var block C.Tk_PhotoImageBlock
+	C.Tk_PhotoGetImage(handle, &block)
+	return photo_block_to_image(&block), nil
+}
+
+// copies the pixels of the block into a Go image
+func photo_block_to_image(block *C.Tk_PhotoImageBlock) *image.NRGBA {
+	w, h := int(block.width), int(block.height)
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if w == 0 || h == 0 {
+		return img
+	}
+
+	pitch, size := int(block.pitch), int(block.pixelSize)
+	pix := (*[1 << 30]byte)(unsafe.Pointer(block.pixelPtr))[: pitch*h : pitch*h]
+	off := block.offset
+	for y := 0; y < h; y++ {
+		row := pix[y*pitch:]
+		out := img.Pix[y*img.Stride:]
+		for x := 0; x < w; x++ {
+			p := row[x*size:]
+			q := out[x*4 : x*4+4]
+			q[0], q[1], q[2] = p[off[0]], p[off[1]], p[off[2]]
+			q[3] = 0xFF
+			if size > 3 {
+				q[3] = p[off[3]]
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,68 @@
+package gothic
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// A snapshot of the interpreter runtime metrics, see Interpreter.Stats.
+type Stats struct {
+	Evals           uint64        // scripts evaluated
+	QueueDepth      int           // actions currently waiting in the async queue
+	QueueHighWater  int           // maximum observed async queue depth
+	AvgQueueLatency time.Duration // average time an action waits in the async queue
+	CommandCalls    uint64        // registered command and method invocations
+	ImageUploads    uint64        // UploadImage calls
+}
+
+// all fields are accessed atomically, 64-bit ones go first to keep them
+// aligned on 32-bit platforms
+type interpreter_stats struct {
+	evals         uint64
+	command_calls uint64
+	image_uploads uint64
+	async_actions uint64
+	queue_wait    uint64 // total, in nanoseconds
+	queue_hw      int64
+}
+
+func (s *interpreter_stats) queued(depth int) {
+	for {
+		hw := atomic.LoadInt64(&s.queue_hw)
+		if int64(depth) <= hw || atomic.CompareAndSwapInt64(&s.queue_hw, hw, int64(depth)) {
+			return
+		}
+	}
+}
+
+func (s *interpreter_stats) dequeued(wait time.Duration) {
+	atomic.AddUint64(&s.async_actions, 1)
+	atomic.AddUint64(&s.queue_wait, uint64(wait))
+}
+
+// Returns a snapshot of the interpreter runtime metrics. Unlike most of the
+// other methods it doesn't go through the interpreter thread and never
+// blocks.
+func (ir *Interpreter) Stats() Stats {
+	s := ir.ir.stats
+	st := Stats{
+		Evals:          atomic.LoadUint64(&s.evals),
+		QueueDepth:     ir.ir.queue.len(),
+		QueueHighWater: int(atomic.LoadInt64(&s.queue_hw)),
+		CommandCalls:   atomic.LoadUint64(&s.command_calls),
+		ImageUploads:   atomic.LoadUint64(&s.image_uploads),
+	}
+	if n := atomic.LoadUint64(&s.async_actions); n != 0 {
+		st.AvgQueueLatency = time.Duration(atomic.LoadUint64(&s.queue_wait) / n)
+	}
+	return st
+}
+
+// Publishes the interpreter runtime metrics as an expvar variable called
+// `name`. Like expvar.Publish, it panics if the name is already in use.
+func (ir *Interpreter) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return ir.Stats()
+	}))
+}
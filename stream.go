@@ -0,0 +1,103 @@
+package gothic
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+)
+
+// ::gothic::stream token onvalue ?ondone?
+//
+// Invokes `{*}$onvalue $value` at the global level for each value sent by the
+// Go side and `{*}$ondone` when the channel is closed. Values sent before the
+// callbacks were attached are buffered. Callbacks are run from the event loop
+// in order, errors become background errors.
+const stream_script = `
+namespace eval ::gothic {
+	variable streams
+	variable stream_buffers
+	array set streams {}
+	array set stream_buffers {}
+
+	proc stream_event {token event args} {
+		variable streams
+		variable stream_buffers
+		if {![info exists streams($token)]} {
+			lappend stream_buffers($token) [list $event {*}$args]
+			return
+		}
+		lassign $streams($token) onvalue ondone
+		if {$event eq "value"} {
+			after 0 [list uplevel #0 [list {*}$onvalue {*}$args]]
+			return
+		}
+		if {$ondone ne ""} {
+			after 0 [list uplevel #0 $ondone]
+		}
+		unset streams($token)
+	}
+
+	proc stream {token onvalue {ondone {}}} {
+		variable streams
+		variable stream_buffers
+		set streams($token) [list $onvalue $ondone]
+		if {[info exists stream_buffers($token)]} {
+			set events $stream_buffers($token)
+			unset stream_buffers($token)
+			foreach ev $events {
+				stream_event $token {*}$ev
+			}
+		}
+	}
+}
+`
+
+func (ir *interpreter) init_streams() error {
+	return ir.eval([]byte(stream_script))
+}
+
+var last_stream_id uint64
+
+// Starts delivering values received from the channel `ch` to TCL, returns
+// the stream token. Used for commands returning a receive channel, e.g.:
+//
+//	ir.RegisterCommand("build", func(target string) <-chan string { ... })
+//
+//	::gothic::stream [build all] {.log insert end} {.log insert end done\n}
+//
+// The goroutine waits for each value to be handed over to the interpreter
+// before receiving the next one.
+func (ir *interpreter) start_stream(ch reflect.Value) string {
+	id := atomic.AddUint64(&last_stream_id, 1)
+	token := "gothic::stream" + strconv.FormatUint(id, 10)
+
+	go func() {
+		var buf bytes.Buffer
+		for {
+			v, ok := ch.Recv()
+			if !ok {
+				break
+			}
+			ir.run_and_wait(func() error {
+				err := ir.set_var("::gothic::stream_value", v.Interface(), GlobalOnly)
+				if err != nil {
+					return err
+				}
+				buf.Reset()
+				buf.WriteString("::gothic::stream_event ")
+				quote(&buf, token)
+				buf.WriteString(" value $::gothic::stream_value")
+				return ir.eval(buf.Bytes())
+			})
+		}
+		ir.run_and_wait(func() error {
+			buf.Reset()
+			buf.WriteString("::gothic::stream_event ")
+			quote(&buf, token)
+			buf.WriteString(" done")
+			return ir.eval(buf.Bytes())
+		})
+	}()
+	return token
+}
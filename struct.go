@@ -0,0 +1,179 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// Returns the index of the `t` struct field matching the `key`, or -1 if
+// there is no such field. Fields are matched by the `tcl:"name"` tag first and
+// then by name (case-insensitively). A leading "-" of the key is ignored, so
+// that Tk's option names (e.g. "-text") match too. Fields tagged as `tcl:"-"`
+// and unexported fields are never matched.
+func struct_field_index(t reflect.Type, key string) int {
+	key = strings.TrimPrefix(key, "-")
+	byname := -1
+	for i, n := 0, t.NumField(); i < n; i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("tcl")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			if tag == key {
+				return i
+			}
+			continue
+		}
+		if byname == -1 && strings.EqualFold(f.Name, key) {
+			byname = i
+		}
+	}
+	return byname
+}
+
+// Decodes a flat key/value list (as produced by `array get`, `dict create` or
+// a `cget` loop) into the struct `v`. Keys without a matching field are
+// ignored.
+func (ir *interpreter) tcl_list_to_go_struct(obj *C.Tcl_Obj, v reflect.Value) error {
+	var objc C.int
+	var objv **C.Tcl_Obj
+	status := C.Tcl_ListObjGetElements(ir.C, obj, &objc, &objv)
+	if status != C.TCL_OK {
+		return ir.result_error()
+	}
+	if objc%2 != 0 {
+		return fmt.Errorf("gothic: cannot decode a list with an odd number of elements into %s", v.Type())
+	}
+
+	// objv is NULL for an empty list
+	elems := unsafe.Slice(objv, int(objc))
+	t := v.Type()
+	for i := 0; i < len(elems); i += 2 {
+		idx := struct_field_index(t, tcl_obj_to_go_string(elems[i]))
+		if idx == -1 {
+			continue
+		}
+		err := ir.tcl_obj_to_go_value(elems[i+1], v.Field(idx))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Embedding OptionArgs in a struct makes it an options parameter of command
+// handlers: as the last parameter of a handler, it takes the rest of the TCL
+// arguments as Tk-style "-option value" pairs, so that Go commands are called
+// the way Tk widgets are configured. Options are matched to the fields like
+// the keys of the key/value lists. The fields start with their zero values or
+// the ones from the `default:"..."` tags, converted like the arguments. An
+// unknown option or a missing value fails the command with the Tk error
+// message.
+//
+//	type SaveOpts struct {
+//		gothic.OptionArgs
+//		Format  string `default:"png"`
+//		Quality int    `tcl:"q" default:"90"`
+//	}
+//
+//	ir.RegisterCommand("save", func(path string, opts SaveOpts) error { ... })
+//	// save out.jpg -format jpeg -q 80
+type OptionArgs struct{}
+
+var option_args_type = reflect.TypeOf(OptionArgs{})
+
+func is_option_args(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i, n := 0, t.NumField(); i < n; i++ {
+		if f := t.Field(i); f.Anonymous && f.Type == option_args_type {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the "-option" names of the fields of an options struct, for the
+// error messages.
+func option_names(t reflect.Type) []string {
+	var out []string
+	for i, n := 0, t.NumField(); i < n; i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("tcl")
+		if f.PkgPath != "" || tag == "-" || f.Type == option_args_type {
+			continue
+		}
+		if tag == "" {
+			tag = strings.ToLower(f.Name)
+		}
+		out = append(out, "-"+tag)
+	}
+	return out
+}
+
+// Returns the value of the options struct `t` with the defaults set.
+func (ir *interpreter) option_defaults(t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	for i, n := 0, t.NumField(); i < n; i++ {
+		def, ok := t.Field(i).Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		obj := new_tcl_string_obj(def)
+		C._gotk_c_incr_ref_count(obj)
+		err := ir.tcl_obj_to_go_value(obj, v.Field(i))
+		C._gotk_c_decr_ref_count(obj)
+		if err != nil {
+			return v, fmt.Errorf("gothic: bad default of %s.%s: %v", t, t.Field(i).Name, err)
+		}
+	}
+	return v, nil
+}
+
+// Decodes the "-option value" pairs `args` into the options struct `v`,
+// which holds the defaults.
+func (ir *interpreter) tcl_args_to_go_options(args []*C.Tcl_Obj, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < len(args); i += 2 {
+		key := tcl_obj_to_go_string(args[i])
+		idx := -1
+		if strings.HasPrefix(key, "-") {
+			idx = struct_field_index(t, key)
+		}
+		if idx == -1 || t.Field(idx).Type == option_args_type {
+			return fmt.Errorf("bad option %q: must be %s", key, join_alternatives(option_names(t)))
+		}
+		if i+1 == len(args) {
+			return fmt.Errorf("value for %q missing", key)
+		}
+		err := ir.tcl_obj_to_go_value(args[i+1], v.Field(idx))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Joins the alternatives the way TCL error messages do: "a, b, or c".
+func join_alternatives(s []string) string {
+	switch len(s) {
+	case 0:
+		return "nothing"
+	case 1:
+		return s[0]
+	case 2:
+		return s[0] + " or " + s[1]
+	}
+	return strings.Join(s[:len(s)-1], ", ") + ", or " + s[len(s)-1]
+}
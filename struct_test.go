@@ -0,0 +1,96 @@
+package gothic
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type test_struct struct {
+	Text    string
+	Width   int `tcl:"w"`
+	Ignored int `tcl:"-"`
+	private int
+}
+
+func test_field(t *testing.T, gold int, key string) {
+	i := struct_field_index(reflect.TypeOf(test_struct{}), key)
+	if i != gold {
+		t.Errorf("%q: %d != %d", key, gold, i)
+	}
+}
+
+func TestStructFieldIndex(t *testing.T) {
+	test_field(t, 0, "Text")
+	test_field(t, 0, "text")
+	test_field(t, 0, "-text")
+	test_field(t, 1, "w")
+	test_field(t, -1, "width")
+	test_field(t, -1, "ignored")
+	test_field(t, -1, "private")
+	test_field(t, -1, "nonexistent")
+}
+
+func TestDecodeStruct(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		var s test_struct
+		err := ir.EvalAs(&s, "list -text hello w 10")
+		if err != nil {
+			t.Error(err)
+		} else if s.Text != "hello" || s.Width != 10 {
+			t.Errorf("unexpected result %+v", s)
+		}
+
+		// the last value wins
+		err = ir.EvalAs(&s, "concat [lrepeat 600000 w 1] w 2")
+		if err != nil {
+			t.Error(err)
+		} else if s.Width != 2 {
+			t.Errorf("2 != %d", s.Width)
+		}
+
+		s = test_struct{}
+		err = ir.EvalAs(&s, "list")
+		if err != nil {
+			t.Error(err)
+		} else if s != (test_struct{}) {
+			t.Errorf("unexpected result %+v", s)
+		}
+	})
+}
+
+type test_save_opts struct {
+	OptionArgs
+	Format  string `default:"png"`
+	Quality int    `tcl:"q" default:"90"`
+	Verbose bool
+}
+
+func TestOptionArgs(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		ir.RegisterCommand("save", func(path string, opts test_save_opts) string {
+			return fmt.Sprintf("%s %s %d %v", path, opts.Format, opts.Quality, opts.Verbose)
+		})
+		for script, gold := range map[string]string{
+			"save a":                        "a png 90 false",
+			"save b -format jpeg -q 80":     "b jpeg 80 false",
+			"save c -verbose 1 -format gif": "c gif 90 true",
+			"save d -q 10; save e":          "e png 90 false",
+		} {
+			var s string
+			err := ir.EvalAs(&s, script)
+			if err != nil {
+				t.Error(err)
+			} else if s != gold {
+				t.Errorf("%s: %s != %s", script, gold, s)
+			}
+		}
+
+		err := ir.Eval("save a -size 10")
+		must_contain(t, err, `bad option "-size": must be -format, -q, or -verbose`)
+		err = ir.Eval("save a -format")
+		must_contain(t, err, `value for "-format" missing`)
+		err = ir.Eval("save a -q x")
+		must_contain(t, err, `expected integer but got "x"`)
+	})
+}
@@ -0,0 +1,94 @@
+package gothic
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Options of a ttk style, see Interpreter.StyleConfigure. Empty fields are
+// left untouched, options without a dedicated field go into Extra.
+type StyleOpts struct {
+	Background       string `tcl:"background"`
+	Foreground       string `tcl:"foreground"`
+	FieldBackground  string `tcl:"fieldbackground"`
+	BorderColor      string `tcl:"bordercolor"`
+	LightColor       string `tcl:"lightcolor"`
+	DarkColor        string `tcl:"darkcolor"`
+	SelectBackground string `tcl:"selectbackground"`
+	SelectForeground string `tcl:"selectforeground"`
+	Font             string `tcl:"font"`
+	Padding          string `tcl:"padding"`
+	Relief           string `tcl:"relief"`
+	Anchor           string `tcl:"anchor"`
+	BorderWidth      string `tcl:"borderwidth"`
+	Extra            map[string]interface{}
+}
+
+// A state-dependent option value for Interpreter.StyleMap. State is a ttk
+// state specification, e.g. "pressed !disabled".
+type StateValue struct {
+	State string
+	Value string
+}
+
+// Configures the ttk style `style` (e.g. "Accent.TButton"), wrapping
+// `ttk::style configure`.
+func (ir *Interpreter) StyleConfigure(style string, opts StyleOpts) error {
+	var buf bytes.Buffer
+	buf.WriteString("ttk::style configure ")
+	quote(&buf, style)
+	write_struct_options(&buf, &opts)
+	return ir.EvalBytes(buf.Bytes())
+}
+
+// Sets state-dependent values of the style options, wrapping
+// `ttk::style map`. The `opts` map is keyed by the option name.
+func (ir *Interpreter) StyleMap(style string, opts map[string][]StateValue) error {
+	var buf bytes.Buffer
+	buf.WriteString("ttk::style map ")
+	quote(&buf, style)
+	m := make(map[string]interface{}, len(opts))
+	for name, values := range opts {
+		var list bytes.Buffer
+		for i, sv := range values {
+			if i != 0 {
+				list.WriteString(" ")
+			}
+			quote(&list, sv.State)
+			list.WriteString(" ")
+			quote(&list, sv.Value)
+		}
+		m[name] = list.String()
+	}
+	write_map_options(&buf, m)
+	return ir.EvalBytes(buf.Bytes())
+}
+
+// Returns the value of the style option, taking the `state` into account (can
+// be empty), wrapping `ttk::style lookup`.
+func (ir *Interpreter) StyleLookup(style, option, state string) (string, error) {
+	var out string
+	option = "-" + strings.TrimPrefix(option, "-")
+	if state == "" {
+		err := ir.EvalAs(&out, "ttk::style lookup %{%q} %{%q}", style, option)
+		return out, err
+	}
+	err := ir.EvalAs(&out, "ttk::style lookup %{%q} %{%q} %{%q}", style, option, state)
+	return out, err
+}
+
+// Creates the style `dst` as a copy of the `src` style: its layout, options
+// and state maps.
+func (ir *Interpreter) StyleClone(dst, src string) error {
+	return ir.Eval(`apply {{dst src} {
+		ttk::style layout $dst [ttk::style layout $src]
+		set opts [ttk::style configure $src]
+		if {[llength $opts]} {
+			ttk::style configure $dst {*}$opts
+		}
+		set opts [ttk::style map $src]
+		if {[llength $opts]} {
+			ttk::style map $dst {*}$opts
+		}
+	}} %{%q} %{%q}`, dst, src)
+}
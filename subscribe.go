@@ -0,0 +1,106 @@
+package gothic
+
+import (
+	"sync"
+)
+
+// Options of Subscribe.
+type SubscribeOpts struct {
+	// Only the latest value is applied: the values received while the
+	// previous one waits for the interpreter thread replace each other. Use
+	// it for state snapshots (sensor readings, progress), where stale values
+	// are worthless and a fast producer would flood the event queue.
+	Conflate bool
+}
+
+// Applies each value received from `ch` on the interpreter thread, e.g. to
+// pump data from a network connection into widgets:
+//
+//	gothic.Subscribe(ir, temps, func(ir *gothic.Interpreter, t float64) {
+//		ir.Eval(".temp configure -text %{%.1f}", t)
+//	})
+//
+// A goroutine reads the channel until it's closed, `stop` is called or the
+// interpreter is closed. Values are applied in order, one at a time, the
+// goroutine waits for each one to be applied before receiving the next
+// (unless conflated, see SubscribeOpts). Once `stop` returns, `apply` isn't
+// called anymore.
+func Subscribe[T any](ir *Interpreter, ch <-chan T, apply func(*Interpreter, T), opts ...SubscribeOpts) (stop func()) {
+	var o SubscribeOpts
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	quit := make(chan struct{})
+	deliver := func(v T) error {
+		return ir.ir.run(func() error {
+			select {
+			case <-quit:
+			default:
+				apply(ir, v)
+			}
+			return nil
+		})
+	}
+
+	if o.Conflate {
+		go conflate(ch, quit, deliver)
+	} else {
+		go func() {
+			for {
+				select {
+				case v, ok := <-ch:
+					if !ok || deliver(v) == ErrInterpreterClosed {
+						return
+					}
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(quit)
+			// wait for the value being applied, if any, unless called from
+			// `apply` itself
+			ir.ir.run(func() error { return nil })
+		})
+	}
+}
+
+// Reads `ch` into a single slot, replacing the value which wasn't delivered
+// yet, another goroutine delivers the slot's values.
+func conflate[T any](ch <-chan T, quit <-chan struct{}, deliver func(T) error) {
+	slot := make(chan T, 1)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for v := range slot {
+			if deliver(v) == ErrInterpreterClosed {
+				return
+			}
+		}
+	}()
+	defer close(slot)
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return
+			}
+			// the reader is the only sender, after dropping the stale value
+			// there is room for the new one
+			select {
+			case <-slot:
+			default:
+			}
+			slot <- v
+		case <-quit:
+			return
+		case <-closed:
+			return
+		}
+	}
+}
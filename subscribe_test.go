@@ -0,0 +1,71 @@
+package gothic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Quit()
+
+	ch := make(chan int)
+	var got []int
+	last := make(chan struct{})
+	stop := Subscribe(ir, ch, func(ir *Interpreter, v int) {
+		got = append(got, v)
+		if v == 5 {
+			close(last)
+		}
+	})
+	for i := 1; i <= 5; i++ {
+		ch <- i
+	}
+	<-last
+	stop()
+	if len(got) != 5 {
+		t.Errorf("expected 5 values in order, got %v", got)
+	}
+	for i, v := range got {
+		if v != i+1 {
+			t.Errorf("expected 5 values in order, got %v", got)
+			break
+		}
+	}
+}
+
+func TestSubscribeConflate(t *testing.T) {
+	ir, err := NewWithOptions(nil, Options{NoTk: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Quit()
+
+	ch := make(chan int)
+	var got []int
+	last := make(chan struct{})
+	stop := Subscribe(ir, ch, func(ir *Interpreter, v int) {
+		got = append(got, v)
+		time.Sleep(time.Millisecond)
+		if v == 100 {
+			close(last)
+		}
+	}, SubscribeOpts{Conflate: true})
+	defer stop()
+	for i := 1; i <= 100; i++ {
+		ch <- i
+	}
+	<-last
+	if len(got) >= 100 {
+		t.Errorf("values weren't conflated: %d applied", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Errorf("values applied out of order: %v", got)
+			break
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package gothic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Returned by Interpreter.NewSysTray when neither `tk systray` nor the
+// tktray package is available.
+var ErrSysTrayUnavailable = errors.New("gothic: system tray requires Tk 8.7 or the tktray package")
+
+// A system tray icon created by Interpreter.NewSysTray.
+type SysTray struct {
+	ir      *Interpreter
+	kind    string // "tk" or "tktray"
+	id      int
+	path    string // tktray icon window
+	image   string
+	command string
+	onclick func()
+}
+
+// ::gothic::systray_menu id
+//
+// Pops up the menu of the tray icon `id` at the pointer.
+const systray_script = `
+namespace eval ::gothic {
+	variable systray_menus
+
+	proc systray_menu {id} {
+		variable systray_menus
+		if {[info exists systray_menus($id)] && $systray_menus($id) ne ""} {
+			tk_popup $systray_menus($id) {*}[winfo pointerxy .]
+		}
+	}
+
+	proc systray_kind {} {
+		if {![catch {tk systray exists}]} {
+			return tk
+		}
+		if {![catch {package require tktray}]} {
+			return tktray
+		}
+		return ""
+	}
+}
+`
+
+// Creates a system tray icon with an empty image, use SetIcon to set it.
+// Uses `tk systray` (Tk 8.7, Shell_NotifyIcon on Windows and the status bar
+// on macOS) if available, the tktray package on X11 otherwise. Tk 8.7
+// supports a single tray icon per interpreter.
+//
+// The left button invokes the OnClick handler, the right button pops up the
+// menu set with SetMenu.
+func (ir *Interpreter) NewSysTray() (*SysTray, error) {
+	var s *SysTray
+	err := ir.ir.run(func() error {
+		var err error
+		s, err = ir.ir.new_systray(ir)
+		return ir.ir.filt(err)
+	})
+	return s, err
+}
+
+func (ir *interpreter) new_systray(iir *Interpreter) (*SysTray, error) {
+	err := ir.eval([]byte(systray_script))
+	if err != nil {
+		return nil, err
+	}
+	var kind string
+	err = ir.eval_as(&kind, []byte("::gothic::systray_kind"))
+	if err != nil {
+		return nil, err
+	}
+	if kind == "" {
+		return nil, ErrSysTrayUnavailable
+	}
+
+	ir.lastid++
+	s := &SysTray{
+		ir:      iir,
+		kind:    kind,
+		id:      ir.lastid,
+		path:    fmt.Sprintf(".gothic_systray%d", ir.lastid),
+		image:   fmt.Sprintf("::gothic::systray%d", ir.lastid),
+		command: ir.next_command("systray_click"),
+	}
+	err = ir.register_command(s.command, func() {
+		if s.onclick != nil {
+			s.onclick()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	script := `
+		image create photo %{1%q} -width 16 -height 16
+		tk systray create -image %{1%q} -text "" -button1 %{2%q} -button3 {::gothic::systray_menu %{0}}
+	`
+	if kind == "tktray" {
+		script = `
+			image create photo %{1%q} -width 16 -height 16
+			tktray::icon %{3%q} -image %{1%q}
+			bind %{3%q} <ButtonRelease-1> %{2%q}
+			bind %{3%q} <ButtonRelease-3> {::gothic::systray_menu %{0}}
+		`
+	}
+	var buf bytes.Buffer
+	err = sprintf(&buf, script, s.id, s.image, s.command, s.path)
+	if err == nil {
+		err = ir.eval(buf.Bytes())
+	}
+	if err != nil {
+		ir.unregister_command(s.command)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Sets the icon image, it's scaled by the platform if necessary.
+func (s *SysTray) SetIcon(img image.Image) error {
+	return s.ir.ir.run(func() error {
+		err := s.ir.Eval("%{%q} blank; %{%q} configure -width 0 -height 0", s.image, s.image)
+		if err != nil {
+			return err
+		}
+		return s.ir.ir.filt(s.ir.ir.upload_image(s.image, img))
+	})
+}
+
+// Sets the text shown when the pointer is over the icon. The tktray package
+// has no tooltips, the text is ignored there.
+func (s *SysTray) SetTooltip(text string) error {
+	if s.kind != "tk" {
+		return nil
+	}
+	return s.ir.Eval("tk systray configure -text %{%q}", text)
+}
+
+// Sets the menu popped up by the right button, an empty path disables it.
+// The menu is an ordinary Tk menu, e.g. created with `menu .traymenu
+// -tearoff 0` and filled with `.traymenu add command ...`.
+func (s *SysTray) SetMenu(menu string) error {
+	return s.ir.Eval("set ::gothic::systray_menus(%{}) %{%q}", s.id, menu)
+}
+
+// Sets the handler of the left button clicks, it's invoked on the
+// interpreter thread.
+func (s *SysTray) OnClick(handler func()) {
+	s.ir.ir.run(func() error {
+		s.onclick = handler
+		return nil
+	})
+}
+
+// Removes the icon from the tray.
+func (s *SysTray) Close() error {
+	return s.ir.ir.run(func() error {
+		script := "tk systray destroy"
+		if s.kind == "tktray" {
+			script = "destroy %{0%q}"
+		}
+		err := s.ir.Eval(script+"\nimage delete %{1%q}\nunset -nocomplain ::gothic::systray_menus(%{2})",
+			s.path, s.image, s.id)
+		s.ir.ir.unregister_command(s.command)
+		return err
+	})
+}
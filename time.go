@@ -0,0 +1,80 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	time_type     = reflect.TypeOf(time.Time{})
+	duration_type = reflect.TypeOf(time.Duration(0))
+)
+
+// ISO-8601 layouts accepted when decoding time.Time from a string, the first
+// one is what `clock format $t -format {%Y-%m-%dT%H:%M:%S}` produces
+var time_layouts = []string{
+	"2006-01-02T15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// time.Time is passed to TCL as clock seconds, time.Duration as milliseconds
+// (suitable for `after`)
+func time_to_tcl_obj(v reflect.Value) *C.Tcl_Obj {
+	switch v.Type() {
+	case time_type:
+		t := v.Interface().(time.Time)
+		return C.Tcl_NewWideIntObj(C.Tcl_WideInt(t.Unix()))
+	case duration_type:
+		d := time.Duration(v.Int())
+		return C.Tcl_NewWideIntObj(C.Tcl_WideInt(d / time.Millisecond))
+	}
+	return nil
+}
+
+// time.Time is accepted as clock seconds or an ISO-8601 string (local time
+// is assumed if there is no zone information), time.Duration as (possibly
+// fractional) milliseconds
+func (ir *interpreter) tcl_obj_to_time(obj *C.Tcl_Obj, v reflect.Value) error {
+	switch v.Type() {
+	case time_type:
+		var secs C.Tcl_WideInt
+		if C.Tcl_GetWideIntFromObj(nil, obj, &secs) == C.TCL_OK {
+			v.Set(reflect.ValueOf(time.Unix(int64(secs), 0)))
+			return nil
+		}
+		s := tcl_obj_to_go_string(obj)
+		for _, layout := range time_layouts {
+			t, err := time.ParseInLocation(layout, s, time.Local)
+			if err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("gothic: cannot convert %q to time.Time", s)
+	case duration_type:
+		var ms C.Tcl_WideInt
+		if C.Tcl_GetWideIntFromObj(nil, obj, &ms) == C.TCL_OK {
+			v.SetInt(int64(time.Duration(ms) * time.Millisecond))
+			return nil
+		}
+		var fms C.double
+		if C.Tcl_GetDoubleFromObj(nil, obj, &fms) == C.TCL_OK {
+			v.SetInt(int64(float64(fms) * float64(time.Millisecond)))
+			return nil
+		}
+		s := tcl_obj_to_go_string(obj)
+		return fmt.Errorf("gothic: cannot convert %q to time.Duration", s)
+	}
+	panic("unreachable")
+}
+
+func is_time_type(t reflect.Type) bool {
+	return t == time_type || t == duration_type
+}
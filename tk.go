@@ -0,0 +1,153 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+	"errors"
+	"unsafe"
+)
+
+// Parameters of the Tk initialization. They are passed to Tk_Init the same
+// way wish passes its command-line arguments. Empty fields mean defaults.
+type TkOptions struct {
+	// X display to use, defaults to the $DISPLAY environment variable.
+	Display string
+
+	// Application name used by `send` and as the title of the main window.
+	AppName string
+
+	// Initial geometry of the main window, e.g. "640x480+10+10".
+	Geometry string
+
+	// Make X server requests synchronous, useful for debugging.
+	Sync bool
+
+	// Class of the main window, used by the option database and window
+	// managers. Defaults to the AppName with the first letter capitalized.
+	ClassName string
+
+	// Id of the window to embed the main window into (-use).
+	Use string
+
+	// Pass the current `::argv` to Tk as well, the way wish does: Tk
+	// consumes its standard options (-display, -geometry, -name, -use,
+	// -sync, -colormap, -visual) up to "--", the rest is left in `::argv`
+	// and `::argc`.
+	UseArgv bool
+}
+
+func (opts *TkOptions) args() []string {
+	var args []string
+	if opts.Display != "" {
+		args = append(args, "-display", opts.Display)
+	}
+	if opts.AppName != "" {
+		args = append(args, "-name", opts.AppName)
+	}
+	if opts.Geometry != "" {
+		args = append(args, "-geometry", opts.Geometry)
+	}
+	if opts.Use != "" {
+		args = append(args, "-use", opts.Use)
+	}
+	if opts.Sync {
+		args = append(args, "-sync")
+	}
+	return args
+}
+
+// Initializes Tk in an interpreter created without it (see
+// NewTclInterpreter and Options.NoTk). Once Tk is initialized, the
+// interpreter thread switches to Tk's main loop semantics: `Done` fires when
+// all Tk windows are destroyed. Useful for tools which only sometimes open a
+// GUI and must not require a display at startup.
+func (ir *Interpreter) InitTk(opts TkOptions) error {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return ir.ir.filt(ir.ir.init_tk(&opts))
+	}
+	return ir.ir.run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.init_tk(&opts))
+	})
+}
+
+// Tk_Init takes its options from the `argv` variable, the original value is
+// restored afterwards, unless it's passed to Tk too (see TkOptions.UseArgv)
+const init_tk_prologue = `
+if {[info exists ::argv]} {
+	set ::gothic::saved_argv $::argv
+}
+set ::argv %{%q}
+`
+
+const init_tk_argv_prologue = `
+if {![info exists ::argv]} {
+	set ::argv {}
+}
+set ::argv [list {*}%{%q} {*}$::argv]
+`
+
+const init_tk_argv_epilogue = `
+set ::argc [llength $::argv]
+`
+
+const init_tk_epilogue = `
+if {[info exists ::gothic::saved_argv]} {
+	set ::argv $::gothic::saved_argv
+	unset ::gothic::saved_argv
+} else {
+	unset -nocomplain ::argv
+}
+`
+
+func (ir *interpreter) init_tk(opts *TkOptions) error {
+	if !ir.notk {
+		return errors.New("gothic: Tk is already initialized")
+	}
+	err := load_tk()
+	if err != nil {
+		return err
+	}
+
+	var list bytes.Buffer
+	for i, arg := range opts.args() {
+		if i != 0 {
+			list.WriteString(" ")
+		}
+		quote(&list, arg)
+	}
+	prologue, epilogue := init_tk_prologue, init_tk_epilogue
+	if opts.UseArgv {
+		prologue, epilogue = init_tk_argv_prologue, init_tk_argv_epilogue
+	}
+	var buf bytes.Buffer
+	err = sprintf(&buf, prologue, list.String())
+	if err != nil {
+		return err
+	}
+	err = ir.eval(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	status := C.Tk_Init(ir.C)
+	if status != C.TCL_OK {
+		err = ir.result_error()
+	}
+	ir.eval([]byte(epilogue))
+	if err != nil {
+		return err
+	}
+
+	if opts.ClassName != "" {
+		cclass := C.CString(opts.ClassName)
+		C.Tk_SetClass(C.Tk_MainWindow(ir.C), cclass)
+		C.free(unsafe.Pointer(cclass))
+	}
+
+	ir.notk = false
+	init_photo_formats()
+	return nil
+}
@@ -0,0 +1,71 @@
+package gothic
+
+import (
+	"embed"
+	"io/fs"
+	"sync"
+)
+
+//go:embed tklib
+var bundled_tklib embed.FS
+
+// The file system LoadTklib takes pure-TCL packages from: a directory per
+// module with a pkgIndex.tcl, the layout of tklib's "modules" directory.
+//
+// gothic doesn't bundle tklib's packages, tablelist and ctext included. It
+// bundles "gothic::tooltip" only: a small tooltip package implementing the
+// basic API of tklib's tooltip, under a name which doesn't conflict with an
+// installed tklib. Applications needing tklib's packages embed a tklib
+// checkout (along with its license) and set it here before the first
+// LoadTklib call, it's mounted with MountFS under "/gothic:/tklib":
+//
+//	//go:embed tklib
+//	var tklib embed.FS
+//
+//	gothic.TklibFS, _ = fs.Sub(tklib, "tklib/modules")
+var TklibFS fs.FS
+
+func init() {
+	TklibFS, _ = fs.Sub(bundled_tklib, "tklib")
+}
+
+// The mount point of TklibFS.
+const tklib_prefix = "/gothic:/tklib"
+
+var tklib_mount struct {
+	once sync.Once
+	err  error
+}
+
+// Mounts TklibFS with MountFS once per process.
+func mount_tklib() error {
+	tklib_mount.once.Do(func() {
+		tklib_mount.err = MountFS(tklib_prefix, TklibFS)
+	})
+	return tklib_mount.err
+}
+
+// Loads the packages `names` (e.g. "gothic::tooltip") from TklibFS with
+// `package require`, so that the packages an application embeds work on
+// systems where tklib isn't installed.
+func (ir *Interpreter) LoadTklib(names ...string) error {
+	err := mount_tklib()
+	if err != nil {
+		return err
+	}
+	return ir.ir.run(func() error {
+		err := ir.Eval(`if {[lsearch -exact $::auto_path %{0%q}] < 0} {
+			lappend ::auto_path %{0%q}
+		}`, tklib_prefix)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			err := ir.Eval("package require %{%q}", name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1 @@
+package ifneeded gothic::tooltip 1.4 [list source [file join $dir tooltip.tcl]]
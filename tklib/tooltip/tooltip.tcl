@@ -0,0 +1,130 @@
+# Tooltips for Tk widgets, compatible with the basic API of tklib's tooltip
+# package. It's provided under its own name, so that it doesn't shadow or
+# conflict with an installed tklib:
+#
+#   gothic::tooltip::tooltip pathName message  - sets the tooltip of a widget
+#   gothic::tooltip::tooltip pathName {}       - removes it
+#   gothic::tooltip::tooltip delay ?millisecs? - gets or sets the delay
+#   gothic::tooltip::tooltip on|off            - enables or disables tooltips
+#   gothic::tooltip::tooltip clear ?pattern?   - removes matching tooltips
+
+package require Tk 8.5
+package provide gothic::tooltip 1.4
+
+namespace eval ::gothic::tooltip {
+	namespace export tooltip
+
+	variable enabled 1
+	variable delay 500
+	variable after_id {}
+	variable messages
+	array set messages {}
+}
+
+proc ::gothic::tooltip::tooltip {w args} {
+	variable enabled
+	variable delay
+	variable messages
+
+	switch -- $w {
+		delay {
+			if {[llength $args]} {
+				set delay [lindex $args 0]
+			}
+			return $delay
+		}
+		on - enable {
+			set enabled 1
+			return
+		}
+		off - disable {
+			set enabled 0
+			hide
+			return
+		}
+		clear {
+			set pattern [expr {[llength $args] ? [lindex $args 0] : "*"}]
+			foreach name [array names messages $pattern] {
+				unset messages($name)
+			}
+			return
+		}
+	}
+
+	if {![winfo exists $w]} {
+		return -code error "bad window path name \"$w\""
+	}
+	set msg [lindex $args 0]
+	if {$msg eq ""} {
+		unset -nocomplain messages($w)
+		return
+	}
+	set messages($w) $msg
+	if {[lsearch -exact [bindtags $w] GothicTooltip] < 0} {
+		bindtags $w [linsert [bindtags $w] end GothicTooltip]
+	}
+	return $w
+}
+
+proc ::gothic::tooltip::schedule {w} {
+	variable enabled
+	variable delay
+	variable after_id
+	variable messages
+
+	hide
+	if {$enabled && [info exists messages($w)]} {
+		set after_id [after $delay [list ::gothic::tooltip::show $w]]
+	}
+}
+
+proc ::gothic::tooltip::show {w} {
+	variable messages
+
+	if {![winfo exists $w] || ![info exists messages($w)]} {
+		return
+	}
+	set t .__gothic_tooltip__
+	if {![winfo exists $t]} {
+		toplevel $t -class GothicTooltipWindow -background black -borderwidth 1
+		wm withdraw $t
+		wm overrideredirect $t 1
+		catch {wm attributes $t -topmost 1}
+		label $t.label -background lightyellow -foreground black \
+			-justify left -wraplength 400 -padx 4 -pady 2
+		pack $t.label
+	}
+	$t.label configure -text $messages($w)
+	update idletasks
+
+	set x [expr {[winfo pointerx $w] + 12}]
+	set y [expr {[winfo rooty $w] + [winfo height $w] + 4}]
+	set right [expr {[winfo screenwidth $w] - [winfo reqwidth $t]}]
+	if {$x > $right} {
+		set x $right
+	}
+	wm geometry $t +$x+$y
+	wm deiconify $t
+	raise $t
+}
+
+proc ::gothic::tooltip::hide {} {
+	variable after_id
+
+	after cancel $after_id
+	set after_id {}
+	if {[winfo exists .__gothic_tooltip__]} {
+		wm withdraw .__gothic_tooltip__
+	}
+}
+
+proc ::gothic::tooltip::forget {w} {
+	variable messages
+	unset -nocomplain messages($w)
+}
+
+bind GothicTooltip <Enter> {::gothic::tooltip::schedule %W}
+bind GothicTooltip <Leave> {::gothic::tooltip::hide}
+bind GothicTooltip <ButtonPress> {::gothic::tooltip::hide}
+bind GothicTooltip <KeyPress> {::gothic::tooltip::hide}
+bind GothicTooltip <Destroy> {::gothic::tooltip::forget %W}
@@ -0,0 +1,32 @@
+package gothic
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadTklib(t *testing.T) {
+	saved := TklibFS
+	defer func() { TklibFS = saved }()
+	TklibFS = fstest.MapFS{
+		"hello/pkgIndex.tcl": {Data: []byte("package ifneeded hello 1.0 [list source [file join $dir hello.tcl]]\n")},
+		"hello/hello.tcl":    {Data: []byte("package provide hello 1.0\nproc hello {} {return hi}\n")},
+	}
+
+	NewTclInterpreter(func(ir *Interpreter) {
+		err := ir.LoadTklib("hello")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var s string
+		err = ir.EvalAs(&s, "hello")
+		if err != nil {
+			t.Error(err)
+		} else if s != "hi" {
+			t.Errorf("hi != %s", s)
+		}
+		err = ir.LoadTklib("nonexistent")
+		must_contain(t, err, "nonexistent")
+	})
+}
@@ -0,0 +1,216 @@
+package gothic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+)
+
+// Options for Interpreter.NewToplevel, empty fields are left at their
+// defaults.
+type ToplevelOpts struct {
+	Name     string // prefix of the generated path, "top" by default
+	Title    string
+	Geometry string // e.g. "640x480+10+10"
+	Class    string // window class, used by the option database
+
+	// path of the window to stay on top of (`wm transient`)
+	Transient string
+
+	// invoked on the interpreter thread when the user asks the window
+	// manager to close the window, the window is destroyed if it's nil
+	OnClose func()
+
+	// additional `toplevel` options
+	Extra map[string]interface{}
+}
+
+// A handle to a toplevel window created by Interpreter.NewToplevel.
+type Toplevel struct {
+	ir      *Interpreter
+	path    string
+	onclose string // command name or empty
+}
+
+// Creates a toplevel window with a unique path and registers it in the list
+// of open windows (see Interpreter.Toplevels), it's removed from the list
+// when the window is destroyed.
+func (ir *Interpreter) NewToplevel(opts ToplevelOpts) (*Toplevel, error) {
+	var t *Toplevel
+	err := ir.ir.run(func() error {
+		var err error
+		t, err = ir.ir.new_toplevel(ir, &opts)
+		return ir.ir.filt(err)
+	})
+	return t, err
+}
+
+const toplevel_destroyed_cmd = "::gothic::toplevel_destroyed"
+
+func (ir *interpreter) new_toplevel(iir *Interpreter, opts *ToplevelOpts) (*Toplevel, error) {
+	if ir.toplevels == nil {
+		ir.toplevels = make(map[string]*Toplevel)
+		err := ir.register_command(toplevel_destroyed_cmd, func(path string) {
+			t, ok := ir.toplevels[path]
+			if !ok {
+				return
+			}
+			delete(ir.toplevels, path)
+			if t.onclose != "" {
+				ir.unregister_command(t.onclose)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = "top"
+	}
+	ir.lastid++
+	t := &Toplevel{ir: iir, path: fmt.Sprintf(".%s%d", name, ir.lastid)}
+
+	var buf bytes.Buffer
+	buf.WriteString("toplevel ")
+	quote(&buf, t.path)
+	if opts.Class != "" {
+		write_option(&buf, "class", opts.Class)
+	}
+	write_map_options(&buf, opts.Extra)
+	buf.WriteString("\nbind ")
+	quote(&buf, t.path)
+	// <Destroy> is delivered for the children too
+	sprintf(&buf, " <Destroy> {if {[string equal %W %{%q}]} {%{} %W}}", t.path, toplevel_destroyed_cmd)
+	if opts.Title != "" {
+		buf.WriteString("\nwm title ")
+		quote(&buf, t.path)
+		buf.WriteString(" ")
+		quote(&buf, opts.Title)
+	}
+	if opts.Geometry != "" {
+		buf.WriteString("\nwm geometry ")
+		quote(&buf, t.path)
+		buf.WriteString(" ")
+		quote(&buf, opts.Geometry)
+	}
+	if opts.Transient != "" {
+		buf.WriteString("\nwm transient ")
+		quote(&buf, t.path)
+		buf.WriteString(" ")
+		quote(&buf, opts.Transient)
+	}
+	err := ir.eval(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	ir.toplevels[t.path] = t
+
+	if opts.OnClose != nil {
+		t.onclose = ir.next_command("onclose")
+		err = ir.register_command(t.onclose, opts.OnClose)
+		if err != nil {
+			return nil, err
+		}
+		buf.Reset()
+		buf.WriteString("wm protocol ")
+		quote(&buf, t.path)
+		buf.WriteString(" WM_DELETE_WINDOW ")
+		quote(&buf, t.onclose)
+		err = ir.eval(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Returns the toplevel windows created by NewToplevel which are still open.
+func (ir *Interpreter) Toplevels() []*Toplevel {
+	var out []*Toplevel
+	ir.ir.run(func() error {
+		for _, t := range ir.ir.toplevels {
+			out = append(out, t)
+		}
+		return nil
+	})
+	return out
+}
+
+// Returns the path of the window.
+func (t *Toplevel) Path() string {
+	return t.path
+}
+
+// Destroys the window.
+func (t *Toplevel) Close() error {
+	return t.ir.Eval("destroy %{%q}", t.path)
+}
+
+// Raises the window above its siblings and gives it the focus.
+func (t *Toplevel) Raise() error {
+	return t.ir.Eval("wm deiconify %{0%q}; raise %{0%q}; focus -force %{0%q}", t.path)
+}
+
+// Makes the window modal: sets a local grab on it and waits until it's
+// destroyed. Events keep being processed meanwhile. See
+// Interpreter.RunModal.
+func (t *Toplevel) Modal() error {
+	return t.ir.RunModal(t.path, nil)
+}
+
+// Sets the icon of the window to the given images.
+func (t *Toplevel) SetIcon(imgs ...image.Image) error {
+	return t.ir.SetWindowIcon(t.path, imgs...)
+}
+
+// Sets the icon of the window `window` (`wm iconphoto`), the images are
+// different sizes of the icon, the window manager picks the one that fits
+// best. For the main window "." the icon also becomes the default one for
+// the toplevels created later (`wm iconphoto -default`). Tk copies the
+// pixels, the images can be reused.
+func (ir *Interpreter) SetWindowIcon(window string, imgs ...image.Image) error {
+	if len(imgs) == 0 {
+		return errors.New("gothic: SetWindowIcon needs at least one image")
+	}
+	return ir.ir.run(func() error {
+		return ir.ir.filt(ir.ir.set_window_icon(window, imgs))
+	})
+}
+
+func (ir *interpreter) set_window_icon(window string, imgs []image.Image) error {
+	// larger images go first, as Tk recommends
+	imgs = append([]image.Image(nil), imgs...)
+	sort.SliceStable(imgs, func(i, j int) bool {
+		bi, bj := imgs[i].Bounds(), imgs[j].Bounds()
+		return bi.Dx()*bi.Dy() > bj.Dx()*bj.Dy()
+	})
+
+	var photos bytes.Buffer
+	defer func() {
+		if photos.Len() > 0 {
+			ir.eval(append([]byte("image delete"), photos.Bytes()...))
+		}
+	}()
+	for _, img := range imgs {
+		name := ir.next_command("icon")
+		err := ir.upload_image(name, img)
+		if err != nil {
+			return err
+		}
+		photos.WriteString(" ")
+		quote(&photos, name)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("wm iconphoto ")
+	quote(&buf, window)
+	if window == "." {
+		buf.WriteString(" -default")
+	}
+	buf.Write(photos.Bytes())
+	return ir.eval(buf.Bytes())
+}
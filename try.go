@@ -0,0 +1,47 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"errors"
+)
+
+// Returned by the Try* method family when the async queue of the interpreter
+// is full.
+var ErrBusy = errors.New("gothic: interpreter queue is full")
+
+// Works exactly as Eval, but instead of blocking when the async queue is full
+// it returns ErrBusy immediately. Once the script is queued, it waits for its
+// completion as usual. Useful for real-time producers which prefer dropping
+// updates to stalling.
+func (ir *Interpreter) TryEval(format string, args ...interface{}) error {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return ir.Eval(format, args...)
+	}
+
+	buf := buffer_pool.get()
+	err := sprintf(&buf, format, args...)
+	if err != nil {
+		buffer_pool.put(buf)
+		return ir.ir.filt(err)
+	}
+	script := buf.Bytes()
+	err = ir.ir.try_run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.eval(script))
+	})
+	buffer_pool.put(buf)
+	return err
+}
+
+// Runs `f` on the interpreter thread and returns its error, or returns
+// ErrBusy immediately if the async queue is full.
+func (ir *Interpreter) TryDo(f func(*Interpreter) error) error {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return f(ir)
+	}
+	return ir.ir.try_run_and_wait(func() error {
+		return f(ir)
+	})
+}
@@ -0,0 +1,60 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+)
+
+// A handle passed to the Interpreter.Transaction function. It must not be used
+// after that function returns.
+type Tx struct {
+	ir  *interpreter
+	buf bytes.Buffer
+}
+
+// Runs `f` as a single unit on the interpreter thread, no other queued actions
+// are interleaved with the calls made through `tx`, hence event handlers can't
+// observe a half-applied multi-widget update. There is no rollback, if `f`
+// returns an error, the changes made before it stay in place and the error is
+// returned as is.
+//
+// Note that scripts which enter the event loop themselves (e.g. `update`)
+// break the atomicity guarantee.
+func (ir *Interpreter) Transaction(f func(tx *Tx) error) error {
+	run := func() error {
+		tx := Tx{ir: ir.ir}
+		return f(&tx)
+	}
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return run()
+	}
+	return ir.ir.run_and_wait(run)
+}
+
+// Works the same way as Interpreter.Eval.
+func (tx *Tx) Eval(format string, args ...interface{}) error {
+	tx.buf.Reset()
+	err := sprintf(&tx.buf, format, args...)
+	if err != nil {
+		return tx.ir.filt(err)
+	}
+	return tx.ir.filt(tx.ir.eval(tx.buf.Bytes()))
+}
+
+// Works the same way as Interpreter.EvalAs.
+func (tx *Tx) EvalAs(out interface{}, format string, args ...interface{}) error {
+	tx.buf.Reset()
+	err := sprintf(&tx.buf, format, args...)
+	if err != nil {
+		return tx.ir.filt(err)
+	}
+	return tx.ir.filt(tx.ir.eval_as(out, tx.buf.Bytes()))
+}
+
+// Works the same way as Interpreter.Set.
+func (tx *Tx) Set(name string, val interface{}) error {
+	return tx.ir.filt(tx.ir.set(name, val))
+}
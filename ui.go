@@ -0,0 +1,352 @@
+package gothic
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Declarative UI construction: a tree of widget values is turned into widget
+// creation and geometry scripts, e.g.:
+//
+//  ui, err := ir.Build(".", gothic.Window{
+//          Title: "Login",
+//          Children: []gothic.Widget{
+//                  gothic.Entry{Name: "user", TextVariable: "user"},
+//                  gothic.Button{Text: "OK", Command: func() { ... }},
+//          },
+//  })
+//  ...
+//  ir.Eval("focus %{}", ui.Path("user"))
+
+// A node of a declarative UI tree, see Interpreter.Build.
+type Widget interface {
+	Spec() Generic
+}
+
+// Specifies how a widget is managed by its parent, implemented by the
+// results of Grid, Pack and Place called without windows, e.g.
+// gothic.Grid().Row(1).Sticky("ew").
+type Layout interface {
+	layout_script(window string) []byte
+}
+
+func (b *GridBuilder) layout_script(window string) []byte {
+	g := b.g
+	g.windows = []string{window}
+	return g.script()
+}
+
+func (b *PackBuilder) layout_script(window string) []byte {
+	g := b.g
+	g.windows = []string{window}
+	return g.script()
+}
+
+func (b *PlaceBuilder) layout_script(window string) []byte {
+	g := b.g
+	g.windows = []string{window}
+	return g.script()
+}
+
+// A widget of any type, the other widget types are shortcuts for it.
+type Generic struct {
+	Type string // widget command, e.g. "ttk::button"
+	Name string // last component of the path, generated if empty
+
+	// widget options, func values are registered as commands and the option
+	// is set to the command name
+	Options map[string]interface{}
+
+	// packed if nil
+	Layout Layout
+
+	// event bindings, keyed by the event pattern (e.g. "<Return>"), values
+	// are funcs or names of commands, both are invoked with the widget path
+	Bindings map[string]interface{}
+
+	Children []Widget
+}
+
+func (w Generic) Spec() Generic { return w }
+
+// A toplevel window. Without a Name it stands for the parent window passed to
+// Interpreter.Build (usually ".").
+type Window struct {
+	Name     string
+	Title    string
+	Geometry string // e.g. "640x480"
+	Options  map[string]interface{}
+	Children []Widget
+}
+
+func (w Window) Spec() Generic {
+	return Generic{Type: "toplevel", Name: w.Name, Options: w.Options, Children: w.Children}
+}
+
+type Frame struct {
+	Name     string
+	Padding  string
+	Options  map[string]interface{}
+	Layout   Layout
+	Children []Widget
+}
+
+func (w Frame) Spec() Generic {
+	opts := with_options(w.Options, "padding", w.Padding)
+	return Generic{Type: "ttk::frame", Name: w.Name, Options: opts, Layout: w.Layout, Children: w.Children}
+}
+
+type Label struct {
+	Name         string
+	Text         string
+	TextVariable string
+	Options      map[string]interface{}
+	Layout       Layout
+}
+
+func (w Label) Spec() Generic {
+	opts := with_options(w.Options, "text", w.Text, "textvariable", w.TextVariable)
+	return Generic{Type: "ttk::label", Name: w.Name, Options: opts, Layout: w.Layout}
+}
+
+type Button struct {
+	Name    string
+	Text    string
+	Command func()
+	Options map[string]interface{}
+	Layout  Layout
+}
+
+func (w Button) Spec() Generic {
+	opts := with_options(w.Options, "text", w.Text)
+	if w.Command != nil {
+		opts["command"] = w.Command
+	}
+	return Generic{Type: "ttk::button", Name: w.Name, Options: opts, Layout: w.Layout}
+}
+
+type Entry struct {
+	Name         string
+	TextVariable string
+	Width        int
+	Options      map[string]interface{}
+	Layout       Layout
+}
+
+func (w Entry) Spec() Generic {
+	opts := with_options(w.Options, "textvariable", w.TextVariable, "width", w.Width)
+	return Generic{Type: "ttk::entry", Name: w.Name, Options: opts, Layout: w.Layout}
+}
+
+type Checkbutton struct {
+	Name     string
+	Text     string
+	Variable string
+	Command  func()
+	Options  map[string]interface{}
+	Layout   Layout
+}
+
+func (w Checkbutton) Spec() Generic {
+	opts := with_options(w.Options, "text", w.Text, "variable", w.Variable)
+	if w.Command != nil {
+		opts["command"] = w.Command
+	}
+	return Generic{Type: "ttk::checkbutton", Name: w.Name, Options: opts, Layout: w.Layout}
+}
+
+// returns a copy of `opts` with non-zero name/value pairs from `kv` added
+func with_options(opts map[string]interface{}, kv ...interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(opts)+len(kv)/2)
+	for k, v := range opts {
+		out[k] = v
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if !reflect.ValueOf(kv[i+1]).IsZero() {
+			out[kv[i].(string)] = kv[i+1]
+		}
+	}
+	return out
+}
+
+// Widgets created by Interpreter.Build.
+type UI struct {
+	ir       *Interpreter
+	paths    map[string]string
+	roots    []string
+	commands []string
+}
+
+// Returns the path of the widget with the Name `name`, or an empty string if
+// there is no such widget.
+func (ui *UI) Path(name string) string {
+	return ui.paths[name]
+}
+
+// Destroys the created widgets and unregisters their commands.
+func (ui *UI) Destroy() error {
+	return ui.ir.ir.run(func() error {
+		for _, path := range ui.roots {
+			err := ui.ir.Eval("destroy %{%q}", path)
+			if err != nil {
+				return err
+			}
+		}
+		for _, cmd := range ui.commands {
+			ui.ir.ir.unregister_command(cmd)
+		}
+		return nil
+	})
+}
+
+// Creates the widget tree `w` within the `parent` window. A Window without a
+// Name configures the parent itself. Widgets are created in order, an error
+// stops the process, leaving the widgets created so far.
+func (ir *Interpreter) Build(parent string, w Widget) (*UI, error) {
+	ui := &UI{ir: ir, paths: make(map[string]string)}
+	err := ir.ir.run(func() error {
+		if win, ok := w.(Window); ok && (win.Name == "" || win.Name == ".") {
+			return ui.build_in(parent, win)
+		}
+		path, err := ui.build(parent, w)
+		if err != nil {
+			return err
+		}
+		ui.roots = append(ui.roots, path)
+		return nil
+	})
+	return ui, err
+}
+
+// configures an existing window instead of creating a toplevel
+func (ui *UI) build_in(path string, win Window) error {
+	if len(win.Options) > 0 {
+		var buf bytes.Buffer
+		quote(&buf, path)
+		buf.WriteString(" configure")
+		err := ui.write_options(&buf, win.Options)
+		if err != nil {
+			return err
+		}
+		err = ui.ir.EvalBytes(buf.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	err := ui.configure_window(path, win)
+	if err != nil {
+		return err
+	}
+	for _, child := range win.Children {
+		child_path, err := ui.build(path, child)
+		if err != nil {
+			return err
+		}
+		ui.roots = append(ui.roots, child_path)
+	}
+	return nil
+}
+
+func (ui *UI) build(parent string, w Widget) (string, error) {
+	ir := ui.ir.ir
+	spec := w.Spec()
+	name := spec.Name
+	if name == "" {
+		ir.lastid++
+		name = fmt.Sprintf("gothic%d", ir.lastid)
+	}
+	path := strings.TrimSuffix(parent, ".") + "." + name
+	if spec.Name != "" {
+		ui.paths[spec.Name] = path
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(spec.Type)
+	buf.WriteString(" ")
+	quote(&buf, path)
+	err := ui.write_options(&buf, spec.Options)
+	if err != nil {
+		return path, err
+	}
+	err = ui.ir.EvalBytes(buf.Bytes())
+	if err != nil {
+		return path, err
+	}
+
+	if win, ok := w.(Window); ok {
+		err = ui.configure_window(path, win)
+	} else if spec.Layout != nil {
+		err = ui.ir.EvalBytes(spec.Layout.layout_script(path))
+	} else {
+		err = ui.ir.EvalBytes(Pack().layout_script(path))
+	}
+	if err != nil {
+		return path, err
+	}
+
+	for pattern, handler := range spec.Bindings {
+		err = ui.bind(path, pattern, handler)
+		if err != nil {
+			return path, err
+		}
+	}
+
+	for _, child := range spec.Children {
+		_, err := ui.build(path, child)
+		if err != nil {
+			return path, err
+		}
+	}
+	return path, nil
+}
+
+func (ui *UI) configure_window(path string, win Window) error {
+	if win.Title != "" {
+		err := ui.ir.Eval("wm title %{%q} %{%q}", path, win.Title)
+		if err != nil {
+			return err
+		}
+	}
+	if win.Geometry != "" {
+		return ui.ir.Eval("wm geometry %{%q} %{%q}", path, win.Geometry)
+	}
+	return nil
+}
+
+// registers func values as commands, returns the value to be used in TCL
+func (ui *UI) command(value interface{}) (interface{}, error) {
+	if value == nil || reflect.TypeOf(value).Kind() != reflect.Func {
+		return value, nil
+	}
+	cmd := ui.ir.ir.next_command("ui")
+	err := ui.ir.ir.register_command(cmd, value)
+	if err != nil {
+		return nil, ui.ir.ir.filt(err)
+	}
+	ui.commands = append(ui.commands, cmd)
+	return cmd, nil
+}
+
+// writes options, registering func values as commands
+func (ui *UI) write_options(buf *bytes.Buffer, opts map[string]interface{}) error {
+	out := make(map[string]interface{}, len(opts))
+	for name, value := range opts {
+		value, err := ui.command(value)
+		if err != nil {
+			return err
+		}
+		out[name] = value
+	}
+	write_map_options(buf, out)
+	return nil
+}
+
+func (ui *UI) bind(path, pattern string, handler interface{}) error {
+	cmd, err := ui.command(handler)
+	if err != nil {
+		return err
+	}
+	return ui.ir.Eval("bind %{%q} %{%q} [list %{%q} %W]", path, pattern, cmd)
+}
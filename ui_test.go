@@ -0,0 +1,80 @@
+package gothic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		// fake widget and geometry commands recording their arguments
+		err := ir.Eval(`
+			set log {}
+			namespace eval ttk {}
+			proc ttk::frame {args} {lappend ::log [list frame {*}$args]}
+			proc ttk::label {args} {lappend ::log [list label {*}$args]}
+			proc grid {args} {lappend ::log [list grid {*}$args]}
+			proc pack {args} {lappend ::log [list pack {*}$args]}
+		`)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		ui, err := ir.Build(".", Frame{
+			Name: "f",
+			Children: []Widget{
+				Label{Name: "l", Text: "Name:", Layout: Grid().Row(1)},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if p := ui.Path("l"); p != ".f.l" {
+			t.Errorf(".f.l != %s", p)
+		}
+
+		var log string
+		ir.EvalAs(&log, `join $log "\n"`)
+		gold := "frame .f\npack configure .f\nlabel .f.l -text Name:\ngrid configure .f.l -row 1"
+		if log != gold {
+			t.Errorf("%s != %s", gold, log)
+		}
+	})
+}
+
+func TestLoadLayout(t *testing.T) {
+	NewTclInterpreter(func(ir *Interpreter) {
+		err := ir.Eval(`
+			set log {}
+			namespace eval ttk {}
+			proc ttk::entry {args} {lappend ::log [list entry {*}$args]}
+			proc grid {args} {lappend ::log [list grid {*}$args]}
+			proc bind {args} {lappend ::log [list bind {*}$args]}
+			proc search {w} {}
+		`)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		layout := `{"type": "ttk::entry", "name": "q",
+			"layout": {"manager": "grid", "row": 0},
+			"events": {"<Return>": "search"}}`
+		_, err = ir.LoadLayout(".", []byte(layout), json.Unmarshal)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var log string
+		ir.EvalAs(&log, `join $log "\n"`)
+		gold := "entry .q\ngrid configure .q -row 0\nbind .q <Return> {search %W}"
+		if log != gold {
+			t.Errorf("%s != %s", gold, log)
+		}
+
+		_, err = ir.LoadLayout(".", []byte(`{"type": "ttk::entry", "command": "nope"}`), json.Unmarshal)
+		must_contain(t, err, `unknown command "nope"`)
+	})
+}
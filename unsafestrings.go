@@ -0,0 +1,35 @@
+//go:build !gothic_safestrings
+
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// Strings are passed between Go and TCL without copying, see
+// safestrings.go for the gothic_safestrings build tag.
+
+// Returns a string sharing the memory of the C string, it must not be used
+// once the C memory changes or is freed.
+func cgo_string_to_go_string(p *C.char, n C.int) string {
+	if n == 0 {
+		return ""
+	}
+	return unsafe.String((*byte)(unsafe.Pointer(p)), int(n))
+}
+
+// Returns a pointer to the string data for the duration of a C call, C must
+// not keep it. Call free_cgo_string when done.
+func go_string_to_cgo_string(s string) (*C.char, C.int) {
+	if len(s) == 0 {
+		return nil, 0
+	}
+	return (*C.char)(unsafe.Pointer(unsafe.StringData(s))), C.int(len(s))
+}
+
+func free_cgo_string(p *C.char) {
+}
@@ -0,0 +1,155 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"errors"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// TCL stores strings in a modified UTF-8: NUL is encoded as the two byte
+// sequence C0 80 and, when TCL_UTF_MAX is 3 (Tcl 8.x), characters outside of
+// the BMP are encoded as surrogate pairs (CESU-8). Go strings are converted to
+// that form on the way in and back on the way out, so that they round-trip
+// intact. Invalid UTF-8 bytes are passed as is, TCL preserves them as long as
+// the string isn't modified.
+var tcl_utf_max = int(C.TCL_UTF_MAX)
+
+// reports whether `s` can be passed to TCL without conversion
+func tcl_utf_valid(s string) bool {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c == 0 {
+			return false
+		}
+		if c < utf8.RuneSelf {
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		if size == 4 && tcl_utf_max < 4 {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
+// appends the TCL's modified UTF-8 form of `s` to `dst`
+func append_tcl_utf(dst []byte, s string) []byte {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c == 0 {
+			dst = append(dst, 0xC0, 0x80)
+			i++
+			continue
+		}
+		if c < utf8.RuneSelf {
+			dst = append(dst, c)
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if size == 4 && tcl_utf_max < 4 {
+			r -= 0x10000
+			dst = append_cesu8_surrogate(dst, 0xD800+(r>>10))
+			dst = append_cesu8_surrogate(dst, 0xDC00+(r&0x3FF))
+		} else {
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return dst
+}
+
+func append_cesu8_surrogate(dst []byte, r rune) []byte {
+	return append(dst, byte(0xE0|r>>12), byte(0x80|(r>>6)&0x3F), byte(0x80|r&0x3F))
+}
+
+// decodes a 3-byte encoded surrogate at the beginning of `s`, returns 0 if
+// there is none
+func decode_cesu8_surrogate(s string) rune {
+	if len(s) < 3 || s[0] != 0xED || s[1]&0xE0 != 0xA0 || s[2]&0xC0 != 0x80 {
+		return 0
+	}
+	return 0xD000 | rune(s[1]&0x3F)<<6 | rune(s[2]&0x3F)
+}
+
+// reports whether `s` (coming from TCL) can be used as a Go string without
+// conversion
+func go_utf_valid(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case 0xC0:
+			if i+1 < len(s) && s[i+1] == 0x80 {
+				return false
+			}
+		case 0xED:
+			if decode_cesu8_surrogate(s[i:]) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// appends the Go form of the TCL's modified UTF-8 string `s` to `dst`
+func append_go_utf(dst []byte, s string) []byte {
+	for i := 0; i < len(s); {
+		if s[i] == 0xC0 && i+1 < len(s) && s[i+1] == 0x80 {
+			dst = append(dst, 0)
+			i += 2
+			continue
+		}
+		if hi := decode_cesu8_surrogate(s[i:]); hi >= 0xD800 && hi < 0xDC00 {
+			lo := decode_cesu8_surrogate(s[i+3:])
+			if lo >= 0xDC00 && lo < 0xE000 {
+				dst = utf8.AppendRune(dst, 0x10000+(hi-0xD800)<<10+(lo-0xDC00))
+				i += 6
+				continue
+			}
+		}
+		dst = append(dst, s[i])
+		i++
+	}
+	return dst
+}
+
+// creates a TCL string object out of a Go string
+func new_tcl_string_obj(s string) *C.Tcl_Obj {
+	if !tcl_utf_valid(s) {
+		b := append_tcl_utf(make([]byte, 0, len(s)+8), s)
+		return C.Tcl_NewStringObj((*C.char)(unsafe.Pointer(&b[0])), C.int(len(b)))
+	}
+	p, n := go_string_to_cgo_string(s)
+	obj := C.Tcl_NewStringObj(p, n)
+	free_cgo_string(p)
+	return obj
+}
+
+// converts a TCL string (as returned by Tcl_GetStringFromObj and friends) to
+// a Go string
+func tcl_string_to_go_string(p *C.char, n C.int) string {
+	s := cgo_string_to_go_string(p, n)
+	if !go_utf_valid(s) {
+		return string(append_go_utf(make([]byte, 0, len(s)), s))
+	}
+	return C.GoStringN(p, n)
+}
+
+// returns the string representation of a TCL object as a Go string
+func tcl_obj_to_go_string(obj *C.Tcl_Obj) string {
+	var n C.int
+	p := C.Tcl_GetStringFromObj(obj, &n)
+	return tcl_string_to_go_string(p, n)
+}
+
+// returns the current interpreter result as an error
+func (ir *interpreter) result_error() error {
+	var n C.int
+	p := C.Tcl_GetStringFromObj(C.Tcl_GetObjResult(ir.C), &n)
+	return errors.New(tcl_string_to_go_string(p, n))
+}
@@ -0,0 +1,44 @@
+package gothic
+
+import (
+	"testing"
+)
+
+func test_utf_round_trip(t *testing.T, s string) {
+	tcl := string(append_tcl_utf(nil, s))
+	if tcl_utf_valid(s) && tcl != s {
+		t.Errorf("%q: valid string was modified: %q", s, tcl)
+	}
+	if !tcl_utf_valid(tcl) {
+		t.Errorf("%q: converted string is not valid for TCL: %q", s, tcl)
+	}
+	for i := 0; i < len(tcl); i++ {
+		if tcl[i] == 0 {
+			t.Errorf("%q: converted string contains NUL: %q", s, tcl)
+		}
+	}
+	back := string(append_go_utf(nil, tcl))
+	if back != s {
+		t.Errorf("%q != %q", s, back)
+	}
+}
+
+func TestUTFRoundTrip(t *testing.T) {
+	test_utf_round_trip(t, "")
+	test_utf_round_trip(t, "hello")
+	test_utf_round_trip(t, "привет, 世界")
+	test_utf_round_trip(t, "a\x00b\x00")
+	test_utf_round_trip(t, "invalid \xff\xfe bytes")
+	test_utf_round_trip(t, "emoji \U0001F600 and \U00010000")
+}
+
+func TestUTFModified(t *testing.T) {
+	if s := string(append_tcl_utf(nil, "\x00")); s != "\xC0\x80" {
+		t.Errorf("NUL is encoded as %q", s)
+	}
+	if tcl_utf_max < 4 {
+		if s := string(append_tcl_utf(nil, "\U0001F600")); s != "\xED\xA0\xBD\xED\xB8\x80" {
+			t.Errorf("U+1F600 is encoded as %q", s)
+		}
+	}
+}
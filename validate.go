@@ -0,0 +1,58 @@
+package gothic
+
+import (
+	"strings"
+)
+
+// ::gothic::validate cmd w v P
+//
+// Tk turns validation off if the entry is modified from within the
+// -validatecommand, it's turned back on when idle.
+const validate_script = `
+namespace eval ::gothic {
+	proc validate {cmd w v P} {
+		after idle [list ::gothic::revalidate $w $v]
+		return [$cmd $P]
+	}
+	proc revalidate {w v} {
+		if {[winfo exists $w] && [$w cget -validate] eq "none"} {
+			$w configure -validate $v
+		}
+	}
+}
+`
+
+func validator_command(entry string) string {
+	return "::gothic::validator" + strings.Replace(entry, "::", "_", -1)
+}
+
+// Makes the `f` function validate every keystroke in the entry widget
+// `entry` (wiring `-validate key` and `-validatecommand`). The function
+// receives the value the entry would have if the edit is accepted (the %P
+// substitution) and returns whether to accept it. It's invoked on the
+// interpreter thread. If you pass nil, the validation is turned off.
+func (ir *Interpreter) SetValidator(entry string, f func(newValue string) bool) error {
+	cmd := validator_command(entry)
+	return ir.ir.run(func() error {
+		if _, ok := ir.ir.commands[cmd]; ok {
+			err := ir.ir.unregister_command(cmd)
+			if err != nil {
+				return ir.ir.filt(err)
+			}
+		}
+		if f == nil {
+			return ir.Eval("%{%q} configure -validate none -validatecommand {}", entry)
+		}
+
+		err := ir.Eval(validate_script)
+		if err != nil {
+			return err
+		}
+		err = ir.ir.register_command(cmd, f)
+		if err != nil {
+			return ir.ir.filt(err)
+		}
+		return ir.Eval("%{0%q} configure -validate key -validatecommand [list ::gothic::validate %{1%q} %W %v %P]",
+			entry, cmd)
+	})
+}
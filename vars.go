@@ -0,0 +1,197 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"unsafe"
+
+	"github.com/nsf/gothic/internal/script"
+)
+
+// Flags controlling the variable name resolution in SetVar and GetVar, and
+// how SetVar sets the value. Flags are combined with "|".
+type VarFlags = script.VarFlags
+
+const (
+	// Resolve the name in the global namespace, even if the call is made
+	// from a command handler invoked inside of a TCL procedure. That's where
+	// Tk widget options like -variable and -textvariable look.
+	GlobalOnly = script.GlobalOnly
+
+	// Resolve the name in the current namespace only.
+	NamespaceOnly = script.NamespaceOnly
+
+	// SetVar appends the value to the current one instead of replacing it,
+	// like `append`. Appending to a log buffer this way doesn't copy it.
+	AppendValue = script.AppendValue
+
+	// SetVar sets the value as a list element (quoting it if needed), with
+	// AppendValue it appends the element to the list, like `lappend`.
+	ListElement = script.ListElement
+)
+
+// the flags are passed to TCL as they are, this fails to compile if their
+// values differ from the TCL ones
+var (
+	_ = [1]struct{}{}[GlobalOnly-C.TCL_GLOBAL_ONLY]
+	_ = [1]struct{}{}[NamespaceOnly-C.TCL_NAMESPACE_ONLY]
+	_ = [1]struct{}{}[AppendValue-C.TCL_APPEND_VALUE]
+	_ = [1]struct{}{}[ListElement-C.TCL_LIST_ELEMENT]
+)
+
+// Works the same way as Set, but allows you to specify how the variable name
+// is resolved and how the value is set (see VarFlags). Namespace-qualified
+// names (e.g. "::ns::var") are supported, the namespace must exist.
+//
+//	ir.SetVar("log", line+"\n", gothic.GlobalOnly|gothic.AppendValue)
+//	ir.SetVar("items", "a b", gothic.AppendValue|gothic.ListElement)
+func (ir *Interpreter) SetVar(name string, val interface{}, flags VarFlags) error {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return ir.ir.filt(ir.ir.set_var(name, val, flags))
+	}
+	return ir.ir.run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.set_var(name, val, flags))
+	})
+}
+
+// Writes the value of the TCL variable `name` into `out`, using the same
+// conversion rules as EvalAs. The `flags` argument has the same meaning as in
+// SetVar. Array elements can be accessed using the "name(key)" syntax.
+func (ir *Interpreter) GetVar(out interface{}, name string, flags VarFlags) error {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return ir.ir.filt(ir.ir.get_var(out, name, flags))
+	}
+	return ir.ir.run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.get_var(out, name, flags))
+	})
+}
+
+// Sets many TCL variables in a single trip to the interpreter thread. The
+// variables are set in the order of their names, all of them are attempted
+// even if some fail, the first error is returned.
+func (ir *Interpreter) SetMany(vars map[string]interface{}) error {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	run := func() error {
+		var first error
+		for _, name := range names {
+			err := ir.ir.set(name, vars[name])
+			if err != nil && first == nil {
+				first = err
+			}
+		}
+		return ir.ir.filt(first)
+	}
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return run()
+	}
+	return ir.ir.run_and_wait(run)
+}
+
+// Sets the elements of the TCL array variable `name`, creating it if
+// necessary. Existing elements which are not in `elems` are left untouched.
+func (ir *Interpreter) SetArray(name string, elems map[string]interface{}) error {
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		return ir.ir.filt(ir.ir.set_array(name, elems))
+	}
+	return ir.ir.run_and_wait(func() error {
+		return ir.ir.filt(ir.ir.set_array(name, elems))
+	})
+}
+
+// Returns the elements of the TCL array variable `name`.
+func (ir *Interpreter) GetArray(name string) (map[string]string, error) {
+	var out map[string]string
+	if C.Tcl_GetCurrentThread() == ir.ir.thread {
+		var err error
+		out, err = ir.ir.get_array(name)
+		return out, ir.ir.filt(err)
+	}
+	err := ir.ir.run_and_wait(func() error {
+		var err error
+		out, err = ir.ir.get_array(name)
+		return ir.ir.filt(err)
+	})
+	return out, err
+}
+
+func (ir *interpreter) set_var(name string, value interface{}, flags VarFlags) error {
+	cname := C.CString(name)
+	err := ir.set_element(cname, nil, value, flags)
+	C.free(unsafe.Pointer(cname))
+	return err
+}
+
+func (ir *interpreter) get_var(out interface{}, name string, flags VarFlags) error {
+	pv := reflect.ValueOf(out)
+	if pv.Kind() != reflect.Ptr || pv.IsNil() {
+		panic("gothic: GetVar expected a non-nil pointer argument")
+	}
+
+	cname := C.CString(name)
+	obj := C.Tcl_GetVar2Ex(ir.C, cname, nil, C.int(flags)|C.TCL_LEAVE_ERR_MSG)
+	C.free(unsafe.Pointer(cname))
+	if obj == nil {
+		return ir.result_error()
+	}
+	return ir.tcl_obj_to_go_value(obj, pv.Elem())
+}
+
+func (ir *interpreter) set_element(cname, ckey *C.char, value interface{}, flags VarFlags) error {
+	obj, err := go_value_to_tcl_obj(value)
+	if err != nil {
+		return err
+	}
+	obj = C.Tcl_SetVar2Ex(ir.C, cname, ckey, obj, C.int(flags)|C.TCL_LEAVE_ERR_MSG)
+	if obj == nil {
+		return ir.result_error()
+	}
+	return nil
+}
+
+func (ir *interpreter) set_array(name string, elems map[string]interface{}) error {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	for key, value := range elems {
+		ckey := C.CString(key)
+		err := ir.set_element(cname, ckey, value, 0)
+		C.free(unsafe.Pointer(ckey))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ir *interpreter) get_array(name string) (map[string]string, error) {
+	var buf bytes.Buffer
+	err := sprintf(&buf, "if {[array exists %{0%q}]} {array get %{0%q}} else {error %{1%q}}",
+		name, fmt.Sprintf("gothic: %q isn't an array", name))
+	if err != nil {
+		return nil, err
+	}
+	err = ir.eval(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	elems, err := Obj{C.Tcl_GetObjResult(ir.C)}.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(elems)/2)
+	for i := 0; i+1 < len(elems); i += 2 {
+		out[elems[i].String()] = elems[i+1].String()
+	}
+	return out, nil
+}
@@ -0,0 +1,223 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Mount points of the TCL filesystem backed by Go file systems. The
+// filesystem is process-wide, just like TCL filesystems are.
+var vfs struct {
+	sync.RWMutex
+	registered bool
+	mounts     map[string]fs.FS
+	prefixes   []string // longest first
+}
+
+// Makes the files of `fsys` available to TCL under `prefix`, e.g.
+// "//go:/assets", so that `source`, `open`, `glob`, `file exists`, `image
+// create photo -file` and the rest read them, including files embedded with
+// go:embed:
+//
+//	//go:embed assets
+//	var assets embed.FS
+//
+//	gothic.MountFS("//go:/assets", assets)
+//	ir.Eval("source //go:/assets/assets/ui.tcl")
+//
+// The filesystem is read-only, opened files are read into memory. The prefix
+// must be an absolute path and is cleaned the way TCL normalizes paths, i.e.
+// "//go:/assets" is the same as "/go:/assets". It hides the native files
+// under it.
+func MountFS(prefix string, fsys fs.FS) error {
+	prefix = path.Clean(prefix)
+	if !path.IsAbs(prefix) || prefix == "/" {
+		return errors.New("gothic: invalid mount point " + prefix)
+	}
+
+	err := load_tcl()
+	if err != nil {
+		return err
+	}
+
+	vfs.Lock()
+	defer vfs.Unlock()
+	if !vfs.registered {
+		if C._gotk_c_fs_register() != C.TCL_OK {
+			return errors.New("gothic: failed to register the filesystem")
+		}
+		vfs.registered = true
+		vfs.mounts = make(map[string]fs.FS)
+	}
+	vfs.mounts[prefix] = fsys
+	vfs_update()
+	return nil
+}
+
+// Removes the mount point created by MountFS.
+func UnmountFS(prefix string) {
+	prefix = path.Clean(prefix)
+	vfs.Lock()
+	defer vfs.Unlock()
+	if _, ok := vfs.mounts[prefix]; !ok {
+		return
+	}
+	delete(vfs.mounts, prefix)
+	vfs_update()
+}
+
+// must be called with the lock held
+func vfs_update() {
+	vfs.prefixes = vfs.prefixes[:0]
+	for prefix := range vfs.mounts {
+		vfs.prefixes = append(vfs.prefixes, prefix)
+	}
+	sort.Slice(vfs.prefixes, func(i, j int) bool {
+		return len(vfs.prefixes[i]) > len(vfs.prefixes[j])
+	})
+	// invalidates the paths TCL has already assigned to a filesystem
+	C._gotk_c_fs_changed()
+}
+
+// Returns the file system and the name in it of the TCL path `p`.
+func vfs_lookup(p *C.char, n C.int) (fs.FS, string, bool) {
+	name := path.Clean(tcl_string_to_go_string(p, n))
+	vfs.RLock()
+	defer vfs.RUnlock()
+	for _, prefix := range vfs.prefixes {
+		if name == prefix {
+			return vfs.mounts[prefix], ".", true
+		}
+		if strings.HasPrefix(name, prefix) && name[len(prefix)] == '/' {
+			return vfs.mounts[prefix], name[len(prefix)+1:], true
+		}
+	}
+	return nil, "", false
+}
+
+func vfs_errno(err error) C.int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return C.int(syscall.ENOENT)
+	case errors.Is(err, fs.ErrPermission):
+		return C.int(syscall.EACCES)
+	case errors.Is(err, fs.ErrInvalid):
+		return C.int(syscall.EINVAL)
+	}
+	return C.int(syscall.EIO)
+}
+
+//export _gotk_go_fs_match_path
+func _gotk_go_fs_match_path(p *C.char, n C.int) C.int {
+	_, _, ok := vfs_lookup(p, n)
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+//export _gotk_go_fs_stat
+func _gotk_go_fs_stat(p *C.char, n C.int, isdir *C.int, size, mtime *C.Tcl_WideInt) C.int {
+	fsys, name, ok := vfs_lookup(p, n)
+	if !ok {
+		return C.int(syscall.ENOENT)
+	}
+	fi, err := fs.Stat(fsys, name)
+	if err != nil {
+		return vfs_errno(err)
+	}
+	*isdir = 0
+	if fi.IsDir() {
+		*isdir = 1
+	}
+	*size = C.Tcl_WideInt(fi.Size())
+	*mtime = C.Tcl_WideInt(fi.ModTime().Unix())
+	return 0
+}
+
+//export _gotk_go_fs_read
+func _gotk_go_fs_read(p *C.char, n C.int, data **C.char, size *C.Tcl_WideInt) C.int {
+	fsys, name, ok := vfs_lookup(p, n)
+	if !ok {
+		return C.int(syscall.ENOENT)
+	}
+	fi, err := fs.Stat(fsys, name)
+	if err != nil {
+		return vfs_errno(err)
+	}
+	if fi.IsDir() {
+		return C.int(syscall.EISDIR)
+	}
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return vfs_errno(err)
+	}
+	// never NULL, it's freed by the channel
+	*data = (*C.char)(C.malloc(C.size_t(len(b) + 1)))
+	if len(b) > 0 {
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(*data)), len(b)), b)
+	}
+	*size = C.Tcl_WideInt(len(b))
+	return 0
+}
+
+func vfs_type_matches(isdir bool, types C.int) bool {
+	if types == 0 {
+		return true
+	}
+	if isdir {
+		return types&C.TCL_GLOB_TYPE_DIR != 0
+	}
+	return types&C.TCL_GLOB_TYPE_FILE != 0
+}
+
+//export _gotk_go_fs_list
+func _gotk_go_fs_list(result, dir *C.Tcl_Obj, p *C.char, n C.int, pattern *C.char, types C.int) C.int {
+	fsys, name, ok := vfs_lookup(p, n)
+	if !ok {
+		return 0
+	}
+
+	// no pattern means the directory itself is checked against the types
+	if pattern == nil {
+		fi, err := fs.Stat(fsys, name)
+		if err == nil && vfs_type_matches(fi.IsDir(), types) {
+			C.Tcl_ListObjAppendElement(nil, result, dir)
+		}
+		return 0
+	}
+
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0
+		}
+		return vfs_errno(err)
+	}
+	hidden := *pattern == '.'
+	for _, e := range entries {
+		ename := e.Name()
+		if ename[0] == '.' && !hidden {
+			continue
+		}
+		if !vfs_type_matches(e.IsDir(), types) {
+			continue
+		}
+		cname := C.CString(ename)
+		if C.Tcl_StringMatch(cname, pattern) != 0 {
+			C._gotk_c_fs_append(result, dir, cname, C.int(len(ename)))
+		}
+		C.free(unsafe.Pointer(cname))
+	}
+	return 0
+}
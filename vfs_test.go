@@ -0,0 +1,70 @@
+package gothic
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestMountFS(t *testing.T) {
+	err := MountFS("//go:/test", fstest.MapFS{
+		"lib/hello.tcl":  {Data: []byte("proc hello {} { return hello }")},
+		"lib/data.txt":   {Data: []byte("0123456789")},
+		"lib/.hidden":    {Data: []byte("")},
+		"lib/sub/x.tcl":  {Data: []byte("")},
+		"other/file.txt": {Data: []byte("")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer UnmountFS("//go:/test")
+
+	NewTclInterpreter(func(ir *Interpreter) {
+		var s string
+		err := ir.EvalAs(&s, "source //go:/test/lib/hello.tcl; hello")
+		if err != nil {
+			t.Error(err)
+		} else if s != "hello" {
+			t.Errorf("%s != hello", s)
+		}
+
+		err = ir.EvalAs(&s, `
+			set f [open //go:/test/lib/data.txt]
+			seek $f 5
+			set data [read $f]
+			close $f
+			set data`)
+		if err != nil {
+			t.Error(err)
+		} else if s != "56789" {
+			t.Errorf("%s != 56789", s)
+		}
+
+		err = ir.EvalAs(&s, `list [file exists //go:/test/lib/data.txt] \
+			[file isdirectory /go:/test/lib] [file exists //go:/test/nope] \
+			[file size //go:/test/lib/data.txt]`)
+		if err != nil {
+			t.Error(err)
+		} else if gold := "1 1 0 10"; s != gold {
+			t.Errorf("%s != %s", gold, s)
+		}
+
+		err = ir.EvalAs(&s, "lsort [glob -tails -directory //go:/test/lib *]")
+		if err != nil {
+			t.Error(err)
+		} else if gold := "data.txt hello.tcl sub"; s != gold {
+			t.Errorf("%s != %s", gold, s)
+		}
+
+		err = ir.EvalAs(&s, "glob -tails -types d -directory //go:/test/lib *")
+		if err != nil {
+			t.Error(err)
+		} else if s != "sub" {
+			t.Errorf("%s != sub", s)
+		}
+
+		err = ir.Eval("open //go:/test/lib/data.txt w")
+		must_contain(t, err, "read-only file system")
+		err = ir.Eval("open //go:/test/lib/nope.txt")
+		must_contain(t, err, "no such file or directory")
+	})
+}
@@ -0,0 +1,55 @@
+package gothic
+
+import (
+	"strings"
+	"sync"
+)
+
+// Delivers each new value of the global TCL variable `name` (can be an array
+// element, e.g. "settings(user)") on the returned channel, so that goroutines
+// can react to UI state without polling. Values are coalesced: if the
+// receiver doesn't keep up, only the latest value is kept. Call `stop` to
+// remove the trace, the channel is closed afterwards.
+//
+// If the trace can't be installed, the returned channel is closed right away.
+func (ir *Interpreter) WatchVar(name string) (values <-chan string, stop func()) {
+	name = "::" + strings.TrimPrefix(name, "::")
+	ch := make(chan string, 1)
+	var cmd string
+	err := ir.ir.run(func() error {
+		cmd = ir.ir.next_command("watch")
+		err := ir.ir.register_command(cmd, func(name1, name2, op string) {
+			var value string
+			if ir.ir.get_var(&value, name, GlobalOnly) != nil {
+				return
+			}
+			// the only sender is the interpreter thread, so dropping the
+			// stale value makes room for the new one
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- value
+		})
+		if err != nil {
+			return err
+		}
+		return ir.Eval("trace add variable %{%q} write %{%q}", name, cmd)
+	})
+	if err != nil {
+		close(ch)
+		return ch, func() {}
+	}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			ir.ir.run(func() error {
+				ir.Eval("trace remove variable %{%q} write %{%q}", name, cmd)
+				ir.ir.unregister_command(cmd)
+				close(ch)
+				return nil
+			})
+		})
+	}
+}
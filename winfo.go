@@ -0,0 +1,153 @@
+package gothic
+
+/*
+#include "interpreter.h"
+*/
+import "C"
+import (
+	"image"
+)
+
+// Typed `winfo` queries about the window `path`, see Interpreter.Winfo. Each
+// query runs on the interpreter thread.
+type Winfo struct {
+	ir   *Interpreter
+	path string
+}
+
+// Returns a handle for `winfo` queries about the window `path`. The window
+// is looked up by every query, the handle stays usable if it's recreated.
+func (ir *Interpreter) Winfo(path string) *Winfo {
+	return &Winfo{ir: ir, path: path}
+}
+
+// Returns the path of the window.
+func (w *Winfo) Path() string {
+	return w.path
+}
+
+// geometry as reported by winfo, decoded from a dict
+type winfo_rect struct {
+	X      int `tcl:"x"`
+	Y      int `tcl:"y"`
+	Width  int `tcl:"width"`
+	Height int `tcl:"height"`
+}
+
+func (r winfo_rect) rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+func (w *Winfo) rect(script string) (image.Rectangle, error) {
+	var r winfo_rect
+	err := w.ir.EvalAs(&r, script, w.path)
+	return r.rect(), err
+}
+
+func (w *Winfo) point(script string) (image.Point, error) {
+	var r winfo_rect
+	err := w.ir.EvalAs(&r, script, w.path)
+	return image.Pt(r.X, r.Y), err
+}
+
+func (w *Winfo) str(script string) (string, error) {
+	var s string
+	err := w.ir.EvalAs(&s, script, w.path)
+	return s, err
+}
+
+func (w *Winfo) flag(script string) (bool, error) {
+	var b bool
+	err := w.ir.EvalAs(&b, script, w.path)
+	return b, err
+}
+
+// Reports whether the window exists. Errors (e.g. a closed interpreter)
+// are reported as false.
+func (w *Winfo) Exists() bool {
+	b, err := w.flag("winfo exists %{%q}")
+	return err == nil && b
+}
+
+// Returns the window geometry relative to its parent (`winfo geometry`).
+func (w *Winfo) Geometry() (image.Rectangle, error) {
+	return w.rect("dict create x [winfo x %{0%q}] y [winfo y %{0%q}] " +
+		"width [winfo width %{0%q}] height [winfo height %{0%q}]")
+}
+
+// Returns the window geometry in root window (screen) coordinates.
+func (w *Winfo) RootGeometry() (image.Rectangle, error) {
+	return w.rect("dict create x [winfo rootx %{0%q}] y [winfo rooty %{0%q}] " +
+		"width [winfo width %{0%q}] height [winfo height %{0%q}]")
+}
+
+// Returns the position of the window's upper-left corner in root window
+// coordinates.
+func (w *Winfo) RootXY() (image.Point, error) {
+	return w.point("dict create x [winfo rootx %{0%q}] y [winfo rooty %{0%q}]")
+}
+
+// Returns the size the window requests from its geometry manager.
+func (w *Winfo) ReqSize() (image.Point, error) {
+	return w.point("dict create x [winfo reqwidth %{0%q}] y [winfo reqheight %{0%q}]")
+}
+
+// Returns the pointer position relative to the window, (-1, -1) if the
+// pointer is on another screen.
+func (w *Winfo) PointerXY() (image.Point, error) {
+	return w.point("lassign [winfo pointerxy %{0%q}] x y\n" +
+		"if {$x != -1} {incr x -[winfo rootx %{0%q}]; incr y -[winfo rooty %{0%q}]}\n" +
+		"dict create x $x y $y")
+}
+
+// Reports whether the window is mapped (`winfo ismapped`).
+func (w *Winfo) IsMapped() (bool, error) {
+	return w.flag("winfo ismapped %{%q}")
+}
+
+// Reports whether the window and all its ancestors up to the toplevel are
+// mapped (`winfo viewable`).
+func (w *Winfo) IsViewable() (bool, error) {
+	return w.flag("winfo viewable %{%q}")
+}
+
+// Returns the window class, e.g. "TButton".
+func (w *Winfo) Class() (string, error) {
+	return w.str("winfo class %{%q}")
+}
+
+// Returns the path of the parent window, empty for the main window.
+func (w *Winfo) Parent() (string, error) {
+	return w.str("winfo parent %{%q}")
+}
+
+// Returns the path of the toplevel window containing the window.
+func (w *Winfo) Toplevel() (string, error) {
+	return w.str("winfo toplevel %{%q}")
+}
+
+// Returns the geometry manager of the window, empty if it's not managed.
+func (w *Winfo) Manager() (string, error) {
+	return w.str("winfo manager %{%q}")
+}
+
+// Returns the paths of the window's children in stacking order.
+func (w *Winfo) Children() ([]string, error) {
+	var out []string
+	err := w.ir.ir.run(func() error {
+		err := w.ir.Eval("winfo children %{%q}", w.path)
+		if err != nil {
+			return err
+		}
+		elems, err := Obj{C.Tcl_GetObjResult(w.ir.ir.C)}.List()
+		if err != nil {
+			return w.ir.ir.filt(err)
+		}
+		out = make([]string, len(elems))
+		for i, e := range elems {
+			out[i] = e.String()
+		}
+		return nil
+	})
+	return out, err
+}